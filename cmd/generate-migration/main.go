@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+	"github.com/carflores-zh/qldb-go/pkg/storage"
+)
+
+const (
+	filePerm = 0o644
+	dirPerm  = 0o755
+)
+
+var invalidName = regexp.MustCompile(`[^a-z0-9]+`)
+
+// PARAM 0: name of the migration, e.g. contract-indexes
+// --dir: directory with the up and down migrations (default sql)
+//
+// writes up/N-name.sql and down/N-name.sql, N is the version after the last one, with the statements that
+// take the schema of the migrations to the tables declared in model.Tables. Review them before migrating
+
+func main() {
+	dir := flag.String("dir", "sql", "directory with the up and down migrations")
+	flag.Parse()
+
+	params := flag.Args()
+
+	if len(params) < 1 {
+		log.Fatal().Msg("not enough params")
+	}
+
+	name := invalidName.ReplaceAllString(strings.ToLower(params[0]), "-")
+
+	version, diff, err := storage.GenerateMigration(os.DirFS(*dir), model.Tables)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error generating migration")
+	}
+
+	if diff.Empty() {
+		log.Info().Msg("the migrations are up to date with model.Tables")
+		return
+	}
+
+	file := fmt.Sprintf("%d-%s.sql", version, name)
+
+	// neither file is written when one of them couldn't be migrated
+	if err = diff.Validate(file); err != nil {
+		log.Fatal().Err(err).Msg("invalid migration generated")
+	}
+
+	paths, err := writeMigration(*dir, file, map[string]string{"up": diff.UpFile(), "down": diff.DownFile()})
+	if err != nil {
+		log.Fatal().Err(err).Msg("error writing migration")
+	}
+
+	for _, path := range paths {
+		log.Info().Str("file", path).Msg("migration written")
+	}
+}
+
+// writeMigration writes the files of a migration, by migration type, to temporary files and renames them once
+// they are all written. A failure removes what was written, a migration is never left without its up or down file
+func writeMigration(dir string, file string, contents map[string]string) ([]string, error) {
+	temps := map[string]string{}
+
+	defer func() {
+		for _, temp := range temps {
+			_ = os.Remove(temp)
+		}
+	}()
+
+	for migrationType, content := range contents {
+		if err := os.MkdirAll(filepath.Join(dir, migrationType), dirPerm); err != nil {
+			return nil, err
+		}
+
+		temp, err := os.CreateTemp(filepath.Join(dir, migrationType), "."+file+"-*")
+		if err != nil {
+			return nil, err
+		}
+
+		temps[migrationType] = temp.Name()
+
+		_, err = temp.WriteString(content)
+		if errClose := temp.Close(); err == nil {
+			err = errClose
+		}
+
+		if err == nil {
+			err = os.Chmod(temp.Name(), filePerm)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", migrationType, file, err)
+		}
+	}
+
+	var written []string
+
+	for migrationType, temp := range temps {
+		path := filepath.Join(dir, migrationType, file)
+
+		if err := os.Rename(temp, path); err != nil {
+			for _, done := range written {
+				_ = os.Remove(done)
+			}
+
+			return nil, err
+		}
+
+		delete(temps, migrationType)
+		written = append(written, path)
+	}
+
+	return written, nil
+}
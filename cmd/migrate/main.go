@@ -2,37 +2,71 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"io/fs"
 	"os"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/qldb"
-	"github.com/aws/aws-sdk-go-v2/service/qldb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cast"
 
 	"github.com/carflores-zh/qldb-go/pkg/storage"
+	"github.com/carflores-zh/qldb-go/sql"
 )
 
 const time3Minutes = 3 * time.Minute
 const inputParams = 3
 
+// [COMMAND]: optional, migrate (default), status, schema, baseline or force
 // PARAM 0: region
 // PARAM 1: ledger name
-// PARAM 2: version
+// PARAM 2: version, not needed by status and schema
+// MIGRATIONS_DIR: optional, directory with the up and down migrations instead of the ones built into the binary
+// --dry-run prints the files and statements that would be executed, with their validation, and exits
+// --force-unlock deletes the migration lock left by a migrator that died and exits, the version is not needed
+//
+// status prints the applied and pending migrations with their timestamps and checksums
+// schema compares the tables and indexes of the ledger with pkg/model and with the applied migrations
+// baseline records that a ledger without migrations recorded is at version, without running any migration
+// force records that the ledger is at version without running any migration, to repair a partial failure
 
 func main() {
-	params := os.Args[1:]
+	dryRun := flag.Bool("dry-run", false, "print the migration plan without touching the ledger")
+	forceUnlock := flag.Bool("force-unlock", false, "delete the migration lock whoever holds it and exit")
+	flag.Parse()
 
-	if len(params) < inputParams {
-		log.Fatal().Msg("not enough params")
+	params := flag.Args()
+
+	command := "migrate"
+	if len(params) > 0 {
+		switch params[0] {
+		case "migrate", "status", "schema", "baseline", "force":
+			command, params = params[0], params[1:]
+		}
+	}
+
+	required := inputParams
+	if command == "status" || command == "schema" || (command == "migrate" && *forceUnlock) {
+		required = inputParams - 1
+	}
+
+	if len(params) < required {
+		log.Fatal().Str("command", command).Msg("not enough params")
 	}
 
 	region := params[0]
 	ledgerName := params[1]
-	version := cast.ToInt(params[2])
 
-	log.Info().Str("region", region).Str("ledger", ledgerName).Int("version", version).Msg("starting migration")
+	var version int
+	if len(params) >= inputParams {
+		version = cast.ToInt(params[2])
+	}
+
+	log.Info().Str("command", command).Str("region", region).Str("ledger", ledgerName).Int("version", version).Msg("starting migration")
 
 	cfg, err := config.LoadDefaultConfig(context.TODO(),
 		config.WithRegion(region),
@@ -42,7 +76,27 @@ func main() {
 		return
 	}
 
-	db, err := storage.NewMigrator(cfg, ledgerName)
+	opts := []storage.Option{storage.WithLogger(log.Logger)}
+
+	// e.g. QLDB_ENDPOINT=http://localhost:4566 to run the migrations against LocalStack
+	if endpoint := os.Getenv("QLDB_ENDPOINT"); endpoint != "" {
+		opts = append(opts, storage.WithEndpoint(endpoint))
+	}
+
+	// the caller ARN is recorded with every migration
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{})
+	if err != nil {
+		log.Warn().Err(err).Msg("error getting caller identity, recording the OS user")
+	} else {
+		opts = append(opts, storage.WithPrincipal(aws.ToString(identity.Arn)))
+	}
+
+	// MIGRATE_IGNORE_DRIFT=true migrates even if applied migration files were edited
+	if cast.ToBool(os.Getenv("MIGRATE_IGNORE_DRIFT")) {
+		opts = append(opts, storage.WithIgnoreDrift())
+	}
+
+	db, err := storage.NewMigrator(cfg, ledgerName, opts...)
 	if err != nil {
 		log.Error().Err(err).Msg("error connecting/creating")
 		return
@@ -50,50 +104,96 @@ func main() {
 
 	defer db.Driver.Shutdown(context.TODO())
 
-	ledgerInput := qldb.CreateLedgerInput{
-		Name:            &ledgerName,
-		PermissionsMode: types.PermissionsModeStandard,
+	ctx := context.Background()
+
+	var migrations fs.FS = sql.FS
+	if dir := os.Getenv("MIGRATIONS_DIR"); dir != "" {
+		migrations = os.DirFS(dir)
 	}
 
-	ctx := context.Background()
+	switch command {
+	case "status":
+		status, errStatus := db.Status(ctx, migrations)
+		if errStatus != nil {
+			log.Error().Err(errStatus).Msg("error reading migration status")
+			return
+		}
 
-	// create ledger and wait for it to be active
-	for {
-		// getting the list of ledgers
-		list, errList := db.Client.ListLedgers(ctx, nil)
-		if errList != nil {
-			log.Error().Err(errList).Msg("error listing ledgers")
+		fmt.Print(status)
+
+		return
+	case "schema":
+		declared, errSchema := db.CompareDeclaredSchema(ctx)
+		if errSchema != nil {
+			log.Error().Err(errSchema).Msg("error comparing the schema with the model")
 			return
 		}
 
-		index := -1
+		applied, errSchema := db.CompareAppliedSchema(ctx, migrations)
+		if errSchema != nil {
+			log.Error().Err(errSchema).Msg("error comparing the schema with the migrations")
+			return
+		}
+
+		fmt.Print(declared, "\n", applied)
 
-		for i, ledger := range list.Ledgers {
-			if *ledger.Name == ledgerName {
-				index = i
-			}
+		if len(declared.Issues)+len(applied.Issues) > 0 {
+			log.Error().Int("model", len(declared.Issues)).Int("migrations", len(applied.Issues)).Msg("the ledger schema differs")
+		}
+
+		return
+	case "baseline":
+		if err = db.Baseline(ctx, migrations, version); err != nil {
+			log.Error().Err(err).Msg("baseline failed")
 		}
 
-		if index == -1 {
-			log.Info().Str("ledger", ledgerName).Msg("ledger not found, creating it")
+		return
+	case "force":
+		if err = db.Force(ctx, migrations, version); err != nil {
+			log.Error().Err(err).Msg("force failed")
+		}
 
-			_, err = db.Client.CreateLedger(ctx, &ledgerInput)
-			if err != nil {
-				log.Error().Err(err).Msg("error creating ledger")
-			}
+		return
+	}
 
-			continue
+	if *forceUnlock {
+		lock, found, errUnlock := db.ForceUnlock(ctx)
+		switch {
+		case errUnlock != nil:
+			log.Error().Err(errUnlock).Msg("error deleting the migration lock")
+		case !found:
+			log.Info().Msg("the migration lock is not held")
+		default:
+			log.Info().Str("owner", lock.Owner).Time("acquiredAt", lock.AcquiredAt).Time("expiresAt", lock.ExpiresAt).
+				Msg("migration lock deleted")
 		}
 
-		if list.Ledgers[index].State == types.LedgerStateActive {
-			break
-		} else {
-			log.Info().Str("ledger", ledgerName).Str("state", string(list.Ledgers[index].State)).Msg("waiting 3 minutes for ledger to be active")
-			time.Sleep(time3Minutes)
+		return
+	}
+
+	if *dryRun {
+		plan, errPlan := db.Plan(ctx, migrations, version)
+		if errPlan != nil {
+			log.Error().Err(errPlan).Msg("error planning migration")
+			return
+		}
+
+		fmt.Print(plan)
+
+		if errPlan = plan.Valid(); errPlan != nil {
+			log.Error().Err(errPlan).Msg("migration would fail")
 		}
+
+		return
+	}
+
+	// create ledger and wait for it to be active
+	if err = db.EnsureLedger(ctx, time3Minutes); err != nil {
+		log.Error().Err(err).Msg("error waiting for ledger to be active")
+		return
 	}
 
-	err = db.MigrateQLDB("sql/", version)
+	err = db.MigrateQLDB(ctx, migrations, version)
 	if err != nil {
 		log.Error().Err(err).Msg("migration failed")
 		return
@@ -46,7 +46,7 @@ func main() {
 	}
 
 	// get contract by ID if there's an active revision
-	contractResult, err := db.SelectContractActive(testContract.ID)
+	contractResult, err := db.SelectContractActive(ctx, testContract.ID)
 	if err != nil {
 		log.Error().Err(err).Msg("error selecting contract")
 	}
@@ -57,13 +57,13 @@ func main() {
 	testContract.Execution = true
 	testContract.Network = "ethereum"
 
-	err = db.UpdateContract(testContract)
+	err = db.UpdateContract(ctx, testContract)
 	if err != nil {
 		log.Error().Err(err).Msg("error updating contract")
 	}
 
 	// select all existing version for an ID
-	versions, err := db.SelectContractVersion(testContract.ID)
+	versions, err := db.SelectContractVersion(ctx, testContract.ID)
 	if err != nil {
 		log.Error().Err(err).Msg("error selecting contract version")
 	}
@@ -72,7 +72,7 @@ func main() {
 	if len(versions) > 1 {
 		wantedVersion := versions[1]
 
-		c3, errSelect := db.SelectContractInstance(testContract.ID, wantedVersion.Version)
+		c3, errSelect := db.SelectContractInstance(ctx, testContract.ID, wantedVersion.Version)
 		if errSelect != nil {
 			log.Error().Err(errSelect).Msg("error selecting contract instance")
 		}
@@ -80,8 +80,19 @@ func main() {
 		log.Printf("Contract instance: %v", c3)
 	}
 
+	// the whole timeline of the contract with the transaction of each revision
+	history, err := db.ContractHistory(ctx, testContract.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("error selecting contract history")
+	}
+
+	for _, revision := range history {
+		log.Info().Int("version", revision.Metadata.Version).Time("txTime", revision.Metadata.TxTime).
+			Str("txId", revision.Metadata.TxID).Bool("deleted", revision.Deleted()).Msg("contract revision")
+	}
+
 	// Validate if a table has redactions
-	hasRedactions, err := db.HasDataRedaction("Contract")
+	hasRedactions, err := db.HasDataRedaction(ctx, "Contract")
 	if err != nil {
 		log.Error().Err(err).Msg("error checking redactions")
 	}
@@ -41,7 +41,7 @@ func main() {
 		Execution: false,
 	}
 
-	id, err := db.InsertContractTx(testContract)
+	id, err := db.InsertContractTx(ctx, testContract)
 	if err != nil {
 		log.Error().Err(err).Msg("error inserting contract")
 	}
@@ -51,7 +51,7 @@ func main() {
 	testContract.Network = "ethereum"
 	testContract.ID = id
 
-	err = db.UpdateContract(testContract)
+	err = db.UpdateContract(ctx, testContract)
 	if err != nil {
 		log.Error().Err(err).Msg("error updating contract")
 	}
@@ -71,12 +71,12 @@ func main() {
 		Document: document,
 	}
 
-	err = db.InsertImage(image)
+	err = db.InsertImage(ctx, image)
 	if err != nil {
 		log.Error().Err(err).Msg("error inserting image")
 	}
 
-	images, err := db.GetAllImages()
+	images, err := db.GetAllImages(ctx)
 	if err != nil {
 		log.Error().Err(err).Msg("error getting all images")
 	}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/carflores-zh/qldb-go/pkg/journal"
+)
+
+// PARAM 0: directory of a downloaded journal export (the S3 prefix given to ExportJournalToS3)
+
+func main() {
+	params := os.Args[1:]
+
+	if len(params) < 1 {
+		log.Fatal().Msg("not enough params")
+	}
+
+	blocks, err := journal.ReadExport(os.DirFS(params[0]))
+	if err != nil {
+		log.Fatal().Err(err).Msg("error reading journal export")
+	}
+
+	result, err := journal.VerifyChain(blocks)
+	if err != nil {
+		log.Fatal().Err(err).Msg("journal verification failed")
+	}
+
+	log.Info().Int("blocks", result.Blocks).Int("revisions", result.Revisions).
+		Int64("first", result.First.SequenceNo).Int64("last", result.Last.SequenceNo).
+		Hex("lastBlockHash", result.LastHash).Msg("journal verified")
+
+	for table, documents := range journal.BuildHistory(blocks) {
+		log.Info().Str("table", table).Int("documents", len(documents)).Msg("exported history")
+	}
+}
@@ -0,0 +1,168 @@
+// Package ionvalue copies Ion values between readers and writers, it is shared by the packages that hash or
+// re-read parts of QLDB revisions
+package ionvalue
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+// Raw returns the value the reader is positioned on as Ion binary
+func Raw(reader ion.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writer := ion.NewBinaryWriter(&buf)
+	if err := Copy(reader, writer); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Finish(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Copy writes the value the reader is positioned on, annotations and nested values included
+func Copy(reader ion.Reader, writer ion.Writer) error {
+	annotations, err := reader.Annotations()
+	if err != nil {
+		return err
+	}
+
+	for _, annotation := range annotations {
+		if annotation.Text == nil {
+			return errors.New("annotation without text")
+		}
+
+		if err = writer.Annotation(ion.NewSymbolTokenFromString(*annotation.Text)); err != nil {
+			return err
+		}
+	}
+
+	if reader.IsNull() {
+		return writer.WriteNullType(reader.Type())
+	}
+
+	switch reader.Type() {
+	case ion.BoolType:
+		v, err := reader.BoolValue()
+		if err != nil {
+			return err
+		}
+
+		return writer.WriteBool(*v)
+	case ion.IntType:
+		v, err := reader.BigIntValue()
+		if err != nil {
+			return err
+		}
+
+		return writer.WriteBigInt(v)
+	case ion.FloatType:
+		v, err := reader.FloatValue()
+		if err != nil {
+			return err
+		}
+
+		return writer.WriteFloat(*v)
+	case ion.DecimalType:
+		v, err := reader.DecimalValue()
+		if err != nil {
+			return err
+		}
+
+		return writer.WriteDecimal(v)
+	case ion.TimestampType:
+		v, err := reader.TimestampValue()
+		if err != nil {
+			return err
+		}
+
+		return writer.WriteTimestamp(*v)
+	case ion.SymbolType:
+		v, err := reader.SymbolValue()
+		if err != nil {
+			return err
+		}
+
+		if v.Text == nil {
+			return errors.New("symbol without text")
+		}
+
+		return writer.WriteSymbolFromString(*v.Text)
+	case ion.StringType:
+		v, err := reader.StringValue()
+		if err != nil {
+			return err
+		}
+
+		return writer.WriteString(*v)
+	case ion.ClobType:
+		v, err := reader.ByteValue()
+		if err != nil {
+			return err
+		}
+
+		return writer.WriteClob(v)
+	case ion.BlobType:
+		v, err := reader.ByteValue()
+		if err != nil {
+			return err
+		}
+
+		return writer.WriteBlob(v)
+	case ion.ListType:
+		return copyContainer(reader, writer, writer.BeginList, writer.EndList)
+	case ion.SexpType:
+		return copyContainer(reader, writer, writer.BeginSexp, writer.EndSexp)
+	case ion.StructType:
+		return copyContainer(reader, writer, writer.BeginStruct, writer.EndStruct)
+	default:
+		return fmt.Errorf("unsupported ion type %v", reader.Type())
+	}
+}
+
+func copyContainer(reader ion.Reader, writer ion.Writer, begin, end func() error) error {
+	if err := reader.StepIn(); err != nil {
+		return err
+	}
+
+	if err := begin(); err != nil {
+		return err
+	}
+
+	for reader.Next() {
+		if writer.IsInStruct() {
+			name, err := reader.FieldName()
+			if err != nil {
+				return err
+			}
+
+			if name == nil || name.Text == nil {
+				return errors.New("field without name")
+			}
+
+			if err = writer.FieldName(ion.NewSymbolTokenFromString(*name.Text)); err != nil {
+				return err
+			}
+		}
+
+		if err := Copy(reader, writer); err != nil {
+			return err
+		}
+	}
+
+	if reader.Err() != nil {
+		return reader.Err()
+	}
+
+	if err := end(); err != nil {
+		return err
+	}
+
+	return reader.StepOut()
+}
@@ -0,0 +1,141 @@
+// Package partiql splits PartiQL statements into tokens, it is shared by the storage package and the index
+// linter of storagetest
+package partiql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Kind is the kind of a token
+type Kind int
+
+const (
+	Word        Kind = iota // keyword or identifier, Text is as written
+	String                  // 'string', Text is the unquoted value
+	QuotedIdent             // "identifier", Text is the unquoted name
+	Ion                     // `ion literal`, Text is the literal without backticks
+	Number                  // 42, 4.2, 4e2
+	Parameter               // ?
+	Punctuation             // operators, brackets, commas...
+)
+
+// Token is a lexeme of a statement, Offset is its position in runes
+type Token struct {
+	Kind   Kind
+	Text   string
+	Offset int
+}
+
+// Keyword reports whether the token is the keyword kw, PartiQL keywords are case insensitive
+func (t Token) Keyword(kw string) bool {
+	return t.Kind == Word && strings.EqualFold(t.Text, kw)
+}
+
+// Lex splits a statement into tokens, comments are skipped
+func Lex(statement string) ([]Token, error) {
+	src := []rune(statement)
+
+	var tokens []Token
+
+	for pos := 0; pos < len(src); {
+		r := src[pos]
+		start := pos
+
+		switch {
+		case unicode.IsSpace(r):
+			pos++
+		case r == '-' && pos+1 < len(src) && src[pos+1] == '-':
+			for pos < len(src) && src[pos] != '\n' {
+				pos++
+			}
+		case r == '/' && pos+1 < len(src) && src[pos+1] == '*':
+			for pos += 2; pos+1 < len(src) && !(src[pos] == '*' && src[pos+1] == '/'); pos++ {
+			}
+
+			if pos+1 >= len(src) {
+				return nil, fmt.Errorf("unterminated block comment at offset %d", start)
+			}
+
+			pos += 2
+		case r == '\'' || r == '"':
+			text, next, err := lexQuoted(src, pos)
+			if err != nil {
+				return nil, err
+			}
+
+			kind := String
+			if r == '"' {
+				kind = QuotedIdent
+			}
+
+			tokens = append(tokens, Token{Kind: kind, Text: text, Offset: start})
+			pos = next
+		case r == '`':
+			for pos++; pos < len(src) && src[pos] != '`'; pos++ {
+			}
+
+			if pos >= len(src) {
+				return nil, fmt.Errorf("unterminated Ion literal at offset %d", start)
+			}
+
+			pos++
+			tokens = append(tokens, Token{Kind: Ion, Text: string(src[start+1 : pos-1]), Offset: start})
+		case r == '_' || unicode.IsLetter(r):
+			for pos < len(src) && (src[pos] == '_' || src[pos] == '$' || unicode.IsLetter(src[pos]) || unicode.IsDigit(src[pos])) {
+				pos++
+			}
+
+			tokens = append(tokens, Token{Kind: Word, Text: string(src[start:pos]), Offset: start})
+		case unicode.IsDigit(r):
+			for pos < len(src) && (unicode.IsDigit(src[pos]) || src[pos] == '.' || src[pos] == 'e' || src[pos] == 'E') {
+				pos++
+			}
+
+			tokens = append(tokens, Token{Kind: Number, Text: string(src[start:pos]), Offset: start})
+		case r == '?':
+			pos++
+			tokens = append(tokens, Token{Kind: Parameter, Text: "?", Offset: start})
+		default:
+			pos++
+
+			// two characters operators
+			if pos < len(src) {
+				switch string([]rune{r, src[pos]}) {
+				case "<=", ">=", "<>", "!=", "<<", ">>", "||":
+					pos++
+				}
+			}
+
+			tokens = append(tokens, Token{Kind: Punctuation, Text: string(src[start:pos]), Offset: start})
+		}
+	}
+
+	return tokens, nil
+}
+
+// lexQuoted reads a quoted string or identifier where a doubled quote escapes it
+func lexQuoted(src []rune, pos int) (text string, next int, err error) {
+	quote := src[pos]
+
+	var sb strings.Builder
+
+	for i := pos + 1; i < len(src); i++ {
+		if src[i] != quote {
+			sb.WriteRune(src[i])
+			continue
+		}
+
+		if i+1 < len(src) && src[i+1] == quote {
+			sb.WriteRune(quote)
+			i++
+
+			continue
+		}
+
+		return sb.String(), i + 1, nil
+	}
+
+	return "", 0, fmt.Errorf("unterminated %s at offset %d", map[rune]string{'\'': "string", '"': "quoted identifier"}[quote], pos)
+}
@@ -0,0 +1,184 @@
+package journal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
+	"github.com/carflores-zh/qldb-go/pkg/verify"
+)
+
+// ErrChainBroken is matched by every verification failure of the journal
+var ErrChainBroken = errors.New("journal chain broken")
+
+// BlockError reports a block that fails verification, it matches ErrChainBroken
+type BlockError struct {
+	Address metadata.BlockAddress
+	Reason  string
+}
+
+func (e *BlockError) Error() string {
+	return fmt.Sprintf("%v at %s/%d: %s", ErrChainBroken, e.Address.StrandID, e.Address.SequenceNo, e.Reason)
+}
+
+func (e *BlockError) Unwrap() error {
+	return ErrChainBroken
+}
+
+// ChainResult summarizes a verified journal
+type ChainResult struct {
+	Blocks    int
+	Revisions int // revisions exported with their content, each one re-hashed
+	First     metadata.BlockAddress
+	Last      metadata.BlockAddress
+	LastHash  []byte
+}
+
+// Verify checks the hashes of the block: every revision hash against its content, the revisions and the
+// transaction info against entriesHashList, entriesHash as the Merkle root of that list and the block hash
+func (b *Block) Verify() error {
+	fail := func(format string, args ...interface{}) error {
+		return &BlockError{Address: b.BlockAddress, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	revisionHashes := make([][]byte, 0, len(b.Revisions))
+
+	for i := range b.Revisions {
+		revision := &b.Revisions[i]
+		revisionHashes = append(revisionHashes, revision.Hash)
+
+		matches, err := revision.HashMatches()
+		if err != nil {
+			return fail("revision %s: %v", revision.Metadata.ID, err)
+		}
+
+		if !matches {
+			return fail("revision %s version %d does not match its hash", revision.Metadata.ID, revision.Metadata.Version)
+		}
+	}
+
+	if len(revisionHashes) > 0 && !containsHash(b.EntriesHashList, merkleRoot(revisionHashes)) {
+		return fail("revisions hash is not in entriesHashList")
+	}
+
+	if b.transactionInfo != nil {
+		transactionInfoHash, err := verify.IonHash(b.transactionInfo)
+		if err != nil {
+			return fail("transactionInfo: %v", err)
+		}
+
+		if !containsHash(b.EntriesHashList, transactionInfoHash) {
+			return fail("transactionInfo hash is not in entriesHashList")
+		}
+	}
+
+	if !bytes.Equal(merkleRoot(b.EntriesHashList), b.EntriesHash) {
+		return fail("entriesHash does not match entriesHashList")
+	}
+
+	if !bytes.Equal(verify.Dot(b.EntriesHash, b.PreviousBlockHash), b.BlockHash) {
+		return fail("blockHash does not match entriesHash and previousBlockHash")
+	}
+
+	return nil
+}
+
+// VerifyChain verifies every block and the links between consecutive blocks of each strand.
+// An export can start anywhere in the journal, so the previousBlockHash of the first block is not checked.
+// All the failures are returned joined, every one a *BlockError
+func VerifyChain(blocks []Block) (*ChainResult, error) {
+	if len(blocks) == 0 {
+		return nil, errors.New("no blocks to verify")
+	}
+
+	sorted := append([]Block(nil), blocks...)
+	sortBlocks(sorted)
+
+	var errs []error
+
+	result := &ChainResult{Blocks: len(sorted), First: sorted[0].BlockAddress}
+
+	for i := range sorted {
+		block := &sorted[i]
+
+		if err := block.Verify(); err != nil {
+			errs = append(errs, err)
+		}
+
+		for _, revision := range block.Revisions {
+			if revision.Metadata != nil {
+				result.Revisions++
+			}
+		}
+
+		if i == 0 || sorted[i-1].BlockAddress.StrandID != block.BlockAddress.StrandID {
+			continue
+		}
+
+		previous := &sorted[i-1]
+
+		switch {
+		case block.BlockAddress.SequenceNo != previous.BlockAddress.SequenceNo+1:
+			errs = append(errs, &BlockError{
+				Address: block.BlockAddress,
+				Reason:  fmt.Sprintf("missing blocks after sequenceNo %d", previous.BlockAddress.SequenceNo),
+			})
+		case !bytes.Equal(block.PreviousBlockHash, previous.BlockHash):
+			errs = append(errs, &BlockError{Address: block.BlockAddress, Reason: "previousBlockHash does not match the previous block"})
+		}
+	}
+
+	last := sorted[len(sorted)-1]
+	result.Last = last.BlockAddress
+	result.LastHash = last.BlockHash
+
+	return result, errors.Join(errs...)
+}
+
+// merkleRoot joins the hashes pairwise, level by level, an odd hash is carried to the next level
+func merkleRoot(hashes [][]byte) []byte {
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	level := hashes
+
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+
+		for i := 0; i < len(level); i += 2 {
+			if i+1 < len(level) {
+				next = append(next, verify.Dot(level[i], level[i+1]))
+			} else {
+				next = append(next, level[i])
+			}
+		}
+
+		level = next
+	}
+
+	return level[0]
+}
+
+func containsHash(hashes [][]byte, hash []byte) bool {
+	for _, h := range hashes {
+		if bytes.Equal(h, hash) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortBlocks(blocks []Block) {
+	sort.SliceStable(blocks, func(i, j int) bool {
+		a, b := blocks[i].BlockAddress, blocks[j].BlockAddress
+		if a.StrandID != b.StrandID {
+			return a.StrandID < b.StrandID
+		}
+
+		return a.SequenceNo < b.SequenceNo
+	})
+}
@@ -0,0 +1,130 @@
+package journal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+const completedManifestSuffix = ".completed.manifest"
+
+// ErrNoExport is returned when no completed export manifest is found
+var ErrNoExport = errors.New("no completed journal export found")
+
+// Manifest is the completed manifest of an export, Keys are the S3 keys of its data files in journal order
+type Manifest struct {
+	Keys []string `ion:"keys"`
+}
+
+// ReadExport reads the blocks of every completed export in fsys, e.g. os.DirFS of a downloaded export prefix.
+// The data files are looked up by their S3 key, relative to the root of fsys or to the manifest directory.
+// Blocks present in more than one export are returned once, sorted by strand and sequence number
+func ReadExport(fsys fs.FS) ([]Block, error) {
+	var manifests []string
+
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() && strings.HasSuffix(name, completedManifestSuffix) {
+			manifests = append(manifests, name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(manifests) == 0 {
+		return nil, ErrNoExport
+	}
+
+	seen := map[string][]byte{} // block hashes by address
+
+	var blocks []Block
+
+	for _, manifestName := range manifests {
+		manifest, err := readManifest(fsys, manifestName)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", manifestName, err)
+		}
+
+		for _, key := range manifest.Keys {
+			fileBlocks, err := readDataFile(fsys, path.Dir(manifestName), key)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, block := range fileBlocks {
+				address := fmt.Sprintf("%s/%d", block.BlockAddress.StrandID, block.BlockAddress.SequenceNo)
+
+				if hash, ok := seen[address]; ok {
+					if !bytes.Equal(hash, block.BlockHash) {
+						return nil, &BlockError{Address: block.BlockAddress, Reason: "exported twice with different hashes"}
+					}
+
+					continue
+				}
+
+				blocks = append(blocks, block)
+				seen[address] = block.BlockHash
+			}
+		}
+	}
+
+	sortBlocks(blocks)
+
+	return blocks, nil
+}
+
+func readManifest(fsys fs.FS, name string) (*Manifest, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	if err = ion.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// readDataFile finds the file of an S3 key, whose bucket prefix may not have been downloaded, and reads its blocks
+func readDataFile(fsys fs.FS, manifestDir, key string) ([]Block, error) {
+	segments := strings.Split(strings.TrimPrefix(key, "/"), "/")
+
+	for i := range segments {
+		suffix := path.Join(segments[i:]...)
+
+		for _, name := range []string{suffix, path.Join(manifestDir, suffix)} {
+			file, err := fsys.Open(name)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			blocks, err := ReadBlocks(file)
+			_ = file.Close() // opened read only, nothing to flush
+
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+
+			return blocks, nil
+		}
+	}
+
+	return nil, fmt.Errorf("data file %s: %w", key, fs.ErrNotExist)
+}
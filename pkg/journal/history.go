@@ -0,0 +1,58 @@
+package journal
+
+import (
+	"sort"
+)
+
+// History is the exported revisions of every document, by table name and document ID, ordered by version
+type History map[string]map[string][]Revision
+
+// BuildHistory groups the revisions of the blocks by table and document.
+// The table of a revision comes from the transaction info of its block, revisions exported without
+// their content (system tables) are left out
+func BuildHistory(blocks []Block) History {
+	history := History{}
+
+	for i := range blocks {
+		block := &blocks[i]
+
+		for _, revision := range block.Revisions {
+			if revision.Metadata == nil || block.TransactionInfo == nil {
+				continue
+			}
+
+			document, ok := block.TransactionInfo.Documents[revision.Metadata.ID]
+			if !ok {
+				continue
+			}
+
+			if history[document.TableName] == nil {
+				history[document.TableName] = map[string][]Revision{}
+			}
+
+			history[document.TableName][revision.Metadata.ID] = append(history[document.TableName][revision.Metadata.ID], revision)
+		}
+	}
+
+	for _, documents := range history {
+		for _, revisions := range documents {
+			sort.SliceStable(revisions, func(i, j int) bool {
+				return revisions[i].Metadata.Version < revisions[j].Metadata.Version
+			})
+		}
+	}
+
+	return history
+}
+
+// Tables returns the table names of the history, sorted
+func (h History) Tables() []string {
+	tables := make([]string, 0, len(h))
+	for table := range h {
+		tables = append(tables, table)
+	}
+
+	sort.Strings(tables)
+
+	return tables
+}
@@ -0,0 +1,221 @@
+// Package journal reads QLDB journal exports (the Ion block files written by ExportJournalToS3)
+// and verifies them offline: the hashes of every block and the hash chain linking the blocks.
+package journal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/amzn/ion-go/ion"
+
+	"github.com/carflores-zh/qldb-go/pkg/internal/ionvalue"
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
+	"github.com/carflores-zh/qldb-go/pkg/verify"
+)
+
+// Block is a journal block, the unit committed by one transaction
+type Block struct {
+	BlockAddress      metadata.BlockAddress `ion:"blockAddress"`
+	TransactionID     string                `ion:"transactionId"`
+	BlockTimestamp    ion.Timestamp         `ion:"blockTimestamp"`
+	BlockHash         []byte                `ion:"blockHash"`
+	EntriesHash       []byte                `ion:"entriesHash"`
+	PreviousBlockHash []byte                `ion:"previousBlockHash"`
+	EntriesHashList   [][]byte              `ion:"entriesHashList"`
+	TransactionInfo   *TransactionInfo      `ion:"transactionInfo"`
+	Revisions         []Revision            `ion:"-"`
+
+	transactionInfo []byte // transactionInfo as exported, it is hashed as is
+}
+
+// TransactionInfo lists the statements of the transaction and the documents they touched
+type TransactionInfo struct {
+	Statements []Statement              `ion:"statements"`
+	Documents  map[string]*DocumentInfo `ion:"documents"` // by document ID
+}
+
+type Statement struct {
+	Statement       string        `ion:"statement"`
+	StartTime       ion.Timestamp `ion:"startTime"`
+	StatementDigest []byte        `ion:"statementDigest"`
+}
+
+type DocumentInfo struct {
+	TableName  string `ion:"tableName"`
+	TableID    string `ion:"tableId"`
+	Statements []int  `ion:"statements"` // indexes in TransactionInfo.Statements
+}
+
+// Revision is a document revision committed in a block.
+// Only its hash is exported for revisions outside the user tables, Metadata is nil for those
+type Revision struct {
+	Hash     []byte            `ion:"hash"`
+	DataHash []byte            `ion:"dataHash"` // set instead of the data once the revision is redacted
+	Metadata *RevisionMetadata `ion:"metadata"`
+
+	raw []byte
+}
+
+type RevisionMetadata struct {
+	ID      string        `ion:"id"`
+	Version int           `ion:"version"`
+	TxTime  ion.Timestamp `ion:"txTime"`
+	TxID    string        `ion:"txId"`
+}
+
+// ParseRevision parses a revision from its Ion, text or binary
+func ParseRevision(raw []byte) (*Revision, error) {
+	revision := &Revision{raw: raw}
+	if err := ion.Unmarshal(raw, revision); err != nil {
+		return nil, err
+	}
+
+	return revision, nil
+}
+
+// HashMatches recomputes the revision hash from its content, it is always true for a revision exported without content
+func (r *Revision) HashMatches() (bool, error) {
+	if r.Metadata == nil {
+		return true, nil
+	}
+
+	computed, err := verify.RevisionHash(r.raw)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(computed, r.Hash), nil
+}
+
+// Unmarshal decodes the revision data into v, it returns false for a redacted or deleted revision
+func (r *Revision) Unmarshal(v interface{}) (bool, error) {
+	data, found, err := structField(r.raw, "data")
+	if err != nil || !found {
+		return false, err
+	}
+
+	return true, ion.Unmarshal(data, v)
+}
+
+// ReadBlocks reads the blocks of an export data file
+func ReadBlocks(r io.Reader) ([]Block, error) {
+	var blocks []Block
+
+	reader := ion.NewReader(r)
+
+	for reader.Next() {
+		raw, err := ionvalue.Raw(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		block, err := parseBlock(raw)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", len(blocks), err)
+		}
+
+		blocks = append(blocks, *block)
+	}
+
+	if reader.Err() != nil {
+		return nil, reader.Err()
+	}
+
+	return blocks, nil
+}
+
+func parseBlock(raw []byte) (*Block, error) {
+	block := &Block{}
+	if err := ion.Unmarshal(raw, block); err != nil {
+		return nil, err
+	}
+
+	reader := ion.NewReaderBytes(raw)
+	if !reader.Next() || reader.Type() != ion.StructType {
+		return nil, errors.New("block is not a struct")
+	}
+
+	if err := reader.StepIn(); err != nil {
+		return nil, err
+	}
+
+	for reader.Next() {
+		name, err := reader.FieldName()
+		if err != nil {
+			return nil, err
+		}
+
+		if name == nil || name.Text == nil || reader.IsNull() {
+			continue
+		}
+
+		switch *name.Text {
+		case "transactionInfo":
+			if block.transactionInfo, err = ionvalue.Raw(reader); err != nil {
+				return nil, err
+			}
+		case "revisions":
+			if block.Revisions, err = parseRevisions(reader); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return block, reader.Err()
+}
+
+func parseRevisions(reader ion.Reader) ([]Revision, error) {
+	if err := reader.StepIn(); err != nil {
+		return nil, err
+	}
+
+	var revisions []Revision
+
+	for reader.Next() {
+		raw, err := ionvalue.Raw(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		revision, err := ParseRevision(raw)
+		if err != nil {
+			return nil, fmt.Errorf("revision %d: %w", len(revisions), err)
+		}
+
+		revisions = append(revisions, *revision)
+	}
+
+	if reader.Err() != nil {
+		return nil, reader.Err()
+	}
+
+	return revisions, reader.StepOut()
+}
+
+// structField returns the field name of the struct raw as Ion binary
+func structField(raw []byte, name string) ([]byte, bool, error) {
+	reader := ion.NewReaderBytes(raw)
+	if !reader.Next() || reader.Type() != ion.StructType {
+		return nil, false, reader.Err()
+	}
+
+	if err := reader.StepIn(); err != nil {
+		return nil, false, err
+	}
+
+	for reader.Next() {
+		field, err := reader.FieldName()
+		if err != nil {
+			return nil, false, err
+		}
+
+		if field != nil && field.Text != nil && *field.Text == name {
+			value, err := ionvalue.Raw(reader)
+			return value, err == nil, err
+		}
+	}
+
+	return nil, false, reader.Err()
+}
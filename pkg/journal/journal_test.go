@@ -0,0 +1,153 @@
+package journal
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readTestExport(t *testing.T) []Block {
+	t.Helper()
+
+	blocks, err := ReadExport(os.DirFS("testdata/export"))
+	require.NoError(t, err)
+	require.Len(t, blocks, 4)
+
+	return blocks
+}
+
+func TestReadExport(t *testing.T) {
+	blocks := readTestExport(t)
+
+	for i, block := range blocks {
+		assert.Equal(t, int64(i+1), block.BlockAddress.SequenceNo)
+	}
+
+	assert.Equal(t, "CREATE TABLE Contract", blocks[0].TransactionInfo.Statements[0].Statement)
+	assert.Nil(t, blocks[0].Revisions[0].Metadata, "system revisions only carry their hash")
+	assert.Equal(t, "Contract", blocks[1].TransactionInfo.Documents["8F0TPCmdNQ6JTRpiLj2TmW"].TableName)
+
+	_, err := ReadExport(fstest.MapFS{})
+	assert.ErrorIs(t, err, ErrNoExport)
+}
+
+func TestReadExport_Duplicates(t *testing.T) {
+	data, err := os.ReadFile("testdata/export/2023/03/01/10/JdxjkR9bSYB5jMHWcI464T.1-2.ion")
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{
+		"a.completed.manifest": {Data: []byte(`{keys:["blocks.ion"]}`)},
+		"b.completed.manifest": {Data: []byte(`{keys:["blocks.ion"]}`)},
+		"blocks.ion":           {Data: data},
+	}
+
+	blocks, err := ReadExport(fsys)
+	require.NoError(t, err)
+	assert.Len(t, blocks, 2, "blocks exported twice are read once")
+
+	fsys["missing.completed.manifest"] = &fstest.MapFile{Data: []byte(`{keys:["other.ion"]}`)}
+
+	_, err = ReadExport(fsys)
+	assert.ErrorContains(t, err, "other.ion")
+}
+
+func TestVerifyChain(t *testing.T) {
+	blocks := readTestExport(t)
+
+	result, err := VerifyChain(blocks)
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.Blocks)
+	assert.Equal(t, 4, result.Revisions)
+	assert.Equal(t, int64(1), result.First.SequenceNo)
+	assert.Equal(t, int64(4), result.Last.SequenceNo)
+	assert.Equal(t, blocks[3].BlockHash, result.LastHash)
+}
+
+func TestVerifyChain_Tampered(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(blocks []Block) []Block
+		failed []int64 // sequence numbers of the failing blocks
+	}{
+		{
+			name: "revision data",
+			tamper: func(blocks []Block) []Block {
+				blocks[1].Revisions[0].raw = bytes.Replace(blocks[1].Revisions[0].raw, []byte("ethereum"), []byte("polygonx"), 1)
+				return blocks
+			},
+			failed: []int64{2},
+		},
+		{
+			name: "transaction info",
+			tamper: func(blocks []Block) []Block {
+				blocks[2].transactionInfo = bytes.Replace(blocks[2].transactionInfo, []byte("UPDATE"), []byte("update"), 1)
+				return blocks
+			},
+			failed: []int64{3},
+		},
+		{
+			name: "block hash",
+			tamper: func(blocks []Block) []Block {
+				blocks[1].BlockHash = blocks[0].BlockHash
+				return blocks
+			},
+			failed: []int64{2, 3},
+		},
+		{
+			name: "missing block",
+			tamper: func(blocks []Block) []Block {
+				return append(blocks[:2], blocks[3])
+			},
+			failed: []int64{4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := VerifyChain(tt.tamper(readTestExport(t)))
+			require.ErrorIs(t, err, ErrChainBroken)
+
+			var failed []int64
+
+			for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+				var blockErr *BlockError
+				require.True(t, errors.As(e, &blockErr))
+
+				failed = append(failed, blockErr.Address.SequenceNo)
+			}
+
+			assert.Equal(t, tt.failed, failed)
+		})
+	}
+}
+
+func TestBuildHistory(t *testing.T) {
+	history := BuildHistory(readTestExport(t))
+	assert.Equal(t, []string{"Contract", "ControlRecord"}, history.Tables())
+
+	contract := history["Contract"]["8F0TPCmdNQ6JTRpiLj2TmW"]
+	require.Len(t, contract, 2)
+
+	var data struct {
+		ID      string `ion:"id"`
+		Network string `ion:"network"`
+	}
+
+	found, err := contract[1].Unmarshal(&data)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "8F0TPCmdNQ6JTRpiLj2TmW", data.ID)
+	assert.Equal(t, "ethereum", data.Network)
+
+	record := history["ControlRecord"]["5PLf9SXwndd63lPaSIa0O6"]
+	require.Len(t, record, 2)
+
+	found, err = record[1].Unmarshal(&data)
+	require.NoError(t, err)
+	assert.False(t, found, "the delete revision has no data")
+}
@@ -1,10 +1,14 @@
 package metadata
 
+import "time"
+
 // HistoryMetadata is the metadata of the document in QLDB (record from a table)
 type HistoryMetadata struct {
-	ID       string `ion:"id"`
-	Version  int    `ion:"version"`
-	DataHash []byte `ion:"dataHash"`
+	ID       string    `ion:"id"`
+	Version  int       `ion:"version"`
+	DataHash []byte    `ion:"dataHash"`
+	TxTime   time.Time `ion:"txTime"` // commit time of the revision
+	TxID     string    `ion:"txId"`
 }
 
 // Result is the result of the insert/update
@@ -25,3 +29,25 @@ type ImageAttestation struct {
 	Signature string `ion:"signature"`
 	Hash      string `ion:"hash"`
 }
+
+// BlockAddress is the location of a revision in the journal
+type BlockAddress struct {
+	StrandID   string `ion:"strandId"`
+	SequenceNo int64  `ion:"sequenceNo"`
+}
+
+// UserTable is a table as listed by information_schema.user_tables, Status is ACTIVE or INACTIVE once dropped
+type UserTable struct {
+	Name    string           `ion:"name"`
+	TableID string           `ion:"tableId"`
+	Status  string           `ion:"status"`
+	Indexes []UserTableIndex `ion:"indexes"`
+}
+
+// UserTableIndex is an index of a user table, Status is BUILDING, FINALIZING, ONLINE, FAILED or DELETING
+type UserTableIndex struct {
+	IndexID string `ion:"indexId"`
+	Expr    string `ion:"expr"` // indexed path in brackets, e.g. [id]
+	Status  string `ion:"status"`
+	Message string `ion:"message"` // why the build failed
+}
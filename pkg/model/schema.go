@@ -0,0 +1,21 @@
+package model
+
+// Table declares a ledger table and the document stored in it.
+// The indexed fields of the document are tagged qldb:"index", the index path is the Ion name of the field
+type Table struct {
+	Name     string
+	Document interface{} // zero value of the document struct, nil for a table without a model
+}
+
+// Tables is the schema of the ledger, cmd/generate-migration writes the migration that takes the
+// migration files in /sql to it. MigrationLock is not listed, the migrator creates it before migrating
+var Tables = []Table{
+	{Name: "Migration", Document: Migration{}},
+	{Name: "TransactionLog", Document: TransactionLog{}},
+	{Name: "Signer", Document: Signer{}},
+	{Name: "ControlRecord", Document: Control{}},
+	{Name: "Contract", Document: Contract{}},
+	{Name: "Share", Document: Share{}},
+	{Name: "Image", Document: Image{}},
+	{Name: "TheHistory"}, // revision history example
+}
@@ -7,9 +7,30 @@ import (
 
 // TODO: WIP all the structs in here represent tables in QLDB
 
+// Migration records a migration run, Version is the version the ledger is at after it.
+// Records written before checksums were introduced only have Version and MigratedAt,
+// records written before sequences were introduced are ordered by MigratedAt
 type Migration struct {
 	Version    int       `ion:"version"`
 	MigratedAt time.Time `ion:"migratedAt"`
+	Direction  string    `ion:"direction,omitempty"`  // up, down, baseline or force
+	Checksum   string    `ion:"checksum,omitempty"`   // SHA-256 of the statements of the file that ran
+	DurationMs int64     `ion:"durationMs,omitempty"` // time spent executing the statements
+	Principal  string    `ion:"principal,omitempty"`  // who ran the migration, e.g. an IAM ARN
+	Source     string    `ion:"source,omitempty"`     // file or Go migration that ran, e.g. up/2-migration.sql
+	Sequence   int64     `ion:"sequence,omitempty"`   // position of the record, one more than the highest recorded before it
+	Status     string    `ion:"status,omitempty"`     // failed when the run was rolled back, empty when it applied
+	Error      string    `ion:"error,omitempty"`      // why the run failed
+}
+
+// MigrationLock is the advisory lock a migrator holds while it migrates the ledger, there is at most one document.
+// Token identifies the holder, a lock past ExpiresAt is stale and can be taken over
+type MigrationLock struct {
+	ID         string    `ion:"id"`
+	Owner      string    `ion:"owner"`
+	Token      string    `ion:"token"`
+	AcquiredAt time.Time `ion:"acquiredAt"`
+	ExpiresAt  time.Time `ion:"expiresAt"`
 }
 
 // Control represents the proposed control record table
@@ -19,10 +40,10 @@ type Control struct {
 	ID              string          `ion:"id"`         // Document ID: same used to get history (unique)
 	Signature1      []byte          `ion:"signature1"` // TODO: this is a byte slice, represents the signature of the document (hash)
 	Signature2      []byte          `ion:"signature2"`
-	Table           string          `ion:"table"`           // Table name of the table/record we are signing
-	DocumentID      string          `ion:"documentId"`      // Document ID of the table/record we are signing
-	Version         int             `ion:"version"`         // Version of the table/record we are signing
-	ControlDocument ControlDocument `ion:"controlDocument"` // TODO: This is the document that actually needs to be signed by the admins
+	Table           string          `ion:"table"`                   // Table name of the table/record we are signing
+	DocumentID      string          `ion:"documentId" qldb:"index"` // Document ID of the table/record we are signing
+	Version         int             `ion:"version"`                 // Version of the table/record we are signing
+	ControlDocument ControlDocument `ion:"controlDocument"`         // TODO: This is the document that actually needs to be signed by the admins
 }
 
 // ControlDocument is document to sign to insert in control
@@ -34,8 +55,8 @@ type ControlDocument struct {
 
 // Contract represents a whitelisted contract
 type Contract struct {
-	ID        string `ion:"id"` // Document ID: same used to get history (unique)
-	Address   string `ion:"address"`
+	ID        string `ion:"id" qldb:"index"` // Document ID: same used to get history (unique)
+	Address   string `ion:"address" qldb:"index"`
 	Input     string `ion:"input"`
 	Output    string `ion:"output"`
 	Network   string `ion:"network"`
@@ -63,8 +84,8 @@ type PrivateKey struct {
 // Image represents an enclave image, accepted and signed by the admins
 // TODO: Can be signed in here or in Control table?
 type Image struct {
-	ID         string    `ion:"id"` // Document ID: same used to get history (unique)
-	ImageID    string    `ion:"imageId"`
+	ID         string    `ion:"id" qldb:"index"` // Document ID: same used to get history (unique)
+	ImageID    string    `ion:"imageId" qldb:"index"`
 	Document   []byte    `ion:"document"` // TODO: this one should be something similar to the attestation document
 	Signature1 []byte    `ion:"signature1"`
 	Signature2 []byte    `ion:"signature2"`
@@ -76,10 +97,10 @@ type Image struct {
 // TODO: trying to make it as generic as possible, it will work as a log, doesn't need signatures
 type TransactionLog struct {
 	ID    string   `ion:"id"` // Document ID: same used to get history (unique)
-	TxID  string   `ion:"txID"`
+	TxID  string   `ion:"txID" qldb:"index"`
 	Nonce uint64   `ion:"nonce"`
 	Fee   *big.Int `ion:"Fee"`
-	To    string   `ion:"to"`
+	To    string   `ion:"to" qldb:"index"`
 	From  string   `ion:"from"`
 	Value *big.Int `ion:"value"`
 	Data  []byte   `ion:"data"`
@@ -90,7 +111,7 @@ type TransactionLog struct {
 // TODO: just as a log, doesn't need signatures, or private info in here
 type Signer struct {
 	ID            string `ion:"id"` // Document ID: same used to get history (unique)
-	PublicAddress string `ion:"publicAddress"`
+	PublicAddress string `ion:"publicAddress" qldb:"index"`
 	Type          string `ion:"type"`
 	CreatedAt     string `ion:"createdAt"`
 }
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+)
+
+// SelectAsOf returns the revision of the document id of tableName that was current at, the latest one
+// committed at or before at. It fails with a NotFoundError if the document didn't exist or was deleted at
+// that time, and with ErrRedacted, along with the revision, if its data was redacted since
+func SelectAsOf[T any](ctx context.Context, driver QLDBDriver, tableName string, id string, at time.Time) (Revision[T], error) {
+	revisions, err := SelectHistory[T](ctx, driver, tableName, id, HistoryUntil(at))
+	if err != nil {
+		return Revision[T]{}, err
+	}
+
+	if len(revisions) == 0 || revisions[len(revisions)-1].Deleted() {
+		return Revision[T]{}, &NotFoundError{Table: tableName, ID: id}
+	}
+
+	revision := revisions[len(revisions)-1]
+	if revision.Redacted() {
+		return revision, fmt.Errorf("%s %q version %d: %w", tableName, id, revision.Metadata.Version, ErrRedacted)
+	}
+
+	return revision, nil
+}
+
+// SelectTableAsOf returns the revisions of the documents of tableName that were current at, sorted by
+// document id. The documents deleted at that time are left out, the redacted ones are kept with their
+// dataHash. It reads the whole history of the table up to at
+func SelectTableAsOf[T any](ctx context.Context, driver QLDBDriver, tableName string, at time.Time) ([]Revision[T], error) {
+	source, err := historySource(tableName, HistoryUntil(at))
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := Execute(ctx, driver, func(txn qldbdriver.Transaction) ([]Revision[T], error) {
+		// qldb:scan the current revision of every document is only known after reading them all
+		return QueryMany[Revision[T]](ctx, txn, fmt.Sprintf("SELECT * FROM %s AS h", source))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	latest := map[string]Revision[T]{}
+
+	for _, revision := range revisions {
+		if current, ok := latest[revision.Metadata.ID]; !ok || revision.Metadata.Version > current.Metadata.Version {
+			latest[revision.Metadata.ID] = revision
+		}
+	}
+
+	current := make([]Revision[T], 0, len(latest))
+
+	for _, revision := range latest {
+		if !revision.Deleted() {
+			current = append(current, revision)
+		}
+	}
+
+	sort.Slice(current, func(i, j int) bool {
+		return current[i].Metadata.ID < current[j].Metadata.ID
+	})
+
+	return current, nil
+}
+
+// ContractAsOf returns the contract id as it was at, see SelectAsOf
+func (db *DB) ContractAsOf(ctx context.Context, id string, at time.Time) (Revision[model.Contract], error) {
+	return SelectAsOf[model.Contract](ctx, db.Driver, "Contract", id, at)
+}
+
+// ContractsAsOf returns the contracts as they were at, see SelectTableAsOf
+func (db *DB) ContractsAsOf(ctx context.Context, at time.Time) ([]Revision[model.Contract], error) {
+	return SelectTableAsOf[model.Contract](ctx, db.Driver, "Contract", at)
+}
+
+// ImageAsOf returns the enclave image id as it was at, see SelectAsOf
+func (db *DB) ImageAsOf(ctx context.Context, id string, at time.Time) (Revision[model.Image], error) {
+	return SelectAsOf[model.Image](ctx, db.Driver, "Image", id, at)
+}
+
+// ImagesAsOf returns the enclave images registered at, the whitelist as it was then, see SelectTableAsOf
+func (db *DB) ImagesAsOf(ctx context.Context, at time.Time) ([]Revision[model.Image], error) {
+	return SelectTableAsOf[model.Image](ctx, db.Driver, "Image", at)
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+	"github.com/carflores-zh/qldb-go/pkg/storage/fake"
+	"github.com/carflores-zh/qldb-go/pkg/storage/mocks"
+	"github.com/carflores-zh/qldb-go/sql"
+)
+
+func TestDB_AsOf(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	db := &DB{Driver: fake.NewDriver(fake.WithClock(func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	})), LedgerName: "test"}
+	require.NoError(t, (&DBMigrator{DB: db}).MigrateQLDB(ctx, sql.FS, 1))
+
+	beforeInserts := now
+
+	first := &model.Contract{Address: "0x1", Network: "ethereum"}
+	firstID, err := db.InsertContractTx(ctx, first)
+	require.NoError(t, err)
+
+	inserted := now
+
+	first.Network = "polygon"
+	require.NoError(t, db.UpdateContract(ctx, first))
+
+	updated := now
+
+	second := &model.Contract{Address: "0x2", Network: "ethereum"}
+	secondID, err := db.InsertContractTx(ctx, second)
+	require.NoError(t, err)
+
+	image := &model.Image{ImageID: "enclave-1", Signature1: []byte{1}}
+	require.NoError(t, db.InsertImage(ctx, image))
+
+	_, err = Execute(ctx, db.Driver, func(txn qldbdriver.Transaction) (qldbdriver.Result, error) {
+		return txn.Execute("DELETE FROM Contract AS c WHERE c.id = ?", firstID)
+	})
+	require.NoError(t, err)
+
+	deleted := now
+
+	_, err = db.ContractAsOf(ctx, firstID, beforeInserts)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	revision, err := db.ContractAsOf(ctx, firstID, inserted)
+	require.NoError(t, err)
+	assert.Equal(t, 0, revision.Metadata.Version)
+	assert.Equal(t, "ethereum", revision.Data.Network)
+
+	// between two revisions the earlier one is current
+	revision, err = db.ContractAsOf(ctx, firstID, updated.Add(30*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, revision.Metadata.Version)
+	assert.Equal(t, "polygon", revision.Data.Network)
+
+	_, err = db.ContractAsOf(ctx, firstID, deleted)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	contracts, err := db.ContractsAsOf(ctx, beforeInserts)
+	require.NoError(t, err)
+	assert.Empty(t, contracts)
+
+	contracts, err = db.ContractsAsOf(ctx, deleted.Add(-time.Minute))
+	require.NoError(t, err)
+	require.Len(t, contracts, 2)
+
+	byID := map[string]model.Contract{}
+	for _, contract := range contracts {
+		byID[contract.Metadata.ID] = *contract.Data
+	}
+
+	assert.Equal(t, map[string]model.Contract{
+		firstID:  {ID: firstID, Address: "0x1", Network: "polygon"},
+		secondID: {ID: secondID, Address: "0x2", Network: "ethereum"},
+	}, byID)
+
+	contracts, err = db.ContractsAsOf(ctx, deleted)
+	require.NoError(t, err)
+	require.Len(t, contracts, 1)
+	assert.Equal(t, secondID, contracts[0].Data.ID)
+
+	images, err := db.ImagesAsOf(ctx, inserted)
+	require.NoError(t, err)
+	assert.Empty(t, images)
+
+	images, err = db.ImagesAsOf(ctx, deleted)
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+	assert.Equal(t, *image, *images[0].Data)
+
+	imageRevision, err := db.ImageAsOf(ctx, image.ID, deleted)
+	require.NoError(t, err)
+	assert.Equal(t, "enclave-1", imageRevision.Data.ImageID)
+
+	_, err = SelectTableAsOf[model.Contract](ctx, db.Driver, "Contract AS c", deleted)
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+}
+
+func TestDB_ContractAsOf_Redacted(t *testing.T) {
+	mDriver := mocks.NewMockQLDBDriver()
+
+	redacted := strings.Replace(contractRevision,
+		`data:{address:"0x1",id:"QAMMHTXhu2zerDt2mcGGzy",network:"ethereum",sendFunds:true}`,
+		`dataHash:{{RN197nx8XG2bpgEGVETksn5DoXV2Yelx31AKSRXgrW8=}}`, 1)
+
+	result := &mocks.MockResult{}
+	result.On("Next", mock.Anything).Return(true).Once()
+	result.On("Next", mock.Anything).Return(false).Once()
+	result.On("GetCurrentData").Return([]byte(redacted)).Once()
+	result.On("Err").Return(nil)
+
+	mDriver.Txn.On("Execute",
+		"SELECT * FROM history(Contract, `1970-01-01T00:00:00Z`, `2023-03-01T10:05:00Z`) AS h WHERE h.metadata.id = ?",
+		[]interface{}{"QAMMHTXhu2zerDt2mcGGzy"}).Return(result, nil)
+
+	db := &DB{Driver: mDriver}
+
+	revision, err := db.ContractAsOf(context.Background(), "QAMMHTXhu2zerDt2mcGGzy", time.Date(2023, 3, 1, 10, 5, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, ErrRedacted)
+	assert.True(t, revision.Redacted())
+	assert.Nil(t, revision.Data)
+	assert.Equal(t, 1, revision.Metadata.Version)
+}
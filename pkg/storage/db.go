@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/amzn/ion-go/ion"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/qldb"
 	"github.com/aws/aws-sdk-go-v2/service/qldbsession"
@@ -15,15 +14,27 @@ import (
 	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
 )
 
-const waitForTables = 1 * time.Second
 const upMigration = "up"
 const downMigration = "down"
 
 type Store interface {
-	InsertTx(tx *model.TransactionLog) error
-	SelectContractVersion(id string) ([]metadata.HistoryMetadata, error)
-	HasDataRedaction(tableName string) (bool, error)
-	SelectContractInstance(id string, version int) ([]model.Contract, error)
+	InsertTx(ctx context.Context, tx *model.TransactionLog) error
+	SelectContractVersion(ctx context.Context, id string) ([]metadata.HistoryMetadata, error)
+	HasDataRedaction(ctx context.Context, tableName string) (bool, error)
+	SelectContractInstance(ctx context.Context, id string, version int) ([]model.Contract, error)
+	ContractHistory(ctx context.Context, id string, opts ...HistoryOption) ([]Revision[model.Contract], error)
+	ContractAsOf(ctx context.Context, id string, at time.Time) (Revision[model.Contract], error)
+	ContractsAsOf(ctx context.Context, at time.Time) ([]Revision[model.Contract], error)
+	SelectRevisionAddress(ctx context.Context, tableName string, id string, version int) (metadata.BlockAddress, error)
+	CheckRevisionHashes(ctx context.Context, tableName string, id string) ([]RevisionHashCheck, error)
+	SelectContractActive(ctx context.Context, id string) ([]model.Contract, error)
+	InsertContractTx(ctx context.Context, contract *model.Contract) (string, error)
+	UpdateContract(ctx context.Context, contract *model.Contract) error
+	QueryTransactions(ctx context.Context) (int, []model.TransactionLog, error)
+	InsertImage(ctx context.Context, image *model.Image) error
+	GetAllImages(ctx context.Context) ([]model.Image, error)
+	ImageAsOf(ctx context.Context, id string, at time.Time) (Revision[model.Image], error)
+	ImagesAsOf(ctx context.Context, at time.Time) ([]Revision[model.Image], error)
 }
 
 type QLDBDriver interface {
@@ -64,50 +75,99 @@ type DB struct {
 
 type DBMigrator struct {
 	*DB
-	Client *qldb.Client
+	Client QLDBClient
+	Policy *MigrationPolicy // statements allowed in the migration files, nil for DefaultMigrationPolicy
+
+	// GoMigrations run with the SQL files, ordered by version
+	GoMigrations []GoMigration
+
+	// Principal is recorded with every migration, empty records the OS user running the migrator
+	Principal string
+	// IgnoreDrift logs applied migrations whose files changed instead of refusing to migrate
+	IgnoreDrift bool
+	// ReadyTimeout is how long a migration waits for its tables and indexes to be ready, 0 for 5 minutes
+	ReadyTimeout time.Duration
+	// LockTTL is how long MigrateQLDB holds the migration lock before another migrator can take it over, 0 for 30 minutes
+	LockTTL time.Duration
 }
 
-func New(cfg aws.Config, ledgerName string) (ds *DB, err error) {
+// New creates a DB connected to ledgerName, opts override the session and driver defaults
+func New(cfg aws.Config, ledgerName string, opts ...Option) (ds *DB, err error) {
+	o := newOptions(opts...)
+
 	qldbSession := qldbsession.NewFromConfig(cfg, func(options *qldbsession.Options) {
 		options.Region = cfg.Region
-		options.RetryMaxAttempts = 3
+		options.RetryMaxAttempts = o.retryMaxAttempts
+
+		if o.endpoint != "" {
+			options.EndpointResolver = qldbsession.EndpointResolverFromURL(o.endpoint)
+		}
 	})
 
 	qldbDriver, err := qldbdriver.New(
 		ledgerName,
 		qldbSession,
 		func(options *qldbdriver.DriverOptions) {
-			options.LoggerVerbosity = qldbdriver.LogInfo
+			options.LoggerVerbosity = o.loggerVerbosity
+
+			if o.maxConcurrentTransactions > 0 {
+				options.MaxConcurrentTransactions = o.maxConcurrentTransactions
+			}
+
+			if o.logger != nil {
+				options.Logger = o.logger
+			}
+
+			if o.retryPolicy != nil {
+				options.RetryPolicy = *o.retryPolicy
+			}
 		})
+	if err != nil {
+		return nil, err
+	}
 
 	store := &DB{
-		Driver: qldbDriver,
+		Driver:     qldbDriver,
+		LedgerName: ledgerName,
 	}
 
-	return store, err
+	return store, nil
 }
 
-func NewMigrator(cfg aws.Config, ledgerName string) (ds *DBMigrator, err error) {
+// NewMigrator creates a DBMigrator, opts apply to both the driver and the control plane client
+func NewMigrator(cfg aws.Config, ledgerName string, opts ...Option) (ds *DBMigrator, err error) {
+	o := newOptions(opts...)
+
 	qldbClient := qldb.NewFromConfig(cfg, func(options *qldb.Options) {
 		options.Region = cfg.Region
-		options.RetryMaxAttempts = 3
+		options.RetryMaxAttempts = o.retryMaxAttempts
+
+		if o.endpoint != "" {
+			options.EndpointResolver = qldb.EndpointResolverFromURL(o.endpoint)
+		}
 	})
 
-	store, err := New(cfg, ledgerName)
+	store, err := New(cfg, ledgerName, opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	storeMigrator := &DBMigrator{
-		DB:     store,
-		Client: qldbClient,
+		DB:           store,
+		Client:       qldbClient,
+		Policy:       o.migrationPolicy,
+		GoMigrations: o.goMigrations,
+		Principal:    o.principal,
+		IgnoreDrift:  o.ignoreDrift,
+		LockTTL:      o.lockTTL,
+		ReadyTimeout: o.readyTimeout,
 	}
 
-	return storeMigrator, err
+	return storeMigrator, nil
 }
 
-func (db *DB) InsertTx(tx *model.TransactionLog) error {
-	_, err := db.Driver.Execute(context.Background(), func(txn qldbdriver.Transaction) (interface{}, error) {
+func (db *DB) InsertTx(ctx context.Context, tx *model.TransactionLog) error {
+	_, err := Execute(ctx, db.Driver, func(txn qldbdriver.Transaction) (qldbdriver.Result, error) {
 		return txn.Execute("INSERT INTO TransactionLog ?", tx)
 	})
 	if err != nil {
@@ -117,66 +177,25 @@ func (db *DB) InsertTx(tx *model.TransactionLog) error {
 	return nil
 }
 
-func (db *DB) SelectContractVersion(id string) (resultMetadata []metadata.HistoryMetadata, err error) {
-	c, err := db.Driver.Execute(context.Background(), func(txn qldbdriver.Transaction) (interface{}, error) {
-		result, errTxn := txn.Execute("SELECT metadata.version from history(Contract) where data.id = ?", id)
-		if errTxn != nil {
-			return nil, errTxn
-		}
-
-		var versions []metadata.HistoryMetadata
-		for result.Next(txn) {
-			temp := new(metadata.HistoryMetadata)
-			err = ion.Unmarshal(result.GetCurrentData(), temp)
-			if err != nil {
-				return nil, err
-			}
-
-			versions = append(versions, *temp)
-		}
-		if result.Err() != nil {
-			return nil, result.Err()
-		}
-
-		return versions, nil
+func (db *DB) SelectContractVersion(ctx context.Context, id string) ([]metadata.HistoryMetadata, error) {
+	return Execute(ctx, db.Driver, func(txn qldbdriver.Transaction) ([]metadata.HistoryMetadata, error) {
+		return QueryMany[metadata.HistoryMetadata](ctx, txn, "SELECT h.metadata.version FROM history(Contract) AS h WHERE h.metadata.id = ?", id)
 	})
-	if err != nil {
-		return nil, err
-	}
-
-	resultMetadata = c.([]metadata.HistoryMetadata)
-
-	return resultMetadata, nil
 }
 
 // HasDataRedaction If we get datahashes this means someone has redacted the data
-func (db *DB) HasDataRedaction(tableName string) (bool, error) {
-	result, err := db.Driver.Execute(context.Background(), func(txn qldbdriver.Transaction) (interface{}, error) {
-		countSelect, err := txn.Execute(fmt.Sprintf("SELECT count(dataHash) as countHashes from history(%s)", tableName))
-		if err != nil {
-			return nil, err
-		}
+func (db *DB) HasDataRedaction(ctx context.Context, tableName string) (bool, error) {
+	resultRedaction, err := Execute(ctx, db.Driver, func(txn qldbdriver.Transaction) (metadata.ResponseHasDataRedaction, error) {
+		count, _, err := QueryOne[metadata.ResponseHasDataRedaction](ctx, txn,
+			// qldb:scan counts the redacted revisions of the whole history
+			fmt.Sprintf("SELECT count(dataHash) as countHashes from history(%s)", tableName))
 
-		countSelect.Next(txn)
-
-		temp := new(metadata.ResponseHasDataRedaction)
-		err = ion.Unmarshal(countSelect.GetCurrentData(), temp)
-		if err != nil {
-			return nil, err
-		}
-
-		if countSelect.Err() != nil {
-			return nil, countSelect.Err()
-		}
-
-		return temp, nil
+		return count, err
 	})
 	if err != nil {
 		return false, err
 	}
 
-	resultRedaction := result.(*metadata.ResponseHasDataRedaction)
-
 	if resultRedaction.CountHashes > 0 {
 		return true, nil
 	}
@@ -184,132 +203,103 @@ func (db *DB) HasDataRedaction(tableName string) (bool, error) {
 	return false, nil
 }
 
-func (db *DB) SelectContractInstance(id string, version int) ([]model.Contract, error) {
-	var contracts []model.Contract
+func (db *DB) SelectContractInstance(ctx context.Context, id string, version int) ([]model.Contract, error) {
+	return Execute(ctx, db.Driver, func(txn qldbdriver.Transaction) ([]model.Contract, error) {
+		return QueryMany[model.Contract](ctx, txn,
+			"SELECT h.data.* FROM history(Contract) AS h WHERE h.metadata.id = ? AND h.metadata.version = ?", id, version)
+	})
+}
 
-	c, err := db.Driver.Execute(context.Background(), func(txn qldbdriver.Transaction) (interface{}, error) {
-		result, err := txn.Execute("SELECT data.* from history(Contract) where data.id = ? AND metadata.version = ?", id, version)
-		if err != nil {
-			return nil, err
-		}
+// SelectRevisionAddress returns the block address of a document revision, it is what verify needs to request its proof
+func (db *DB) SelectRevisionAddress(ctx context.Context, tableName string, id string, version int) (metadata.BlockAddress, error) {
+	if err := validTableName(tableName); err != nil {
+		return metadata.BlockAddress{}, err
+	}
 
-		var cs []model.Contract
-		for result.Next(txn) {
-			temp := new(model.Contract)
-			err = ion.Unmarshal(result.GetCurrentData(), temp)
-			if err != nil {
-				return nil, err
-			}
+	type revision struct {
+		BlockAddress metadata.BlockAddress `ion:"blockAddress"`
+	}
 
-			cs = append(cs, *temp)
-		}
-		if result.Err() != nil {
-			return nil, result.Err()
+	found, err := Execute(ctx, db.Driver, func(txn qldbdriver.Transaction) (revision, error) {
+		r, ok, err := QueryOne[revision](ctx, txn,
+			fmt.Sprintf("SELECT h.blockAddress FROM history(%s) AS h WHERE h.metadata.id = ? AND h.metadata.version = ?", tableName), id, version)
+		if err == nil && !ok {
+			err = &NotFoundError{Table: tableName, ID: fmt.Sprintf("%s version %d", id, version)}
 		}
 
-		return cs, nil
+		return r, err
 	})
 	if err != nil {
-		return nil, err
+		return metadata.BlockAddress{}, err
 	}
 
-	contracts = c.([]model.Contract)
-
-	return contracts, nil
+	return found.BlockAddress, nil
 }
 
-func (db *DB) SelectContractActive(id string) ([]model.Contract, error) {
-	var contracts []model.Contract
-
-	c, err := db.Driver.Execute(context.Background(), func(txn qldbdriver.Transaction) (interface{}, error) {
-		result, err := txn.Execute("SELECT id FROM Contract AS c WHERE c.id = ?", id)
-		if err != nil {
-			return nil, err
-		}
-
-		var cs []model.Contract
-		for result.Next(txn) {
-			temp := new(model.Contract)
-			err = ion.Unmarshal(result.GetCurrentData(), temp)
-			if err != nil {
-				return nil, err
-			}
-
-			cs = append(cs, *temp)
-		}
-		if result.Err() != nil {
-			return nil, result.Err()
-		}
-
-		return cs, nil
+// SelectContractActive returns the current revision of a contract, or a *NotFoundError if it doesn't exist
+func (db *DB) SelectContractActive(ctx context.Context, id string) ([]model.Contract, error) {
+	contracts, err := Execute(ctx, db.Driver, func(txn qldbdriver.Transaction) ([]model.Contract, error) {
+		return QueryMany[model.Contract](ctx, txn, "SELECT id FROM Contract AS c WHERE c.id = ?", id)
 	})
-
 	if err != nil {
 		return nil, err
 	}
 
-	contracts = c.([]model.Contract)
+	if len(contracts) == 0 {
+		return nil, &NotFoundError{Table: "Contract", ID: id}
+	}
 
 	return contracts, nil
 }
 
-func (db *DB) InsertContractTx(contract *model.Contract) (id string, err error) {
-	_, err = db.Driver.Execute(context.Background(), func(txn qldbdriver.Transaction) (interface{}, error) {
-		temp := new(metadata.Result)
-		contract.ID = ""
-
-		resultContract, errContract := txn.Execute("INSERT INTO Contract ?", contract)
-		if errContract != nil {
-			return nil, err
-		}
+func (db *DB) InsertContractTx(ctx context.Context, contract *model.Contract) (string, error) {
+	insert := *contract
+	insert.ID = ""
 
-		resultContract.Next(txn)
-		err = ion.Unmarshal(resultContract.GetCurrentData(), temp)
+	id, err := Execute(ctx, db.Driver, func(txn qldbdriver.Transaction) (string, error) {
+		contractID, err := ExecReturningID(ctx, txn, "INSERT INTO Contract ?", insert)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 
-		contract.ID = temp.DocumentID
-
 		_, err = txn.Execute(
 			"UPDATE Contract AS c SET c.id = ? WHERE c.address = ? AND c.network = ?",
-			temp.DocumentID, contract.Address, contract.Network)
+			contractID, insert.Address, insert.Network)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 
 		controlRecord := model.Control{
 			Table:      "Contract",
-			DocumentID: temp.DocumentID,
+			DocumentID: contractID,
 			Version:    0,
 		}
 
-		resultControl, errControl := txn.Execute("INSERT INTO ControlRecord ?", controlRecord)
-		if errControl != nil {
-			return nil, err
-		}
-
-		resultControl.Next(txn)
-		err = ion.Unmarshal(resultControl.GetCurrentData(), temp)
+		controlID, err := ExecReturningID(ctx, txn, "INSERT INTO ControlRecord ?", controlRecord)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 
 		_, err = txn.Execute(
 			"UPDATE ControlRecord AS c SET c.id = ? WHERE c.documentId = ? AND c.version = ?",
-			temp.DocumentID, controlRecord.DocumentID, controlRecord.Version)
+			controlID, controlRecord.DocumentID, controlRecord.Version)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 
-		return nil, nil
+		return contractID, nil
 	})
+	if err != nil {
+		return "", err
+	}
+
+	contract.ID = id
 
-	return contract.ID, err
+	return id, nil
 }
 
-func (db *DB) UpdateContract(contract *model.Contract) error {
-	_, err := db.Driver.Execute(context.Background(), func(txn qldbdriver.Transaction) (interface{}, error) {
+func (db *DB) UpdateContract(ctx context.Context, contract *model.Contract) error {
+	_, err := Execute(ctx, db.Driver, func(txn qldbdriver.Transaction) (qldbdriver.Result, error) {
 		return txn.Execute("UPDATE Contract AS c SET c = ? where c.id = ?", contract, contract.ID)
 	})
 	if err != nil {
@@ -319,34 +309,13 @@ func (db *DB) UpdateContract(contract *model.Contract) error {
 	return nil
 }
 
-func (db *DB) QueryTransactions() (int, []model.TransactionLog, error) {
-	p, err := db.Driver.Execute(context.Background(), func(txn qldbdriver.Transaction) (interface{}, error) {
-		result, err := txn.Execute("SELECT * FROM TransactionLog")
-		if err != nil {
-			return nil, err
-		}
-
-		var txs []model.TransactionLog
-		for result.Next(txn) {
-			temp := new(model.TransactionLog)
-			err = ion.Unmarshal(result.GetCurrentData(), temp)
-			if err != nil {
-				return nil, err
-			}
-
-			txs = append(txs, *temp)
-		}
-		if result.Err() != nil {
-			return nil, result.Err()
-		}
-
-		return txs, nil
+func (db *DB) QueryTransactions(ctx context.Context) (int, []model.TransactionLog, error) {
+	txs, err := Execute(ctx, db.Driver, func(txn qldbdriver.Transaction) ([]model.TransactionLog, error) {
+		return QueryMany[model.TransactionLog](ctx, txn, "SELECT * FROM TransactionLog")
 	})
 	if err != nil {
 		return 0, nil, err
 	}
 
-	people := p.([]model.TransactionLog)
-
-	return len(people), people, err
+	return len(txs), txs, nil
 }
@@ -1,10 +1,16 @@
 package storage
 
 import (
+	"context"
 	"testing"
 
+	"github.com/amzn/ion-go/ion"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
+	"github.com/carflores-zh/qldb-go/pkg/storage/mocks"
 )
 
 func TestNew(t *testing.T) {
@@ -16,21 +22,66 @@ func TestNew(t *testing.T) {
 	tests := []struct {
 		name    string
 		args    args
-		wantDs  *DBMigrator
+		wantDs  *DB
 		wantErr bool
 	}{
-		{"success", args{aws.Config{}, "ledger"}, &DBMigrator{}, false},
+		{"success", args{aws.Config{}, "ledger"}, &DB{}, false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotDs, err := New(tt.args.cfg, tt.args.ledgerName)
+			gotDs, err := New(tt.args.cfg, tt.args.ledgerName, WithMaxConcurrentTransactions(5))
 			if (err != nil) != tt.wantErr {
 				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
 			assert.IsType(t, gotDs, tt.wantDs)
+			assert.Equal(t, tt.args.ledgerName, gotDs.LedgerName)
 		})
 	}
 }
+
+func TestDB_SelectContractVersion(t *testing.T) {
+	versionIon, _ := ion.MarshalBinary(metadata.HistoryMetadata{Version: 1})
+
+	t.Run("success", func(t *testing.T) {
+		mDriver := mocks.NewMockQLDBDriver()
+
+		result := &mocks.MockResult{}
+		result.On("Next", mock.Anything).Return(true).Times(2)
+		result.On("Next", mock.Anything).Return(false).Once()
+		result.On("GetCurrentData").Return(versionIon)
+		result.On("Err").Return(nil)
+
+		mDriver.Txn.On("Execute", "SELECT h.metadata.version FROM history(Contract) AS h WHERE h.metadata.id = ?", []interface{}{"c1"}).
+			Return(result, nil).Once()
+
+		db := &DB{Driver: mDriver, LedgerName: "test"}
+
+		versions, err := db.SelectContractVersion(context.Background(), "c1")
+		assert.NoError(t, err)
+		assert.Len(t, versions, 2)
+	})
+
+	t.Run("canceled-while-iterating", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDriver := mocks.NewMockQLDBDriver()
+
+		// the ledger keeps returning rows, the context is canceled after the first one
+		result := &mocks.MockResult{}
+		result.On("Next", mock.Anything).Return(true)
+		result.On("GetCurrentData").Return(versionIon).Run(func(mock.Arguments) { cancel() })
+
+		mDriver.Txn.On("Execute", mock.Anything, mock.Anything).Return(result, nil).Once()
+
+		db := &DB{Driver: mDriver, LedgerName: "test"}
+
+		versions, err := db.SelectContractVersion(ctx, "c1")
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, versions)
+		result.AssertNumberOfCalls(t, "GetCurrentData", 1)
+	})
+}
@@ -1,21 +1,33 @@
 package storage
 
 import (
+	"context"
 	"fmt"
+	"testing"
+	"time"
+
 	"github.com/amzn/ion-go/ion"
-	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
-	"github.com/carflores-zh/qldb-go/pkg/storage/mocks"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
-	"testing"
 
 	"github.com/carflores-zh/qldb-go/pkg/model"
-	"github.com/stretchr/testify/assert"
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
+	"github.com/carflores-zh/qldb-go/pkg/storage/mocks"
 )
 
 var errInsertImage = fmt.Errorf("error inserting image")
 
+// expiredContext returns a context whose deadline has already passed
+func expiredContext() context.Context {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	cancel()
+
+	return ctx
+}
+
 func TestDB_InsertImage(t *testing.T) {
 	type args struct {
+		ctx   func() context.Context
 		image *model.Image
 	}
 
@@ -28,10 +40,10 @@ func TestDB_InsertImage(t *testing.T) {
 		{"success-insert-image",
 			func() *DB {
 				// create mock driver
-				mDriver := mocks.MockQLDBDriver{}
+				mDriver := mocks.NewMockQLDBDriver()
 
 				// first create the result of the insert
-				result := mocks.MockResult{}
+				result := &mocks.MockResult{}
 
 				// operations executed on the result
 				result.On("Next", mock.Anything).Return(true)
@@ -43,14 +55,14 @@ func TestDB_InsertImage(t *testing.T) {
 
 				// operations executed on the transaction
 				mDriver.Txn.On("Execute", "INSERT INTO Image ?", mock.Anything).Return(result, nil).Times(1)
-				mDriver.Txn.On("Execute", "UPDATE Image AS i SET i.id = ? WHERE i.imageId = ?", []interface{}{metadataResult.DocumentID, "0001"}).Return(mocks.MockResult{}, nil).Times(1)
+				mDriver.Txn.On("Execute", "UPDATE Image AS i SET i.id = ? WHERE i.imageId = ?", []interface{}{metadataResult.DocumentID, "0001"}).Return(&mocks.MockResult{}, nil).Times(1)
 
 				return &DB{
 					Driver:     mDriver,
 					LedgerName: "test",
 				}
 			},
-			args{&model.Image{
+			args{context.Background, &model.Image{
 				ImageID: "0001",
 				ID:      "0123456789",
 			}},
@@ -59,27 +71,42 @@ func TestDB_InsertImage(t *testing.T) {
 		{"error-insert-image",
 			func() *DB {
 				// create mock driver
-				mDriver := mocks.MockQLDBDriver{}
-				mDriver.Txn.On("Execute", "INSERT INTO Image ?", mock.Anything).Return(mocks.MockResult{}, errInsertImage).Times(1)
+				mDriver := mocks.NewMockQLDBDriver()
+				mDriver.Txn.On("Execute", "INSERT INTO Image ?", mock.Anything).Return(&mocks.MockResult{}, errInsertImage).Times(1)
 
 				return &DB{
 					Driver:     mDriver,
 					LedgerName: "test",
 				}
 			},
-			args{&model.Image{
+			args{context.Background, &model.Image{
 				ImageID: "0001",
 				ID:      "0123456789",
 			}},
 			assert.Error,
 		},
+		{"deadline-exceeded-insert-image",
+			func() *DB {
+				// the driver never reaches the transaction once the deadline is gone
+				return &DB{
+					Driver:     mocks.NewMockQLDBDriver(),
+					LedgerName: "test",
+				}
+			},
+			args{expiredContext, &model.Image{
+				ImageID: "0001",
+			}},
+			func(t assert.TestingT, err error, msgAndArgs ...interface{}) bool {
+				return assert.ErrorIs(t, err, context.DeadlineExceeded, msgAndArgs...)
+			},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			db := tt.newDB()
 
-			tt.wantErr(t, db.InsertImage(tt.args.image), fmt.Sprintf("InsertImage(%v)", tt.args.image))
+			tt.wantErr(t, db.InsertImage(tt.args.ctx(), tt.args.image), fmt.Sprintf("InsertImage(%v)", tt.args.image))
 		})
 	}
 }
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// Sentinel errors returned by the storage package, match them with errors.Is
+var (
+	ErrNotFound            = errors.New("document not found")
+	ErrOCCConflict         = errors.New("optimistic concurrency conflict")
+	ErrInvalidStatement    = errors.New("invalid statement")
+	ErrConstraintViolation = errors.New("constraint violation")
+	ErrLedgerNotActive     = errors.New("ledger not active")
+	ErrMigrationDrift      = errors.New("migration drift")
+	ErrHashMismatch        = errors.New("revision hash mismatch")
+	ErrInvalidMigration    = errors.New("invalid migration file")
+	ErrMigrationLocked     = errors.New("migration locked")
+	ErrLockLost            = errors.New("migration lock lost")
+	ErrNotReady            = errors.New("tables not ready")
+	ErrMigrationFailed     = errors.New("migration failed")
+	ErrRedacted            = errors.New("revision redacted")
+)
+
+// NotFoundError reports a document missing from a table, it matches ErrNotFound
+type NotFoundError struct {
+	Table string
+	ID    string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %q: %v", e.Table, e.ID, ErrNotFound)
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return ErrNotFound
+}
+
+// StatementError reports a statement rejected locally or by the ledger, it matches ErrInvalidStatement
+type StatementError struct {
+	Statement string
+	Position  string // file:line of the statement in its migration, empty outside migrations
+	Reason    string
+	Err       error // ledger error that rejected the statement, nil when rejected locally
+}
+
+func (e *StatementError) Error() string {
+	prefix := fmt.Sprintf("%v {%s}", ErrInvalidStatement, e.Statement)
+	if e.Position != "" {
+		prefix = fmt.Sprintf("%v at %s {%s}", ErrInvalidStatement, e.Position, e.Statement)
+	}
+
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", prefix, e.Reason, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %s", prefix, e.Reason)
+}
+
+func (e *StatementError) Unwrap() []error {
+	if e.Err != nil {
+		return []error{ErrInvalidStatement, e.Err}
+	}
+
+	return []error{ErrInvalidStatement}
+}
+
+// ParseError reports a migration file that can't be split into statements, it matches ErrInvalidMigration
+type ParseError struct {
+	File   string
+	Line   int
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%v %s:%d: %s", ErrInvalidMigration, e.File, e.Line, e.Reason)
+}
+
+func (e *ParseError) Unwrap() error {
+	return ErrInvalidMigration
+}
+
+// MigrationDriftError reports a ledger whose recorded migrations don't match the migration files, it matches ErrMigrationDrift
+type MigrationDriftError struct {
+	Version int
+	Reason  string
+}
+
+func (e *MigrationDriftError) Error() string {
+	return fmt.Sprintf("%v at version %d: %s", ErrMigrationDrift, e.Version, e.Reason)
+}
+
+func (e *MigrationDriftError) Unwrap() error {
+	return ErrMigrationDrift
+}
+
+// MigrationFailedError reports a migration step that was rolled back, the ledger stays at version From.
+// It matches ErrMigrationFailed and Err
+type MigrationFailedError struct {
+	From      int
+	Direction string
+	Source    string
+	Err       error
+}
+
+func (e *MigrationFailedError) Error() string {
+	return fmt.Sprintf("%v: %s %s, the ledger stays at version %d: %v", ErrMigrationFailed, e.Source, e.Direction, e.From, e.Err)
+}
+
+func (e *MigrationFailedError) Unwrap() []error {
+	return []error{ErrMigrationFailed, e.Err}
+}
+
+// LockedError reports a migration lock held by another migrator, it matches ErrMigrationLocked
+type LockedError struct {
+	Owner      string
+	AcquiredAt time.Time
+	ExpiresAt  time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%v by %s since %s until %s", ErrMigrationLocked, e.Owner,
+		e.AcquiredAt.Format(time.RFC3339), e.ExpiresAt.Format(time.RFC3339))
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrMigrationLocked
+}
+
+// NotReadyError reports tables or indexes still pending when WaitForTables gave up, it matches ErrNotReady and Err
+type NotReadyError struct {
+	Pending []string
+	Err     error // why the wait stopped, e.g. context.DeadlineExceeded
+}
+
+func (e *NotReadyError) Error() string {
+	return fmt.Sprintf("%v: %s: %v", ErrNotReady, strings.Join(e.Pending, ", "), e.Err)
+}
+
+func (e *NotReadyError) Unwrap() []error {
+	return []error{ErrNotReady, e.Err}
+}
+
+// HashMismatchError reports revisions whose stored hash differs from the one computed from their content, it matches ErrHashMismatch
+type HashMismatchError struct {
+	Table    string
+	ID       string
+	Versions []int
+}
+
+func (e *HashMismatchError) Error() string {
+	return fmt.Sprintf("%v: %s %q versions %v", ErrHashMismatch, e.Table, e.ID, e.Versions)
+}
+
+func (e *HashMismatchError) Unwrap() error {
+	return ErrHashMismatch
+}
+
+// translateError maps QLDB session and control plane errors to the storage sentinel errors.
+// The original error stays in the chain so errors.As still reaches the AWS exception types
+func translateError(err error) error {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+
+	var sentinel error
+
+	switch apiErr.ErrorCode() {
+	case "OccConflictException":
+		sentinel = ErrOCCConflict
+	case "ResourceNotFoundException":
+		sentinel = ErrNotFound
+	case "ResourceAlreadyExistsException":
+		sentinel = ErrConstraintViolation
+	case "ResourceInUseException", "ResourcePreconditionNotMetException":
+		sentinel = ErrLedgerNotActive
+	case "BadRequestException", "InvalidParameterException":
+		sentinel = badRequestSentinel(apiErr.ErrorMessage())
+	default:
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
+
+// badRequestSentinel classifies a BadRequestException by its message, QLDB uses the same exception for all of them
+func badRequestSentinel(message string) error {
+	message = strings.ToLower(message)
+
+	switch {
+	case strings.Contains(message, "not active") || strings.Contains(message, "active state"):
+		return ErrLedgerNotActive
+	case strings.Contains(message, "already exists") || strings.Contains(message, "constraint"):
+		return ErrConstraintViolation
+	default:
+		return ErrInvalidStatement
+	}
+}
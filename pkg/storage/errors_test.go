@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	qldbtypes "github.com/aws/aws-sdk-go-v2/service/qldb/types"
+	sessiontypes "github.com/aws/aws-sdk-go-v2/service/qldbsession/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/carflores-zh/qldb-go/pkg/storage/mocks"
+)
+
+func TestTranslateError(t *testing.T) {
+	message := func(m string) *string { return &m }
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"occ", &sessiontypes.OccConflictException{Message: message("digest mismatch")}, ErrOCCConflict},
+		{"syntax", &sessiontypes.BadRequestException{Message: message("Syntax Error: unexpected token")}, ErrInvalidStatement},
+		{"table-exists", &sessiontypes.BadRequestException{Message: message("Table with name: Contract already exists")}, ErrConstraintViolation},
+		{"ledger-not-active", &sessiontypes.BadRequestException{Message: message("Ledger ledger is not active")}, ErrLedgerNotActive},
+		{"ledger-missing", &qldbtypes.ResourceNotFoundException{Message: message("ledger not found")}, ErrNotFound},
+		{"ledger-in-use", &qldbtypes.ResourceInUseException{Message: message("ledger is being created")}, ErrLedgerNotActive},
+		{"generic-api-error", &smithy.GenericAPIError{Code: "OccConflictException"}, ErrOCCConflict},
+		{"wrapped", fmt.Errorf("operation error: %w", &sessiontypes.OccConflictException{}), ErrOCCConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := translateError(tt.err)
+
+			assert.ErrorIs(t, got, tt.want)
+			assert.ErrorIs(t, got, tt.err)
+		})
+	}
+
+	t.Run("unknown-error-untouched", func(t *testing.T) {
+		err := errors.New("boom")
+		assert.Equal(t, err, translateError(err))
+	})
+}
+
+func TestStatementError(t *testing.T) {
+	cause := &sessiontypes.BadRequestException{}
+	err := error(&StatementError{Statement: "SELEC 1", Reason: "rejected by the ledger", Err: cause})
+
+	var statementErr *StatementError
+
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+	assert.ErrorIs(t, err, cause)
+	assert.True(t, errors.As(err, &statementErr))
+	assert.Equal(t, "SELEC 1", statementErr.Statement)
+}
+
+func TestDB_SelectContractActive_NotFound(t *testing.T) {
+	mDriver := mocks.NewMockQLDBDriver()
+
+	result := &mocks.MockResult{}
+	result.On("Next", mock.Anything).Return(false)
+	result.On("Err").Return(nil)
+
+	mDriver.Txn.On("Execute", "SELECT id FROM Contract AS c WHERE c.id = ?", []interface{}{"missing"}).Return(result, nil)
+
+	db := &DB{Driver: mDriver, LedgerName: "test"}
+
+	_, err := db.SelectContractActive(context.Background(), "missing")
+
+	var notFound *NotFoundError
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "missing", notFound.ID)
+}
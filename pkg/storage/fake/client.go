@@ -0,0 +1,630 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/qldb"
+	"github.com/aws/aws-sdk-go-v2/service/qldb/types"
+)
+
+const arnPrefix = "arn:aws:qldb:us-east-2:123456789012:"
+
+var errNotSupported = errors.New("not supported by the fake client")
+
+// Client is an in-memory implementation of storage.QLDBClient (the QLDB control plane).
+// Ledgers go CREATING → ACTIVE → DELETING → gone, exports IN_PROGRESS → COMPLETED,
+// each transition happens once the transition delay has elapsed on the client clock
+type Client struct {
+	mu              sync.Mutex
+	clock           func() time.Time
+	transitionDelay time.Duration
+	ledgers         map[string]*ledgerResource
+	exports         map[string]*types.JournalS3ExportDescription
+	streams         map[string]*types.JournalKinesisStreamDescription
+	tags            map[string]map[string]*string // by resource ARN
+	sequence        int
+}
+
+type ledgerResource struct {
+	name               string
+	arn                string
+	state              types.LedgerState
+	permissionsMode    types.PermissionsMode
+	deletionProtection bool
+	createdAt          time.Time
+	changedAt          time.Time // last state change, transitions are relative to it
+}
+
+// ClientOption configures a Client
+type ClientOption func(*Client)
+
+// WithClientClock sets the clock driving the state transitions (default time.Now)
+func WithClientClock(clock func() time.Time) ClientOption {
+	return func(c *Client) {
+		c.clock = clock
+	}
+}
+
+// WithTransitionDelay sets how long ledgers stay CREATING/DELETING and exports IN_PROGRESS (default 0,
+// the next call after the change already sees the new state)
+func WithTransitionDelay(delay time.Duration) ClientOption {
+	return func(c *Client) {
+		c.transitionDelay = delay
+	}
+}
+
+func NewClient(opts ...ClientOption) *Client {
+	c := &Client{
+		clock:   time.Now,
+		ledgers: map[string]*ledgerResource{},
+		exports: map[string]*types.JournalS3ExportDescription{},
+		streams: map[string]*types.JournalKinesisStreamDescription{},
+		tags:    map[string]map[string]*string{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// advance applies the transitions due at the current time, callers hold the lock
+func (c *Client) advance() {
+	now := c.clock()
+
+	for name, l := range c.ledgers {
+		if now.Sub(l.changedAt) < c.transitionDelay {
+			continue
+		}
+
+		switch l.state {
+		case types.LedgerStateCreating:
+			l.state = types.LedgerStateActive
+			l.changedAt = now
+		case types.LedgerStateDeleting:
+			delete(c.ledgers, name)
+			delete(c.tags, l.arn)
+		}
+	}
+
+	for _, export := range c.exports {
+		if export.Status == types.ExportStatusInProgress && now.Sub(*export.ExportCreationTime) >= c.transitionDelay {
+			export.Status = types.ExportStatusCompleted
+		}
+	}
+}
+
+func (c *Client) nextID() string {
+	c.sequence++
+	return fmt.Sprintf("%022d", c.sequence)
+}
+
+// ledger returns an existing ledger, the error matches the QLDB ResourceNotFoundException
+func (c *Client) ledger(name *string) (*ledgerResource, error) {
+	l, ok := c.ledgers[aws.ToString(name)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{
+			Message:      aws.String(fmt.Sprintf("The ledger %s does not exist", aws.ToString(name))),
+			ResourceType: aws.String("LEDGER"),
+			ResourceName: name,
+		}
+	}
+
+	return l, nil
+}
+
+// activeLedger returns a ledger that can serve journal requests
+func (c *Client) activeLedger(name *string) (*ledgerResource, error) {
+	l, err := c.ledger(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.state != types.LedgerStateActive {
+		return nil, &types.ResourcePreconditionNotMetException{
+			Message: aws.String(fmt.Sprintf("The ledger %s is %s", l.name, l.state)),
+		}
+	}
+
+	return l, nil
+}
+
+func (c *Client) CreateLedger(ctx context.Context, params *qldb.CreateLedgerInput, optFns ...func(*qldb.Options)) (*qldb.CreateLedgerOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	name := aws.ToString(params.Name)
+	if _, exists := c.ledgers[name]; exists {
+		return nil, &types.ResourceAlreadyExistsException{
+			Message:      aws.String(fmt.Sprintf("The ledger %s already exists", name)),
+			ResourceType: aws.String("LEDGER"),
+			ResourceName: params.Name,
+		}
+	}
+
+	if params.PermissionsMode == "" {
+		return nil, &types.InvalidParameterException{Message: aws.String("PermissionsMode is required")}
+	}
+
+	now := c.clock()
+	l := &ledgerResource{
+		name:               name,
+		arn:                arnPrefix + "ledger/" + name,
+		state:              types.LedgerStateCreating,
+		permissionsMode:    params.PermissionsMode,
+		deletionProtection: params.DeletionProtection == nil || *params.DeletionProtection,
+		createdAt:          now,
+		changedAt:          now,
+	}
+
+	c.ledgers[name] = l
+	c.setTags(l.arn, params.Tags)
+
+	return &qldb.CreateLedgerOutput{
+		Arn:                aws.String(l.arn),
+		CreationDateTime:   aws.Time(l.createdAt),
+		DeletionProtection: aws.Bool(l.deletionProtection),
+		Name:               aws.String(l.name),
+		PermissionsMode:    l.permissionsMode,
+		State:              l.state,
+	}, nil
+}
+
+func (c *Client) DescribeLedger(ctx context.Context, params *qldb.DescribeLedgerInput, optFns ...func(*qldb.Options)) (*qldb.DescribeLedgerOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	l, err := c.ledger(params.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &qldb.DescribeLedgerOutput{
+		Arn:                aws.String(l.arn),
+		CreationDateTime:   aws.Time(l.createdAt),
+		DeletionProtection: aws.Bool(l.deletionProtection),
+		Name:               aws.String(l.name),
+		PermissionsMode:    l.permissionsMode,
+		State:              l.state,
+	}, nil
+}
+
+func (c *Client) ListLedgers(ctx context.Context, params *qldb.ListLedgersInput, optFns ...func(*qldb.Options)) (*qldb.ListLedgersOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	names := make([]string, 0, len(c.ledgers))
+	for name := range c.ledgers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	if params == nil {
+		params = &qldb.ListLedgersInput{}
+	}
+
+	from, to, next, err := page(len(names), params.MaxResults, params.NextToken)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &qldb.ListLedgersOutput{NextToken: next}
+	for _, name := range names[from:to] {
+		l := c.ledgers[name]
+		out.Ledgers = append(out.Ledgers, types.LedgerSummary{
+			CreationDateTime: aws.Time(l.createdAt),
+			Name:             aws.String(l.name),
+			State:            l.state,
+		})
+	}
+
+	return out, nil
+}
+
+func (c *Client) UpdateLedger(ctx context.Context, params *qldb.UpdateLedgerInput, optFns ...func(*qldb.Options)) (*qldb.UpdateLedgerOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	l, err := c.ledger(params.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.DeletionProtection != nil {
+		l.deletionProtection = *params.DeletionProtection
+	}
+
+	return &qldb.UpdateLedgerOutput{
+		Arn:                aws.String(l.arn),
+		CreationDateTime:   aws.Time(l.createdAt),
+		DeletionProtection: aws.Bool(l.deletionProtection),
+		Name:               aws.String(l.name),
+		State:              l.state,
+	}, nil
+}
+
+func (c *Client) UpdateLedgerPermissionsMode(ctx context.Context, params *qldb.UpdateLedgerPermissionsModeInput, optFns ...func(*qldb.Options)) (*qldb.UpdateLedgerPermissionsModeOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	l, err := c.ledger(params.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	l.permissionsMode = params.PermissionsMode
+
+	return &qldb.UpdateLedgerPermissionsModeOutput{
+		Arn:             aws.String(l.arn),
+		Name:            aws.String(l.name),
+		PermissionsMode: l.permissionsMode,
+	}, nil
+}
+
+func (c *Client) DeleteLedger(ctx context.Context, params *qldb.DeleteLedgerInput, optFns ...func(*qldb.Options)) (*qldb.DeleteLedgerOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	l, err := c.ledger(params.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case l.deletionProtection:
+		return nil, &types.ResourcePreconditionNotMetException{
+			Message:      aws.String(fmt.Sprintf("The ledger %s has deletion protection enabled", l.name)),
+			ResourceType: aws.String("LEDGER"),
+			ResourceName: params.Name,
+		}
+	case l.state != types.LedgerStateActive:
+		return nil, &types.ResourceInUseException{
+			Message:      aws.String(fmt.Sprintf("The ledger %s is %s", l.name, l.state)),
+			ResourceType: aws.String("LEDGER"),
+			ResourceName: params.Name,
+		}
+	}
+
+	l.state = types.LedgerStateDeleting
+	l.changedAt = c.clock()
+
+	return &qldb.DeleteLedgerOutput{}, nil
+}
+
+func (c *Client) TagResource(ctx context.Context, params *qldb.TagResourceInput, optFns ...func(*qldb.Options)) (*qldb.TagResourceOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	if err := c.resourceExists(params.ResourceArn); err != nil {
+		return nil, err
+	}
+
+	c.setTags(aws.ToString(params.ResourceArn), params.Tags)
+
+	return &qldb.TagResourceOutput{}, nil
+}
+
+func (c *Client) UntagResource(ctx context.Context, params *qldb.UntagResourceInput, optFns ...func(*qldb.Options)) (*qldb.UntagResourceOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	if err := c.resourceExists(params.ResourceArn); err != nil {
+		return nil, err
+	}
+
+	for _, key := range params.TagKeys {
+		delete(c.tags[aws.ToString(params.ResourceArn)], key)
+	}
+
+	return &qldb.UntagResourceOutput{}, nil
+}
+
+func (c *Client) ListTagsForResource(ctx context.Context, params *qldb.ListTagsForResourceInput, optFns ...func(*qldb.Options)) (*qldb.ListTagsForResourceOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	if err := c.resourceExists(params.ResourceArn); err != nil {
+		return nil, err
+	}
+
+	tags := map[string]*string{}
+	for key, value := range c.tags[aws.ToString(params.ResourceArn)] {
+		tags[key] = value
+	}
+
+	return &qldb.ListTagsForResourceOutput{Tags: tags}, nil
+}
+
+func (c *Client) setTags(arn string, tags map[string]*string) {
+	if len(tags) == 0 {
+		return
+	}
+
+	if c.tags[arn] == nil {
+		c.tags[arn] = map[string]*string{}
+	}
+
+	for key, value := range tags {
+		c.tags[arn][key] = value
+	}
+}
+
+// resourceExists checks a ledger or stream ARN, the only taggable QLDB resources
+func (c *Client) resourceExists(arn *string) error {
+	for _, l := range c.ledgers {
+		if l.arn == aws.ToString(arn) {
+			return nil
+		}
+	}
+
+	for _, s := range c.streams {
+		if aws.ToString(s.Arn) == aws.ToString(arn) {
+			return nil
+		}
+	}
+
+	return &types.ResourceNotFoundException{Message: aws.String(fmt.Sprintf("The resource %s does not exist", aws.ToString(arn)))}
+}
+
+func (c *Client) ExportJournalToS3(ctx context.Context, params *qldb.ExportJournalToS3Input, optFns ...func(*qldb.Options)) (*qldb.ExportJournalToS3Output, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	l, err := c.activeLedger(params.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.InclusiveStartTime != nil && params.ExclusiveEndTime != nil && !params.InclusiveStartTime.Before(*params.ExclusiveEndTime) {
+		return nil, &types.InvalidParameterException{Message: aws.String("InclusiveStartTime must be before ExclusiveEndTime")}
+	}
+
+	export := &types.JournalS3ExportDescription{
+		ExclusiveEndTime:      params.ExclusiveEndTime,
+		ExportCreationTime:    aws.Time(c.clock()),
+		ExportId:              aws.String(c.nextID()),
+		InclusiveStartTime:    params.InclusiveStartTime,
+		LedgerName:            aws.String(l.name),
+		RoleArn:               params.RoleArn,
+		S3ExportConfiguration: params.S3ExportConfiguration,
+		Status:                types.ExportStatusInProgress,
+		OutputFormat:          params.OutputFormat,
+	}
+
+	if export.OutputFormat == "" {
+		export.OutputFormat = types.OutputFormatIonBinary
+	}
+
+	c.exports[*export.ExportId] = export
+
+	return &qldb.ExportJournalToS3Output{ExportId: export.ExportId}, nil
+}
+
+func (c *Client) DescribeJournalS3Export(ctx context.Context, params *qldb.DescribeJournalS3ExportInput, optFns ...func(*qldb.Options)) (*qldb.DescribeJournalS3ExportOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	export, ok := c.exports[aws.ToString(params.ExportId)]
+	if !ok || aws.ToString(export.LedgerName) != aws.ToString(params.Name) {
+		return nil, &types.ResourceNotFoundException{Message: aws.String(fmt.Sprintf("The export %s does not exist", aws.ToString(params.ExportId)))}
+	}
+
+	description := *export
+
+	return &qldb.DescribeJournalS3ExportOutput{ExportDescription: &description}, nil
+}
+
+func (c *Client) ListJournalS3Exports(ctx context.Context, params *qldb.ListJournalS3ExportsInput, optFns ...func(*qldb.Options)) (*qldb.ListJournalS3ExportsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	exports, next, err := c.listExports("", params.MaxResults, params.NextToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &qldb.ListJournalS3ExportsOutput{JournalS3Exports: exports, NextToken: next}, nil
+}
+
+func (c *Client) ListJournalS3ExportsForLedger(ctx context.Context, params *qldb.ListJournalS3ExportsForLedgerInput, optFns ...func(*qldb.Options)) (*qldb.ListJournalS3ExportsForLedgerOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	if _, err := c.ledger(params.Name); err != nil {
+		return nil, err
+	}
+
+	exports, next, err := c.listExports(aws.ToString(params.Name), params.MaxResults, params.NextToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &qldb.ListJournalS3ExportsForLedgerOutput{JournalS3Exports: exports, NextToken: next}, nil
+}
+
+// listExports pages over the exports of ledgerName (all ledgers if empty) ordered by ID
+func (c *Client) listExports(ledgerName string, maxResults *int32, nextToken *string) ([]types.JournalS3ExportDescription, *string, error) {
+	var ids []string
+
+	for id, export := range c.exports {
+		if ledgerName == "" || aws.ToString(export.LedgerName) == ledgerName {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	from, to, next, err := page(len(ids), maxResults, nextToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	exports := make([]types.JournalS3ExportDescription, 0, to-from)
+	for _, id := range ids[from:to] {
+		exports = append(exports, *c.exports[id])
+	}
+
+	return exports, next, nil
+}
+
+func (c *Client) StreamJournalToKinesis(ctx context.Context, params *qldb.StreamJournalToKinesisInput, optFns ...func(*qldb.Options)) (*qldb.StreamJournalToKinesisOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	l, err := c.activeLedger(params.LedgerName)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.KinesisConfiguration == nil || params.KinesisConfiguration.StreamArn == nil {
+		return nil, &types.InvalidParameterException{Message: aws.String("KinesisConfiguration.StreamArn is required")}
+	}
+
+	id := c.nextID()
+	stream := &types.JournalKinesisStreamDescription{
+		KinesisConfiguration: params.KinesisConfiguration,
+		LedgerName:           aws.String(l.name),
+		RoleArn:              params.RoleArn,
+		Status:               types.StreamStatusActive,
+		StreamId:             aws.String(id),
+		StreamName:           params.StreamName,
+		Arn:                  aws.String(arnPrefix + "stream/" + l.name + "/" + id),
+		CreationTime:         aws.Time(c.clock()),
+		ExclusiveEndTime:     params.ExclusiveEndTime,
+		InclusiveStartTime:   params.InclusiveStartTime,
+	}
+
+	c.streams[id] = stream
+	c.setTags(*stream.Arn, params.Tags)
+
+	return &qldb.StreamJournalToKinesisOutput{StreamId: stream.StreamId}, nil
+}
+
+func (c *Client) DescribeJournalKinesisStream(ctx context.Context, params *qldb.DescribeJournalKinesisStreamInput, optFns ...func(*qldb.Options)) (*qldb.DescribeJournalKinesisStreamOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	stream, err := c.stream(params.LedgerName, params.StreamId)
+	if err != nil {
+		return nil, err
+	}
+
+	description := *stream
+
+	return &qldb.DescribeJournalKinesisStreamOutput{Stream: &description}, nil
+}
+
+func (c *Client) CancelJournalKinesisStream(ctx context.Context, params *qldb.CancelJournalKinesisStreamInput, optFns ...func(*qldb.Options)) (*qldb.CancelJournalKinesisStreamOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	stream, err := c.stream(params.LedgerName, params.StreamId)
+	if err != nil {
+		return nil, err
+	}
+
+	if stream.Status != types.StreamStatusActive && stream.Status != types.StreamStatusImpaired {
+		return nil, &types.ResourcePreconditionNotMetException{
+			Message: aws.String(fmt.Sprintf("The stream %s is %s", aws.ToString(stream.StreamId), stream.Status)),
+		}
+	}
+
+	stream.Status = types.StreamStatusCanceled
+
+	return &qldb.CancelJournalKinesisStreamOutput{StreamId: stream.StreamId}, nil
+}
+
+func (c *Client) ListJournalKinesisStreamsForLedger(ctx context.Context, params *qldb.ListJournalKinesisStreamsForLedgerInput, optFns ...func(*qldb.Options)) (*qldb.ListJournalKinesisStreamsForLedgerOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+
+	if _, err := c.ledger(params.LedgerName); err != nil {
+		return nil, err
+	}
+
+	var ids []string
+
+	for id, stream := range c.streams {
+		if aws.ToString(stream.LedgerName) == aws.ToString(params.LedgerName) {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	from, to, next, err := page(len(ids), params.MaxResults, params.NextToken)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &qldb.ListJournalKinesisStreamsForLedgerOutput{NextToken: next}
+	for _, id := range ids[from:to] {
+		out.Streams = append(out.Streams, *c.streams[id])
+	}
+
+	return out, nil
+}
+
+func (c *Client) stream(ledgerName, streamID *string) (*types.JournalKinesisStreamDescription, error) {
+	stream, ok := c.streams[aws.ToString(streamID)]
+	if !ok || aws.ToString(stream.LedgerName) != aws.ToString(ledgerName) {
+		return nil, &types.ResourceNotFoundException{Message: aws.String(fmt.Sprintf("The stream %s does not exist", aws.ToString(streamID)))}
+	}
+
+	return stream, nil
+}
+
+func (c *Client) GetDigest(ctx context.Context, params *qldb.GetDigestInput, optFns ...func(*qldb.Options)) (*qldb.GetDigestOutput, error) {
+	return nil, fmt.Errorf("GetDigest: %w", errNotSupported)
+}
+
+func (c *Client) GetRevision(ctx context.Context, params *qldb.GetRevisionInput, optFns ...func(*qldb.Options)) (*qldb.GetRevisionOutput, error) {
+	return nil, fmt.Errorf("GetRevision: %w", errNotSupported)
+}
+
+func (c *Client) GetBlock(ctx context.Context, params *qldb.GetBlockInput, optFns ...func(*qldb.Options)) (*qldb.GetBlockOutput, error) {
+	return nil, fmt.Errorf("GetBlock: %w", errNotSupported)
+}
+
+// page returns the bounds of the requested page and the token of the next one
+func page(total int, maxResults *int32, nextToken *string) (from, to int, next *string, err error) {
+	if nextToken != nil {
+		if from, err = strconv.Atoi(*nextToken); err != nil || from < 0 || from > total {
+			return 0, 0, nil, &types.InvalidParameterException{Message: aws.String("invalid NextToken")}
+		}
+	}
+
+	to = total
+	if maxResults != nil && *maxResults > 0 && from+int(*maxResults) < total {
+		to = from + int(*maxResults)
+		next = aws.String(strconv.Itoa(to))
+	}
+
+	return from, to, next, nil
+}
@@ -0,0 +1,142 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/qldb"
+	"github.com/aws/aws-sdk-go-v2/service/qldb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_LedgerLifecycle(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	c := NewClient(WithClientClock(func() time.Time { return now }), WithTransitionDelay(time.Minute))
+
+	created, err := c.CreateLedger(ctx, &qldb.CreateLedgerInput{
+		Name:            aws.String("test"),
+		PermissionsMode: types.PermissionsModeStandard,
+		Tags:            map[string]*string{"env": aws.String("dev")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, types.LedgerStateCreating, created.State)
+	assert.True(t, *created.DeletionProtection, "deletion protection is on by default")
+
+	var alreadyExists *types.ResourceAlreadyExistsException
+
+	_, err = c.CreateLedger(ctx, &qldb.CreateLedgerInput{Name: aws.String("test"), PermissionsMode: types.PermissionsModeStandard})
+	assert.True(t, errors.As(err, &alreadyExists))
+
+	now = now.Add(time.Minute)
+
+	ledger, err := c.DescribeLedger(ctx, &qldb.DescribeLedgerInput{Name: aws.String("test")})
+	require.NoError(t, err)
+	assert.Equal(t, types.LedgerStateActive, ledger.State)
+
+	tags, err := c.ListTagsForResource(ctx, &qldb.ListTagsForResourceInput{ResourceArn: ledger.Arn})
+	require.NoError(t, err)
+	assert.Equal(t, "dev", aws.ToString(tags.Tags["env"]))
+
+	var preconditionNotMet *types.ResourcePreconditionNotMetException
+
+	_, err = c.DeleteLedger(ctx, &qldb.DeleteLedgerInput{Name: aws.String("test")})
+	assert.True(t, errors.As(err, &preconditionNotMet))
+
+	_, err = c.UpdateLedger(ctx, &qldb.UpdateLedgerInput{Name: aws.String("test"), DeletionProtection: aws.Bool(false)})
+	require.NoError(t, err)
+
+	_, err = c.DeleteLedger(ctx, &qldb.DeleteLedgerInput{Name: aws.String("test")})
+	require.NoError(t, err)
+
+	list, err := c.ListLedgers(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, list.Ledgers, 1)
+	assert.Equal(t, types.LedgerStateDeleting, list.Ledgers[0].State)
+
+	now = now.Add(time.Minute)
+
+	var notFound *types.ResourceNotFoundException
+
+	_, err = c.DescribeLedger(ctx, &qldb.DescribeLedgerInput{Name: aws.String("test")})
+	assert.True(t, errors.As(err, &notFound))
+
+	_, err = c.ListTagsForResource(ctx, &qldb.ListTagsForResourceInput{ResourceArn: ledger.Arn})
+	assert.True(t, errors.As(err, &notFound))
+}
+
+func TestClient_ListLedgersPages(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient()
+
+	for _, name := range []string{"c", "a", "b"} {
+		_, err := c.CreateLedger(ctx, &qldb.CreateLedgerInput{Name: aws.String(name), PermissionsMode: types.PermissionsModeStandard})
+		require.NoError(t, err)
+	}
+
+	var names []string
+
+	paginator := qldb.NewListLedgersPaginator(c, &qldb.ListLedgersInput{MaxResults: aws.Int32(2)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		require.NoError(t, err)
+
+		for _, ledger := range page.Ledgers {
+			names = append(names, aws.ToString(ledger.Name))
+		}
+	}
+
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+}
+
+func TestClient_ExportsAndStreams(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	c := NewClient(WithClientClock(func() time.Time { return now }), WithTransitionDelay(time.Minute))
+
+	_, err := c.CreateLedger(ctx, &qldb.CreateLedgerInput{Name: aws.String("test"), PermissionsMode: types.PermissionsModeStandard})
+	require.NoError(t, err)
+
+	var preconditionNotMet *types.ResourcePreconditionNotMetException
+
+	_, err = c.ExportJournalToS3(ctx, &qldb.ExportJournalToS3Input{Name: aws.String("test")})
+	assert.True(t, errors.As(err, &preconditionNotMet), "the ledger is still CREATING")
+
+	now = now.Add(time.Minute)
+
+	export, err := c.ExportJournalToS3(ctx, &qldb.ExportJournalToS3Input{Name: aws.String("test")})
+	require.NoError(t, err)
+
+	described, err := c.DescribeJournalS3Export(ctx, &qldb.DescribeJournalS3ExportInput{Name: aws.String("test"), ExportId: export.ExportId})
+	require.NoError(t, err)
+	assert.Equal(t, types.ExportStatusInProgress, described.ExportDescription.Status)
+
+	now = now.Add(time.Minute)
+
+	exports, err := c.ListJournalS3ExportsForLedger(ctx, &qldb.ListJournalS3ExportsForLedgerInput{Name: aws.String("test")})
+	require.NoError(t, err)
+	require.Len(t, exports.JournalS3Exports, 1)
+	assert.Equal(t, types.ExportStatusCompleted, exports.JournalS3Exports[0].Status)
+
+	stream, err := c.StreamJournalToKinesis(ctx, &qldb.StreamJournalToKinesisInput{
+		LedgerName:           aws.String("test"),
+		StreamName:           aws.String("journal"),
+		KinesisConfiguration: &types.KinesisConfiguration{StreamArn: aws.String("arn:aws:kinesis:us-east-2:123456789012:stream/journal")},
+	})
+	require.NoError(t, err)
+
+	_, err = c.CancelJournalKinesisStream(ctx, &qldb.CancelJournalKinesisStreamInput{LedgerName: aws.String("test"), StreamId: stream.StreamId})
+	require.NoError(t, err)
+
+	streams, err := c.ListJournalKinesisStreamsForLedger(ctx, &qldb.ListJournalKinesisStreamsForLedgerInput{LedgerName: aws.String("test")})
+	require.NoError(t, err)
+	require.Len(t, streams.Streams, 1)
+	assert.Equal(t, types.StreamStatusCanceled, streams.Streams[0].Status)
+
+	_, err = c.CancelJournalKinesisStream(ctx, &qldb.CancelJournalKinesisStreamInput{LedgerName: aws.String("test"), StreamId: stream.StreamId})
+	assert.True(t, errors.As(err, &preconditionNotMet))
+}
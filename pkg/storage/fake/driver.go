@@ -0,0 +1,289 @@
+// Package fake provides an in-memory QLDB driver for tests.
+// It keeps tables, indexes and the full revision history of every document, and understands the
+// PartiQL subset used by pkg/storage, so the Store and the migrator can run without a ledger.
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amzn/ion-go/ion"
+	"github.com/aws/aws-sdk-go-v2/service/qldbsession/types"
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+)
+
+var errDriverClosed = errors.New("cannot invoke methods on a closed driver")
+
+// Driver is an in-memory implementation of storage.QLDBDriver.
+// Transactions are serialized and see a snapshot of the ledger that is only committed when fn succeeds,
+// with one revision per document written, as QLDB commits them
+type Driver struct {
+	mu             sync.Mutex
+	state          *ledger
+	clock          func() time.Time
+	indexBuildTime time.Duration
+	failures       []failure
+	closed         bool
+}
+
+// failure is an error injected with FailOn
+type failure struct {
+	match string
+	err   error
+}
+
+// Option configures a Driver
+type Option func(*Driver)
+
+// WithClock sets the clock used for the txTime of the revisions (default time.Now)
+func WithClock(clock func() time.Time) Option {
+	return func(d *Driver) {
+		d.clock = clock
+	}
+}
+
+// WithIndexBuildTime keeps new indexes BUILDING in information_schema.user_tables for buildTime (default 0, online at once)
+func WithIndexBuildTime(buildTime time.Duration) Option {
+	return func(d *Driver) {
+		d.indexBuildTime = buildTime
+	}
+}
+
+func NewDriver(opts ...Option) *Driver {
+	d := &Driver{
+		state: newLedger(),
+		clock: time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// FailOn makes the next statement containing match fail with err, the transaction is rolled back
+func (d *Driver) FailOn(match string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.failures = append(d.failures, failure{match: match, err: err})
+}
+
+func (d *Driver) Execute(ctx context.Context, fn func(txn qldbdriver.Transaction) (interface{}, error)) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, errDriverClosed
+	}
+
+	txn := &transaction{
+		driver: d,
+		state:  d.state.clone(),
+		txTime: timestampOf(d.clock()),
+		block:  d.state.blockSeq + 1,
+	}
+	txn.id = txn.state.newID()
+
+	result, err := fn(txn)
+	if err != nil {
+		return nil, err
+	}
+
+	if errCtx := ctx.Err(); errCtx != nil {
+		return nil, errCtx
+	}
+
+	if txn.aborted {
+		return result, nil
+	}
+
+	if txn.wrote {
+		txn.state.blockSeq = txn.block
+	}
+
+	d.state = txn.state
+
+	return result, nil
+}
+
+// SetRetryPolicy does nothing, the transactions of the fake are serialized and never conflict or get retried
+func (d *Driver) SetRetryPolicy(qldbdriver.RetryPolicy) {}
+
+// GetTableNames returns the active tables, sorted by name
+func (d *Driver) GetTableNames(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, errDriverClosed
+	}
+
+	names := make([]string, 0, len(d.state.tables))
+	for name := range d.state.tables {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (d *Driver) Shutdown(ctx context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+}
+
+// takeFailure returns the injected error for statement, if any
+func (d *Driver) takeFailure(statement string) error {
+	for i, f := range d.failures {
+		if strings.Contains(statement, f.match) {
+			d.failures = append(d.failures[:i], d.failures[i+1:]...)
+			return f.err
+		}
+	}
+
+	return nil
+}
+
+type transaction struct {
+	driver  *Driver
+	id      string
+	state   *ledger
+	txTime  ion.Timestamp
+	block   int64
+	wrote   bool
+	aborted bool
+}
+
+func (txn *transaction) Execute(statement string, parameters ...interface{}) (qldbdriver.Result, error) {
+	if txn.aborted {
+		return nil, badRequest("Transaction %s has been aborted", txn.id)
+	}
+
+	if err := txn.driver.takeFailure(statement); err != nil {
+		return nil, err
+	}
+
+	stmt, err := parse(statement)
+	if err != nil {
+		return nil, badRequest("Syntax Error: %v", err)
+	}
+
+	params := make([]interface{}, 0, len(parameters))
+
+	for _, parameter := range parameters {
+		value, err := toValue(parameter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter %v: %w", parameter, err)
+		}
+
+		params = append(params, value)
+	}
+
+	values, err := txn.run(stmt, params)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &result{}
+
+	for _, value := range values {
+		data, err := encode(value)
+		if err != nil {
+			return nil, err
+		}
+
+		res.values = append(res.values, data)
+	}
+
+	return res, nil
+}
+
+func (txn *transaction) BufferResult(res qldbdriver.Result) (qldbdriver.BufferedResult, error) {
+	buffered := &result{}
+
+	for res.Next(txn) {
+		buffered.values = append(buffered.values, res.GetCurrentData())
+	}
+
+	return &bufferedResult{result: buffered}, res.Err()
+}
+
+func (txn *transaction) Abort() error {
+	txn.aborted = true
+	return nil
+}
+
+func (txn *transaction) ID() string {
+	return txn.id
+}
+
+// result is a fully materialized qldbdriver.Result
+type result struct {
+	values [][]byte
+	pos    int
+}
+
+func (r *result) Next(txn qldbdriver.Transaction) bool {
+	if r.pos >= len(r.values) {
+		return false
+	}
+
+	r.pos++
+
+	return true
+}
+
+func (r *result) GetCurrentData() []byte {
+	if r.pos == 0 {
+		return nil
+	}
+
+	return r.values[r.pos-1]
+}
+
+func (r *result) GetConsumedIOs() *qldbdriver.IOUsage {
+	return nil
+}
+
+func (r *result) GetTimingInformation() *qldbdriver.TimingInformation {
+	return nil
+}
+
+func (r *result) Err() error {
+	return nil
+}
+
+type bufferedResult struct {
+	*result
+}
+
+func (r *bufferedResult) Next() bool {
+	return r.result.Next(nil)
+}
+
+func badRequest(format string, args ...interface{}) error {
+	message := fmt.Sprintf(format, args...)
+	return &types.BadRequestException{Message: &message}
+}
+
+func noSuchTable(name string) error {
+	return badRequest("Semantic Error: No such variable named '%s'", name)
+}
@@ -0,0 +1,322 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/amzn/ion-go/ion"
+	"github.com/aws/aws-sdk-go-v2/service/qldbsession/types"
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type contract struct {
+	ID      string `ion:"id"`
+	Address string `ion:"address"`
+	Network string `ion:"network"`
+}
+
+type revisionRow struct {
+	Hash     []byte `ion:"hash"`
+	Data     *contract
+	Metadata struct {
+		ID      string        `ion:"id"`
+		Version int           `ion:"version"`
+		TxTime  ion.Timestamp `ion:"txTime"`
+	} `ion:"metadata"`
+}
+
+// query runs statement in its own transaction and unmarshals every document into a T
+func query[T any](t *testing.T, d *Driver, statement string, parameters ...interface{}) []T {
+	t.Helper()
+
+	var documents []T
+
+	_, err := d.Execute(context.Background(), func(txn qldbdriver.Transaction) (interface{}, error) {
+		documents = nil
+
+		result, err := txn.Execute(statement, parameters...)
+		if err != nil {
+			return nil, err
+		}
+
+		for result.Next(txn) {
+			var document T
+			if err = ion.Unmarshal(result.GetCurrentData(), &document); err != nil {
+				return nil, err
+			}
+
+			documents = append(documents, document)
+		}
+
+		return nil, result.Err()
+	})
+	require.NoError(t, err, statement)
+
+	return documents
+}
+
+func exec(t *testing.T, d *Driver, statements ...string) {
+	t.Helper()
+
+	for _, statement := range statements {
+		query[map[string]interface{}](t, d, statement)
+	}
+}
+
+func TestDriver_InsertUpdateSelect(t *testing.T) {
+	d := NewDriver()
+	exec(t, d, "CREATE TABLE Contract", "CREATE INDEX ON Contract(id)")
+
+	inserted := query[struct {
+		DocumentID string `ion:"documentId"`
+	}](t, d, "INSERT INTO Contract ?", contract{Address: "0x1", Network: "ethereum"})
+	require.Len(t, inserted, 1)
+	require.Len(t, inserted[0].DocumentID, idLength)
+
+	id := inserted[0].DocumentID
+
+	query[map[string]interface{}](t, d, "UPDATE Contract AS c SET c.id = ? WHERE c.address = ? AND c.network = ?", id, "0x1", "ethereum")
+
+	contracts := query[contract](t, d, "SELECT * FROM Contract AS c WHERE c.id = ?", id)
+	assert.Equal(t, []contract{{ID: id, Address: "0x1", Network: "ethereum"}}, contracts)
+
+	projected := query[map[string]interface{}](t, d, "SELECT id FROM Contract AS c WHERE c.id = ?", id)
+	assert.Equal(t, []map[string]interface{}{{"id": id}}, projected)
+
+	byID := query[struct {
+		DocID   string `ion:"docId"`
+		Network string `ion:"network"`
+	}](t, d, "SELECT docId, c.network FROM Contract AS c BY docId WHERE docId = ?", id)
+	require.Len(t, byID, 1)
+	assert.Equal(t, "ethereum", byID[0].Network)
+
+	// the whole document can be replaced
+	query[map[string]interface{}](t, d, "UPDATE Contract AS c SET c = ? where c.id = ?", contract{ID: id, Address: "0x1", Network: "polygon"}, id)
+	contracts = query[contract](t, d, "SELECT * FROM Contract WHERE network = 'polygon'")
+	assert.Len(t, contracts, 1)
+}
+
+func TestDriver_History(t *testing.T) {
+	now := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	d := NewDriver(WithClock(func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}))
+	exec(t, d, "CREATE TABLE Contract")
+
+	query[map[string]interface{}](t, d, "INSERT INTO Contract ?", contract{ID: "c1", Network: "ethereum"})
+	query[map[string]interface{}](t, d, "UPDATE Contract AS c SET c.network = 'polygon' WHERE c.id = 'c1'")
+	query[map[string]interface{}](t, d, "DELETE FROM Contract AS c WHERE c.id = 'c1'")
+
+	assert.Empty(t, query[contract](t, d, "SELECT * FROM Contract"))
+
+	revisions := query[revisionRow](t, d, "SELECT * FROM history(Contract) AS h")
+	require.Len(t, revisions, 3)
+
+	for i, r := range revisions {
+		assert.Equal(t, i, r.Metadata.Version)
+		assert.Len(t, r.Hash, 32)
+	}
+
+	assert.Equal(t, "polygon", revisions[1].Data.Network)
+	assert.Nil(t, revisions[2].Data, "a delete is recorded as a revision without data")
+
+	versions := query[struct {
+		Version int `ion:"version"`
+	}](t, d, "SELECT metadata.version from history(Contract) where data.id = ?", "c1")
+	assert.Len(t, versions, 2, "the deleted revision has no data.id")
+
+	instance := query[contract](t, d, "SELECT data.* from history(Contract) where data.id = ? AND metadata.version = ?", "c1", 1)
+	assert.Equal(t, []contract{{ID: "c1", Network: "polygon"}}, instance)
+
+	bounded := query[revisionRow](t, d, "SELECT * FROM history(Contract, `2023-03-01T10:02:00Z`, `2023-03-01T10:03:00Z`) AS h")
+	assert.Len(t, bounded, 2)
+
+	count := query[struct {
+		CountHashes int `ion:"countHashes"`
+	}](t, d, "SELECT count(dataHash) as countHashes from history(Contract)")
+	assert.Equal(t, 0, count[0].CountHashes)
+}
+
+func TestDriver_RevisionPerTransaction(t *testing.T) {
+	ctx := context.Background()
+	d := NewDriver()
+	exec(t, d, "CREATE TABLE Contract")
+
+	_, err := d.Execute(ctx, func(txn qldbdriver.Transaction) (interface{}, error) {
+		for _, statement := range []struct {
+			text       string
+			parameters []interface{}
+		}{
+			{"INSERT INTO Contract ?", []interface{}{contract{ID: "c1", Network: "ethereum"}}},
+			{"UPDATE Contract AS c SET c.network = 'polygon' WHERE c.id = 'c1'", nil},
+			{"UPDATE Contract AS c SET c.address = '0x1' WHERE c.id = 'c1'", nil},
+			{"INSERT INTO Contract ?", []interface{}{contract{ID: "c2"}}},
+			{"DELETE FROM Contract AS c WHERE c.id = 'c2'", nil},
+		} {
+			if _, err := txn.Execute(statement.text, statement.parameters...); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, nil
+	})
+	require.NoError(t, err)
+
+	revisions := query[revisionRow](t, d, "SELECT * FROM history(Contract) AS h")
+	require.Len(t, revisions, 1, "the document inserted and deleted by the transaction leaves no revision")
+	assert.Equal(t, 0, revisions[0].Metadata.Version)
+	assert.Equal(t, &contract{ID: "c1", Address: "0x1", Network: "polygon"}, revisions[0].Data)
+
+	_, err = d.Execute(ctx, func(txn qldbdriver.Transaction) (interface{}, error) {
+		if _, err := txn.Execute("UPDATE Contract AS c SET c.network = 'base' WHERE c.id = 'c1'"); err != nil {
+			return nil, err
+		}
+
+		return txn.Execute("DELETE FROM Contract AS c WHERE c.id = 'c1'")
+	})
+	require.NoError(t, err)
+
+	revisions = query[revisionRow](t, d, "SELECT * FROM history(Contract) AS h")
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 1, revisions[1].Metadata.Version)
+	assert.Nil(t, revisions[1].Data, "the transaction only commits the delete")
+}
+
+func TestDriver_SchemaAndErrors(t *testing.T) {
+	ctx := context.Background()
+	d := NewDriver()
+	exec(t, d, "CREATE TABLE Image", "CREATE INDEX ON Image(imageId)", "CREATE TABLE Share", "DROP TABLE Share")
+
+	names, err := d.GetTableNames(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Image"}, names)
+
+	tables := query[struct {
+		Name    string `ion:"name"`
+		Status  string `ion:"status"`
+		Indexes []struct {
+			Expr string `ion:"expr"`
+		} `ion:"indexes"`
+	}](t, d, "SELECT * FROM information_schema.user_tables")
+	require.Len(t, tables, 2)
+	assert.Equal(t, "Image", tables[0].Name)
+	assert.Equal(t, "[imageId]", tables[0].Indexes[0].Expr)
+	assert.Equal(t, statusInactive, tables[1].Status)
+
+	var badRequest *types.BadRequestException
+
+	_, err = d.Execute(ctx, func(txn qldbdriver.Transaction) (interface{}, error) {
+		return txn.Execute("CREATE TABLE Image")
+	})
+	assert.True(t, errors.As(err, &badRequest))
+
+	_, err = d.Execute(ctx, func(txn qldbdriver.Transaction) (interface{}, error) {
+		return txn.Execute("SELECT * FROM Missing")
+	})
+	assert.True(t, errors.As(err, &badRequest))
+
+	_, err = d.Execute(ctx, func(txn qldbdriver.Transaction) (interface{}, error) {
+		return txn.Execute("SELEC * FROM Image")
+	})
+	assert.ErrorContains(t, err, "Syntax Error")
+
+	_, err = d.Execute(ctx, func(txn qldbdriver.Transaction) (interface{}, error) {
+		return txn.Execute("CREATE INDEX ON Image(to)")
+	})
+	assert.ErrorContains(t, err, "unexpected reserved word to")
+
+	exec(t, d, `CREATE INDEX ON Image("to")`)
+}
+
+func TestDriver_DropIndex(t *testing.T) {
+	ctx := context.Background()
+	d := NewDriver()
+	exec(t, d, "CREATE TABLE Image", "CREATE INDEX ON Image(id)", "CREATE INDEX ON Image(imageId)")
+
+	type userTable struct {
+		Indexes []struct {
+			IndexID string `ion:"indexId"`
+			Expr    string `ion:"expr"`
+		} `ion:"indexes"`
+	}
+
+	tables := query[userTable](t, d, "SELECT * FROM information_schema.user_tables WHERE name = 'Image'")
+	require.Len(t, tables, 1)
+	require.Len(t, tables[0].Indexes, 2)
+
+	_, err := d.Execute(ctx, func(txn qldbdriver.Transaction) (interface{}, error) {
+		return txn.Execute(fmt.Sprintf(`DROP INDEX "%s" ON Image`, tables[0].Indexes[0].IndexID))
+	})
+	assert.ErrorContains(t, err, "expected WITH")
+
+	exec(t, d, fmt.Sprintf(`DROP INDEX "%s" ON Image WITH (purge = true)`, tables[0].Indexes[0].IndexID))
+
+	tables = query[userTable](t, d, "SELECT * FROM information_schema.user_tables WHERE name = 'Image'")
+	require.Len(t, tables[0].Indexes, 1)
+	assert.Equal(t, "[imageId]", tables[0].Indexes[0].Expr)
+
+	var badRequest *types.BadRequestException
+
+	_, err = d.Execute(ctx, func(txn qldbdriver.Transaction) (interface{}, error) {
+		return txn.Execute(fmt.Sprintf(`DROP INDEX "%s" ON Image WITH (purge = true)`, tables[0].Indexes[0].IndexID+"x"))
+	})
+	assert.True(t, errors.As(err, &badRequest))
+}
+
+func TestDriver_Rollback(t *testing.T) {
+	d := NewDriver()
+	exec(t, d, "CREATE TABLE Image")
+
+	errInjected := errors.New("injected")
+	d.FailOn("UPDATE Image", errInjected)
+
+	_, err := d.Execute(context.Background(), func(txn qldbdriver.Transaction) (interface{}, error) {
+		if _, err := txn.Execute("INSERT INTO Image ?", map[string]interface{}{"imageId": "1"}); err != nil {
+			return nil, err
+		}
+
+		return txn.Execute("UPDATE Image AS i SET i.id = 'x'")
+	})
+	assert.ErrorIs(t, err, errInjected)
+	assert.Empty(t, query[map[string]interface{}](t, d, "SELECT * FROM Image"), "the insert must be rolled back")
+
+	d.Shutdown(context.Background())
+
+	_, err = d.GetTableNames(context.Background())
+	assert.ErrorIs(t, err, errDriverClosed)
+}
+
+func TestDriver_UndropTable(t *testing.T) {
+	ctx := context.Background()
+	d := NewDriver()
+	exec(t, d, "CREATE TABLE Share", "CREATE INDEX ON Share(id)")
+	query[map[string]interface{}](t, d, "INSERT INTO Share ?", map[string]interface{}{"id": "s1"})
+	exec(t, d, "DROP TABLE Share")
+
+	type userTable struct {
+		TableID string `ion:"tableId"`
+		Status  string `ion:"status"`
+	}
+
+	tables := query[userTable](t, d, "SELECT * FROM information_schema.user_tables WHERE name = 'Share'")
+	require.Len(t, tables, 1)
+	assert.Equal(t, statusInactive, tables[0].Status)
+
+	_, err := d.Execute(ctx, func(txn qldbdriver.Transaction) (interface{}, error) {
+		return txn.Execute("UNDROP TABLE Share")
+	})
+	assert.ErrorContains(t, err, "Table with id Share does not exist", "UNDROP TABLE takes the table id")
+
+	exec(t, d, fmt.Sprintf(`UNDROP TABLE "%s"`, tables[0].TableID))
+
+	tables = query[userTable](t, d, "SELECT * FROM information_schema.user_tables WHERE name = 'Share'")
+	require.Len(t, tables, 1)
+	assert.Equal(t, statusActive, tables[0].Status)
+	assert.Len(t, query[map[string]interface{}](t, d, "SELECT * FROM Share AS s WHERE s.id = 's1'"), 1)
+}
@@ -0,0 +1,650 @@
+package fake
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// row is a value bound to the FROM source of a statement
+type row struct {
+	value interface{}
+	docID string
+	table *table
+}
+
+// scope evaluates expressions for one statement
+type scope struct {
+	src    source
+	params []interface{}
+}
+
+func (txn *transaction) run(stmt interface{}, params []interface{}) ([]interface{}, error) {
+	switch s := stmt.(type) {
+	case createTableStmt:
+		return txn.createTable(s)
+	case createIndexStmt:
+		return txn.createIndex(s)
+	case dropTableStmt:
+		return txn.dropTable(s)
+	case dropIndexStmt:
+		return txn.dropIndex(s)
+	case undropTableStmt:
+		return txn.undropTable(s)
+	case insertStmt:
+		return txn.insert(s, params)
+	case updateStmt:
+		return txn.update(s, params)
+	case deleteStmt:
+		return txn.delete(s, params)
+	case selectStmt:
+		return txn.selectRows(s, params)
+	default:
+		return nil, fmt.Errorf("unsupported statement %T", stmt)
+	}
+}
+
+func (txn *transaction) createTable(s createTableStmt) ([]interface{}, error) {
+	if txn.state.findTable(s.table, false) != nil {
+		return nil, badRequest("Table with name: %s already exists", s.table)
+	}
+
+	t := &table{
+		id:     txn.state.newID(),
+		name:   s.table,
+		status: statusActive,
+		docs:   map[string]*document{},
+	}
+
+	txn.state.tables[s.table] = t
+	txn.wrote = true
+
+	return []interface{}{map[string]interface{}{"tableId": t.id}}, nil
+}
+
+func (txn *transaction) createIndex(s createIndexStmt) ([]interface{}, error) {
+	t := txn.state.findTable(s.table, false)
+	if t == nil {
+		return nil, noSuchTable(s.table)
+	}
+
+	if t.hasIndex(s.path) {
+		return nil, badRequest("Index on [%s] already exists in table %s", strings.Join(s.path, "."), s.table)
+	}
+
+	t.indexes = append(t.indexes, index{id: txn.state.newID(), path: s.path, createdAt: txn.driver.clock()})
+	txn.wrote = true
+
+	return []interface{}{map[string]interface{}{"tableId": t.id}}, nil
+}
+
+func (txn *transaction) dropIndex(s dropIndexStmt) ([]interface{}, error) {
+	t := txn.state.findTable(s.table, false)
+	if t == nil {
+		return nil, noSuchTable(s.table)
+	}
+
+	// the indexes are shared with the committed state, the index is dropped from a copy
+	indexes := make([]index, 0, len(t.indexes))
+	for _, idx := range t.indexes {
+		if idx.id != s.id {
+			indexes = append(indexes, idx)
+		}
+	}
+
+	if len(indexes) == len(t.indexes) {
+		return nil, badRequest("Index with id %s does not exist in table %s", s.id, s.table)
+	}
+
+	t.indexes = indexes
+	txn.wrote = true
+
+	return []interface{}{map[string]interface{}{"tableId": t.id}}, nil
+}
+
+func (txn *transaction) dropTable(s dropTableStmt) ([]interface{}, error) {
+	t := txn.state.findTable(s.table, false)
+	if t == nil {
+		return nil, noSuchTable(s.table)
+	}
+
+	t.status = statusInactive
+	delete(txn.state.tables, s.table)
+	txn.state.dropped = append(txn.state.dropped, t)
+	txn.wrote = true
+
+	return []interface{}{map[string]interface{}{"tableId": t.id}}, nil
+}
+
+func (txn *transaction) undropTable(s undropTableStmt) ([]interface{}, error) {
+	for i, t := range txn.state.dropped {
+		if t.id != s.id {
+			continue
+		}
+
+		if txn.state.findTable(t.name, false) != nil {
+			return nil, badRequest("Table with name: %s already exists", t.name)
+		}
+
+		// the dropped tables are shared with the committed state, the table is undropped from copies
+		undropped := t.clone()
+		undropped.status = statusActive
+
+		txn.state.tables[t.name] = undropped
+		txn.state.dropped = append(txn.state.dropped[:i:i], txn.state.dropped[i+1:]...)
+		txn.wrote = true
+
+		return []interface{}{map[string]interface{}{"tableId": t.id}}, nil
+	}
+
+	return nil, badRequest("Table with id %s does not exist or is not dropped", s.id)
+}
+
+func (txn *transaction) insert(s insertStmt, params []interface{}) ([]interface{}, error) {
+	t := txn.state.findTable(s.table, false)
+	if t == nil {
+		return nil, noSuchTable(s.table)
+	}
+
+	sc := &scope{params: params}
+
+	var out []interface{}
+
+	for _, valueExpr := range s.values {
+		value, err := sc.eval(valueExpr, row{})
+		if err != nil {
+			return nil, err
+		}
+
+		data, isStruct := value.(map[string]interface{})
+		if !isStruct {
+			return nil, badRequest("Semantic Error: INSERT value must be a struct, found %T", value)
+		}
+
+		doc := &document{id: txn.state.newID()}
+		t.docs[doc.id] = doc
+		t.order = append(t.order, doc.id)
+
+		if err = txn.addRevision(t, doc, data); err != nil {
+			return nil, err
+		}
+
+		out = append(out, map[string]interface{}{"documentId": doc.id})
+	}
+
+	return out, nil
+}
+
+func (txn *transaction) update(s updateStmt, params []interface{}) ([]interface{}, error) {
+	sc := &scope{src: s.source, params: params}
+
+	rows, err := txn.matching(sc, s.where)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []interface{}
+
+	for _, r := range rows {
+		data := copyStruct(r.value.(map[string]interface{}))
+
+		for _, set := range s.sets {
+			value, err := sc.eval(set.value, r)
+			if err != nil {
+				return nil, err
+			}
+
+			if data, err = assign(data, sc.fieldPath(set.path), value); err != nil {
+				return nil, err
+			}
+		}
+
+		if err = txn.addRevision(r.table, r.table.docs[r.docID], data); err != nil {
+			return nil, err
+		}
+
+		out = append(out, map[string]interface{}{"documentId": r.docID})
+	}
+
+	return out, nil
+}
+
+func (txn *transaction) delete(s deleteStmt, params []interface{}) ([]interface{}, error) {
+	sc := &scope{src: s.source, params: params}
+
+	rows, err := txn.matching(sc, s.where)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []interface{}
+
+	for _, r := range rows {
+		if err = txn.addRevision(r.table, r.table.docs[r.docID], nil); err != nil {
+			return nil, err
+		}
+
+		out = append(out, map[string]interface{}{"documentId": r.docID})
+	}
+
+	return out, nil
+}
+
+// matching returns the current documents of a table source satisfying where
+func (txn *transaction) matching(sc *scope, where expr) ([]row, error) {
+	rows, err := txn.rows(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	return sc.filter(rows, where)
+}
+
+func (txn *transaction) selectRows(s selectStmt, params []interface{}) ([]interface{}, error) {
+	sc := &scope{src: s.source, params: params}
+
+	rows, err := txn.matching(sc, s.where)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.star {
+		out := make([]interface{}, 0, len(rows))
+		for _, r := range rows {
+			out = append(out, r.value)
+		}
+
+		return out, nil
+	}
+
+	if isAggregate(s.items) {
+		return sc.aggregate(s.items, rows)
+	}
+
+	out := make([]interface{}, 0, len(rows))
+
+	for _, r := range rows {
+		projected := map[string]interface{}{}
+
+		for _, item := range s.items {
+			value, err := sc.eval(item.expr, r)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, isMissing := value.(missing); isMissing {
+				continue
+			}
+
+			if !item.spread {
+				projected[item.name] = value
+				continue
+			}
+
+			if fields, isStruct := value.(map[string]interface{}); isStruct {
+				for name, field := range fields {
+					projected[name] = field
+				}
+			}
+		}
+
+		out = append(out, projected)
+	}
+
+	return out, nil
+}
+
+// rows lists the values of the FROM source of sc
+func (txn *transaction) rows(sc *scope) ([]row, error) {
+	src := sc.src
+
+	if src.kind == sourceUserTables {
+		return txn.userTables(), nil
+	}
+
+	t := txn.state.findTable(src.table, src.kind == sourceHistory)
+	if t == nil {
+		return nil, noSuchTable(src.table)
+	}
+
+	var rows []row
+
+	for _, id := range t.order {
+		doc := t.docs[id]
+
+		switch src.kind {
+		case sourceTable:
+			if current := doc.current(); current != nil {
+				rows = append(rows, row{value: current.data, docID: id, table: t})
+			}
+		case sourceCommitted:
+			if current := doc.current(); current != nil {
+				rows = append(rows, row{value: revisionValue(id, current), docID: id, table: t})
+			}
+		case sourceHistory:
+			for i := range doc.revisions {
+				rows = append(rows, row{value: revisionValue(id, &doc.revisions[i]), docID: id, table: t})
+			}
+		}
+	}
+
+	if src.kind == sourceHistory {
+		return sc.withinBounds(rows)
+	}
+
+	return rows, nil
+}
+
+// withinBounds keeps the history rows committed between the start and end time of history(t, start, end)
+func (sc *scope) withinBounds(rows []row) ([]row, error) {
+	src := sc.src
+	if src.start == nil {
+		return rows, nil
+	}
+
+	start, err := sc.eval(src.start, row{})
+	if err != nil {
+		return nil, err
+	}
+
+	var end interface{} = missing{}
+	if src.end != nil {
+		if end, err = sc.eval(src.end, row{}); err != nil {
+			return nil, err
+		}
+	}
+
+	var bounded []row
+
+	for _, r := range rows {
+		txTime := r.value.(map[string]interface{})["metadata"].(map[string]interface{})["txTime"]
+
+		if c, ok := compare(txTime, start); !ok || c < 0 {
+			continue
+		}
+
+		if _, noEnd := end.(missing); !noEnd {
+			if c, ok := compare(txTime, end); !ok || c > 0 {
+				continue
+			}
+		}
+
+		bounded = append(bounded, r)
+	}
+
+	return bounded, nil
+}
+
+func (txn *transaction) userTables() []row {
+	tables := make([]*table, 0, len(txn.state.tables)+len(txn.state.dropped))
+	for _, t := range txn.state.tables {
+		tables = append(tables, t)
+	}
+
+	tables = append(tables, txn.state.dropped...)
+
+	sort.SliceStable(tables, func(i, j int) bool { return tables[i].name < tables[j].name })
+
+	rows := make([]row, 0, len(tables))
+	for _, t := range tables {
+		rows = append(rows, row{value: t.userTableValue(txn.driver.clock(), txn.driver.indexBuildTime)})
+	}
+
+	return rows
+}
+
+// addRevision records the new data of doc, nil data records a delete. QLDB commits one revision per
+// document per transaction: a document written again by the transaction keeps the version of its first
+// write, and a document inserted then deleted by the same transaction leaves no revision
+func (txn *transaction) addRevision(t *table, doc *document, data map[string]interface{}) error {
+	r := revision{
+		data:         data,
+		txTime:       txn.txTime,
+		txID:         txn.id,
+		blockAddress: txn.block,
+	}
+
+	// the committed revisions are shared with the ledger, they are never modified in place
+	revisions := doc.revisions
+	if n := len(revisions); n > 0 && revisions[n-1].txID == txn.id {
+		revisions = revisions[: n-1 : n-1]
+	}
+
+	if len(revisions) == 0 && data == nil {
+		t.forget(doc.id)
+		txn.wrote = true
+
+		return nil
+	}
+
+	if len(revisions) > 0 {
+		r.version = revisions[len(revisions)-1].version + 1
+	}
+
+	hash, err := revisionHash(data, revisionMetadata(doc.id, &r))
+	if err != nil {
+		return err
+	}
+
+	r.hash = hash
+	doc.revisions = append(revisions, r)
+	txn.wrote = true
+
+	return nil
+}
+
+func (sc *scope) filter(rows []row, where expr) ([]row, error) {
+	if where == nil {
+		return rows, nil
+	}
+
+	var matched []row
+
+	for _, r := range rows {
+		value, err := sc.eval(where, r)
+		if err != nil {
+			return nil, err
+		}
+
+		if value == true {
+			matched = append(matched, r)
+		}
+	}
+
+	return matched, nil
+}
+
+func (sc *scope) eval(e expr, r row) (interface{}, error) {
+	switch ex := e.(type) {
+	case literalExpr:
+		return ex.value, nil
+	case paramExpr:
+		if ex.index >= len(sc.params) {
+			return nil, badRequest("Semantic Error: missing value for parameter %d", ex.index+1)
+		}
+
+		return sc.params[ex.index], nil
+	case pathExpr:
+		return sc.resolve(ex, r), nil
+	case notExpr:
+		value, err := sc.eval(ex.operand, r)
+		return value == false, err
+	case binaryExpr:
+		return sc.binary(ex, r)
+	case callExpr:
+		return nil, badRequest("Semantic Error: %s() is only supported as a SELECT aggregate", ex.name)
+	default:
+		return nil, fmt.Errorf("unsupported expression %T", e)
+	}
+}
+
+func (sc *scope) binary(ex binaryExpr, r row) (interface{}, error) {
+	left, err := sc.eval(ex.left, r)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := sc.eval(ex.right, r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ex.op {
+	case "AND":
+		return left == true && right == true, nil
+	case "OR":
+		return left == true || right == true, nil
+	}
+
+	c, ok := compare(left, right)
+	if !ok {
+		// missing, null and values of different types never match
+		return false, nil
+	}
+
+	switch ex.op {
+	case "=":
+		return c == 0, nil
+	case "<>":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+// fieldPath strips the source alias from a path, what remains is relative to the row value
+func (sc *scope) fieldPath(path pathExpr) []string {
+	if sc.src.alias != "" && strings.EqualFold(path.parts[0], sc.src.alias) {
+		return path.parts[1:]
+	}
+
+	return path.parts
+}
+
+func (sc *scope) resolve(path pathExpr, r row) interface{} {
+	if sc.src.byAlias != "" && len(path.parts) == 1 && strings.EqualFold(path.parts[0], sc.src.byAlias) {
+		return r.docID
+	}
+
+	var value interface{} = r.value
+
+	for _, part := range sc.fieldPath(path) {
+		fields, isStruct := value.(map[string]interface{})
+		if !isStruct {
+			return missing{}
+		}
+
+		value = lookup(fields, part)
+	}
+
+	return value
+}
+
+// lookup finds a struct field, PartiQL matches unquoted names case-insensitively
+func lookup(fields map[string]interface{}, name string) interface{} {
+	if value, ok := fields[name]; ok {
+		return value
+	}
+
+	for field, value := range fields {
+		if strings.EqualFold(field, name) {
+			return value
+		}
+	}
+
+	return missing{}
+}
+
+func isAggregate(items []selectItem) bool {
+	for _, item := range items {
+		if _, isCall := item.expr.(callExpr); isCall {
+			return true
+		}
+	}
+
+	return false
+}
+
+// aggregate computes the count() items of a SELECT over all the rows
+func (sc *scope) aggregate(items []selectItem, rows []row) ([]interface{}, error) {
+	projected := map[string]interface{}{}
+
+	for _, item := range items {
+		call, isCall := item.expr.(callExpr)
+		if !isCall || call.name != "count" {
+			return nil, badRequest("Semantic Error: only count() can be mixed in an aggregate SELECT")
+		}
+
+		count := 0
+
+		for _, r := range rows {
+			if call.star {
+				count++
+				continue
+			}
+
+			if len(call.args) != 1 {
+				return nil, badRequest("Semantic Error: count() takes one argument")
+			}
+
+			value, err := sc.eval(call.args[0], r)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, isMissing := value.(missing); !isMissing && value != nil {
+				count++
+			}
+		}
+
+		projected[item.name] = big.NewInt(int64(count))
+	}
+
+	return []interface{}{projected}, nil
+}
+
+// assign sets the value at path in data, an empty path replaces the whole document
+func assign(data map[string]interface{}, path []string, value interface{}) (map[string]interface{}, error) {
+	if len(path) == 0 {
+		replacement, isStruct := value.(map[string]interface{})
+		if !isStruct {
+			return nil, badRequest("Semantic Error: a document can only be replaced by a struct")
+		}
+
+		return replacement, nil
+	}
+
+	parent := data
+
+	for _, part := range path[:len(path)-1] {
+		child, isStruct := parent[part].(map[string]interface{})
+		if !isStruct {
+			child = map[string]interface{}{}
+		} else {
+			child = copyStruct(child)
+		}
+
+		parent[part] = child
+		parent = child
+	}
+
+	parent[path[len(path)-1]] = value
+
+	return data, nil
+}
+
+func copyStruct(fields map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		c[name] = value
+	}
+
+	return c
+}
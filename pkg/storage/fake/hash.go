@@ -0,0 +1,20 @@
+package fake
+
+import (
+	"github.com/carflores-zh/qldb-go/pkg/verify"
+)
+
+// revisionHash is the QLDB revision hash, computed the same way clients verify it
+func revisionHash(data, metadata map[string]interface{}) ([]byte, error) {
+	revision := map[string]interface{}{"metadata": metadata}
+	if data != nil {
+		revision["data"] = data
+	}
+
+	encoded, err := encode(revision)
+	if err != nil {
+		return nil, err
+	}
+
+	return verify.RevisionHash(encoded)
+}
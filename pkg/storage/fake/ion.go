@@ -0,0 +1,281 @@
+package fake
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+// Documents are held as plain Go values decoded from Ion:
+// nil, bool, *big.Int, float64, *ion.Decimal, ion.Timestamp, string, []byte, []interface{} and map[string]interface{}
+
+// missing is the PartiQL MISSING value, returned when a path doesn't resolve
+type missing struct{}
+
+// toValue converts a statement parameter to a document value by round-tripping it through Ion
+func toValue(parameter interface{}) (interface{}, error) {
+	data, err := ion.MarshalBinary(parameter)
+	if err != nil {
+		return nil, err
+	}
+
+	return decode(data)
+}
+
+// decode reads the first Ion value of data
+func decode(data []byte) (interface{}, error) {
+	reader := ion.NewReaderBytes(data)
+	if !reader.Next() {
+		if reader.Err() != nil {
+			return nil, reader.Err()
+		}
+
+		return nil, fmt.Errorf("no ion value")
+	}
+
+	return readValue(reader)
+}
+
+func readValue(reader ion.Reader) (interface{}, error) {
+	if reader.IsNull() {
+		return nil, nil
+	}
+
+	switch reader.Type() {
+	case ion.BoolType:
+		v, err := reader.BoolValue()
+		return *v, err
+	case ion.IntType:
+		return reader.BigIntValue()
+	case ion.FloatType:
+		v, err := reader.FloatValue()
+		return *v, err
+	case ion.DecimalType:
+		return reader.DecimalValue()
+	case ion.TimestampType:
+		v, err := reader.TimestampValue()
+		return *v, err
+	case ion.StringType:
+		v, err := reader.StringValue()
+		return *v, err
+	case ion.SymbolType:
+		v, err := reader.SymbolValue()
+		if err != nil || v.Text == nil {
+			return nil, err
+		}
+
+		return *v.Text, nil
+	case ion.BlobType, ion.ClobType:
+		return reader.ByteValue()
+	case ion.ListType, ion.SexpType:
+		return readList(reader)
+	case ion.StructType:
+		return readStruct(reader)
+	default:
+		return nil, fmt.Errorf("unsupported ion type %v", reader.Type())
+	}
+}
+
+func readList(reader ion.Reader) (interface{}, error) {
+	if err := reader.StepIn(); err != nil {
+		return nil, err
+	}
+
+	list := []interface{}{}
+
+	for reader.Next() {
+		v, err := readValue(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, v)
+	}
+
+	if reader.Err() != nil {
+		return nil, reader.Err()
+	}
+
+	return list, reader.StepOut()
+}
+
+func readStruct(reader ion.Reader) (interface{}, error) {
+	if err := reader.StepIn(); err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+
+	for reader.Next() {
+		name, err := reader.FieldName()
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := readValue(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		if name != nil && name.Text != nil {
+			fields[*name.Text] = v
+		}
+	}
+
+	if reader.Err() != nil {
+		return nil, reader.Err()
+	}
+
+	return fields, reader.StepOut()
+}
+
+// encode writes v as Ion binary
+func encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writer := ion.NewBinaryWriter(&buf)
+	if err := writeValue(writer, v); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Finish(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeValue(writer ion.Writer, v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		return writer.WriteNull()
+	case bool:
+		return writer.WriteBool(value)
+	case int:
+		return writer.WriteInt(int64(value))
+	case int64:
+		return writer.WriteInt(value)
+	case *big.Int:
+		return writer.WriteBigInt(value)
+	case float64:
+		return writer.WriteFloat(value)
+	case *ion.Decimal:
+		return writer.WriteDecimal(value)
+	case ion.Timestamp:
+		return writer.WriteTimestamp(value)
+	case time.Time:
+		return writer.WriteTimestamp(ion.NewTimestamp(value, ion.TimestampPrecisionNanosecond, ion.TimezoneUTC))
+	case string:
+		return writer.WriteString(value)
+	case []byte:
+		return writer.WriteBlob(value)
+	case []interface{}:
+		if err := writer.BeginList(); err != nil {
+			return err
+		}
+
+		for _, item := range value {
+			if err := writeValue(writer, item); err != nil {
+				return err
+			}
+		}
+
+		return writer.EndList()
+	case map[string]interface{}:
+		return writeStruct(writer, value)
+	default:
+		return fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+func writeStruct(writer ion.Writer, fields map[string]interface{}) error {
+	if err := writer.BeginStruct(); err != nil {
+		return err
+	}
+
+	// sorted names keep the encoding deterministic
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := writer.FieldName(ion.NewSymbolTokenFromString(name)); err != nil {
+			return err
+		}
+
+		if err := writeValue(writer, fields[name]); err != nil {
+			return err
+		}
+	}
+
+	return writer.EndStruct()
+}
+
+// compare orders two scalar values, ok is false when they are not comparable
+func compare(a, b interface{}) (result int, ok bool) {
+	switch x := a.(type) {
+	case *big.Int:
+		switch y := b.(type) {
+		case *big.Int:
+			return x.Cmp(y), true
+		case float64:
+			f, _ := new(big.Float).SetInt(x).Float64()
+			return compareFloat(f, y), true
+		}
+	case float64:
+		switch y := b.(type) {
+		case float64:
+			return compareFloat(x, y), true
+		case *big.Int:
+			f, _ := new(big.Float).SetInt(y).Float64()
+			return compareFloat(x, f), true
+		}
+	case *ion.Decimal:
+		if y, isDecimal := b.(*ion.Decimal); isDecimal {
+			return x.Cmp(y), true
+		}
+	case string:
+		if y, isString := b.(string); isString {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	case bool:
+		if y, isBool := b.(bool); isBool && x == y {
+			return 0, true
+		}
+	case ion.Timestamp:
+		if y, isTimestamp := b.(ion.Timestamp); isTimestamp {
+			return x.GetDateTime().Compare(y.GetDateTime()), true
+		}
+	case []byte:
+		if y, isBytes := b.([]byte); isBytes {
+			return bytes.Compare(x, y), true
+		}
+	}
+
+	return 0, false
+}
+
+func compareFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
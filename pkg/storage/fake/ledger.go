@@ -0,0 +1,222 @@
+package fake
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+const (
+	statusActive   = "ACTIVE"
+	statusInactive = "INACTIVE"
+	indexOnline    = "ONLINE"
+	indexBuilding  = "BUILDING"
+	strandID       = "JdxjkR9bSYB5jMHWcI464T"
+	idLength       = 22
+)
+
+// ledger is the committed state of the fake, transactions work on a copy and replace it on commit
+type ledger struct {
+	tables     map[string]*table // active tables by name
+	dropped    []*table
+	blockSeq   int64
+	idSequence int64
+}
+
+type table struct {
+	id      string
+	name    string
+	status  string
+	indexes []index
+	docs    map[string]*document
+	order   []string // document ids in insertion order
+}
+
+type index struct {
+	id        string
+	path      []string
+	createdAt time.Time
+}
+
+type document struct {
+	id        string
+	revisions []revision
+}
+
+// revision is one committed version of a document, data is nil for the revision recording a delete
+type revision struct {
+	data         map[string]interface{}
+	version      int
+	txTime       ion.Timestamp
+	txID         string
+	blockAddress int64
+	hash         []byte
+}
+
+func newLedger() *ledger {
+	return &ledger{tables: map[string]*table{}}
+}
+
+// clone copies the ledger so a transaction can change it without touching the committed state.
+// Revision data is never mutated in place, so documents only need a capped copy of their revisions
+func (l *ledger) clone() *ledger {
+	c := &ledger{
+		tables:     make(map[string]*table, len(l.tables)),
+		dropped:    l.dropped[:len(l.dropped):len(l.dropped)],
+		blockSeq:   l.blockSeq,
+		idSequence: l.idSequence,
+	}
+
+	for name, t := range l.tables {
+		c.tables[name] = t.clone()
+	}
+
+	return c
+}
+
+func (t *table) clone() *table {
+	c := &table{
+		id:      t.id,
+		name:    t.name,
+		status:  t.status,
+		indexes: t.indexes[:len(t.indexes):len(t.indexes)],
+		docs:    make(map[string]*document, len(t.docs)),
+		order:   t.order[:len(t.order):len(t.order)],
+	}
+
+	for id, doc := range t.docs {
+		c.docs[id] = &document{id: doc.id, revisions: doc.revisions[:len(doc.revisions):len(doc.revisions)]}
+	}
+
+	return c
+}
+
+// newID returns a QLDB-like 22 characters base62 ID
+func (l *ledger) newID() string {
+	const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	l.idSequence++
+
+	b := make([]byte, idLength)
+	if _, err := rand.Read(b); err != nil {
+		// fall back to a sequence based ID, uniqueness is all the fake needs
+		return big.NewInt(l.idSequence).Text(62)
+	}
+
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+
+	return string(b)
+}
+
+// findTable looks up an active table, or a dropped one when includeDropped is set (history keeps working after DROP)
+func (l *ledger) findTable(name string, includeDropped bool) *table {
+	if t, ok := l.tables[name]; ok {
+		return t
+	}
+
+	if includeDropped {
+		for i := len(l.dropped) - 1; i >= 0; i-- {
+			if l.dropped[i].name == name {
+				return l.dropped[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+func (t *table) hasIndex(path []string) bool {
+	for _, idx := range t.indexes {
+		if strings.Join(idx.path, ".") == strings.Join(path, ".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forget removes a document that has no revision, the order is shared with the ledger and is copied
+func (t *table) forget(id string) {
+	delete(t.docs, id)
+
+	order := make([]string, 0, len(t.order))
+	for _, docID := range t.order {
+		if docID != id {
+			order = append(order, docID)
+		}
+	}
+
+	t.order = order
+}
+
+// current returns the latest revision of the document, or nil if it has been deleted
+func (d *document) current() *revision {
+	latest := &d.revisions[len(d.revisions)-1]
+	if latest.data == nil {
+		return nil
+	}
+
+	return latest
+}
+
+// revisionValue is the revision as returned by history() and committed views
+func revisionValue(docID string, r *revision) map[string]interface{} {
+	value := map[string]interface{}{
+		"blockAddress": map[string]interface{}{
+			"strandId":   strandID,
+			"sequenceNo": big.NewInt(r.blockAddress),
+		},
+		"hash":     r.hash,
+		"metadata": revisionMetadata(docID, r),
+	}
+
+	if r.data != nil {
+		value["data"] = r.data
+	}
+
+	return value
+}
+
+func revisionMetadata(docID string, r *revision) map[string]interface{} {
+	return map[string]interface{}{
+		"id":      docID,
+		"version": big.NewInt(int64(r.version)),
+		"txTime":  r.txTime,
+		"txId":    r.txID,
+	}
+}
+
+// userTableValue is the table as returned by information_schema.user_tables at now,
+// indexes are BUILDING for buildTime after they are created
+func (t *table) userTableValue(now time.Time, buildTime time.Duration) map[string]interface{} {
+	indexes := make([]interface{}, 0, len(t.indexes))
+	for _, idx := range t.indexes {
+		status := indexOnline
+		if now.Before(idx.createdAt.Add(buildTime)) {
+			status = indexBuilding
+		}
+
+		indexes = append(indexes, map[string]interface{}{
+			"indexId": idx.id,
+			"expr":    "[" + strings.Join(idx.path, ".") + "]",
+			"status":  status,
+		})
+	}
+
+	return map[string]interface{}{
+		"name":    t.name,
+		"tableId": t.id,
+		"status":  t.status,
+		"indexes": indexes,
+	}
+}
+
+func timestampOf(t time.Time) ion.Timestamp {
+	// QLDB records txTime with millisecond precision
+	return ion.NewTimestampWithFractionalSeconds(t.UTC().Truncate(time.Millisecond), ion.TimestampPrecisionNanosecond, ion.TimezoneUTC, 3)
+}
@@ -0,0 +1,733 @@
+package fake
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/carflores-zh/qldb-go/pkg/internal/partiql"
+)
+
+// This file parses the PartiQL subset understood by the fake driver:
+//
+//	CREATE TABLE t | CREATE INDEX ON t (path) | DROP TABLE t | DROP INDEX "id" ON t WITH (purge = true)
+//	UNDROP TABLE "id"
+//	INSERT INTO t value | INSERT INTO t << value, ... >>
+//	UPDATE t [AS a] SET path = expr, ... [WHERE cond]
+//	DELETE FROM t [AS a] [WHERE cond]
+//	SELECT * | items FROM source [AS a] [BY id] [WHERE cond]
+//
+// where source is a table, _ql_committed_t, history(t [, start [, end]]) or information_schema.user_tables,
+// and cond combines equality/comparison predicates with AND, OR, NOT and parentheses
+
+// eof ends the tokens of a statement, it is not a kind of the partiql lexer
+const eof partiql.Kind = -1
+
+// lex splits statement with the lexer shared with the storage package, so both read statements the same way
+func lex(statement string) ([]partiql.Token, error) {
+	tokens, err := partiql.Lex(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	// a statement may end with a semicolon
+	if n := len(tokens); n > 0 && tokens[n-1].Kind == partiql.Punctuation && tokens[n-1].Text == ";" {
+		tokens = tokens[:n-1]
+	}
+
+	return append(tokens, partiql.Token{Kind: eof, Offset: len([]rune(statement))}), nil
+}
+
+type (
+	createTableStmt struct{ table string }
+	createIndexStmt struct {
+		table string
+		path  []string
+	}
+	dropTableStmt   struct{ table string }
+	undropTableStmt struct{ id string }
+	dropIndexStmt   struct {
+		table string
+		id    string
+	}
+	insertStmt struct {
+		table  string
+		values []expr
+	}
+	updateStmt struct {
+		source source
+		sets   []assignment
+		where  expr
+	}
+	deleteStmt struct {
+		source source
+		where  expr
+	}
+	selectStmt struct {
+		star   bool
+		items  []selectItem
+		source source
+		where  expr
+	}
+)
+
+type assignment struct {
+	path  pathExpr
+	value expr
+}
+
+type selectItem struct {
+	expr   expr
+	name   string
+	spread bool // path.*
+}
+
+type sourceKind int
+
+const (
+	sourceTable sourceKind = iota
+	sourceCommitted
+	sourceHistory
+	sourceUserTables
+)
+
+type source struct {
+	kind       sourceKind
+	table      string
+	alias      string
+	byAlias    string
+	start, end expr
+}
+
+type (
+	expr     interface{}
+	pathExpr struct {
+		parts []string
+	}
+	paramExpr   struct{ index int }
+	literalExpr struct{ value interface{} }
+	binaryExpr  struct {
+		op          string
+		left, right expr
+	}
+	notExpr  struct{ operand expr }
+	callExpr struct {
+		name string
+		star bool
+		args []expr
+	}
+)
+
+type parser struct {
+	tokens []partiql.Token
+	pos    int
+	params int
+}
+
+func parse(statement string) (interface{}, error) {
+	tokens, err := lex(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+
+	stmt, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().Kind != eof {
+		return nil, fmt.Errorf("unexpected %q", p.peek().Text)
+	}
+
+	return stmt, nil
+}
+
+func (p *parser) peek() partiql.Token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() partiql.Token {
+	t := p.tokens[p.pos]
+	if t.Kind != eof {
+		p.pos++
+	}
+
+	return t
+}
+
+// isKeyword reports if the current token is the keyword kw, keywords are case-insensitive
+func (p *parser) isKeyword(kw string) bool {
+	return p.peek().Keyword(kw)
+}
+
+func (p *parser) acceptKeyword(kw string) bool {
+	if p.isKeyword(kw) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.acceptKeyword(kw) {
+		return fmt.Errorf("expected %s, found %q", kw, p.peek().Text)
+	}
+
+	return nil
+}
+
+func (p *parser) acceptPunct(punct string) bool {
+	if t := p.peek(); t.Kind == partiql.Punctuation && t.Text == punct {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) expectPunct(punct string) error {
+	if !p.acceptPunct(punct) {
+		return fmt.Errorf("expected %s, found %q", punct, p.peek().Text)
+	}
+
+	return nil
+}
+
+// identifier reads a name, QLDB rejects reserved words unless they are quoted, e.g. "to"
+func (p *parser) identifier() (string, error) {
+	t := p.next()
+	if t.Kind != partiql.Word && t.Kind != partiql.QuotedIdent {
+		return "", fmt.Errorf("expected identifier, found %q", t.Text)
+	}
+
+	if t.Kind == partiql.Word && partiql.Reserved(t.Text) {
+		return "", fmt.Errorf("unexpected reserved word %s, quote it to use it as a name", t.Text)
+	}
+
+	return t.Text, nil
+}
+
+func (p *parser) statement() (interface{}, error) {
+	switch {
+	case p.acceptKeyword("CREATE"):
+		return p.create()
+	case p.acceptKeyword("DROP"):
+		return p.drop()
+	case p.acceptKeyword("UNDROP"):
+		if err := p.expectKeyword("TABLE"); err != nil {
+			return nil, err
+		}
+
+		id, err := p.identifier()
+
+		return undropTableStmt{id: id}, err
+	case p.acceptKeyword("INSERT"):
+		return p.insert()
+	case p.acceptKeyword("UPDATE"):
+		return p.update()
+	case p.acceptKeyword("DELETE"):
+		return p.delete()
+	case p.acceptKeyword("SELECT"):
+		return p.selectStatement()
+	default:
+		return nil, fmt.Errorf("unsupported statement starting with %q", p.peek().Text)
+	}
+}
+
+func (p *parser) drop() (interface{}, error) {
+	if p.acceptKeyword("TABLE") {
+		table, err := p.identifier()
+		return dropTableStmt{table: table}, err
+	}
+
+	if err := p.expectKeyword("INDEX"); err != nil {
+		return nil, err
+	}
+
+	id, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.expectKeyword("ON"); err != nil {
+		return nil, err
+	}
+
+	table, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+
+	// QLDB requires WITH (purge = true), the only option of DROP INDEX
+	for _, want := range []string{"WITH", "(", "purge", "=", "true", ")"} {
+		if t := p.next(); (t.Kind != partiql.Word && t.Kind != partiql.Punctuation) || !strings.EqualFold(t.Text, want) {
+			return nil, fmt.Errorf("expected %s, found %q", want, t.Text)
+		}
+	}
+
+	return dropIndexStmt{table: table, id: id}, nil
+}
+
+func (p *parser) create() (interface{}, error) {
+	if p.acceptKeyword("TABLE") {
+		table, err := p.identifier()
+		return createTableStmt{table: table}, err
+	}
+
+	if err := p.expectKeyword("INDEX"); err != nil {
+		return nil, err
+	}
+
+	if err := p.expectKeyword("ON"); err != nil {
+		return nil, err
+	}
+
+	table, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.expectPunct("("); err != nil {
+		return nil, err
+	}
+
+	path, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+
+	return createIndexStmt{table: table, path: path.parts}, p.expectPunct(")")
+}
+
+func (p *parser) insert() (interface{}, error) {
+	if err := p.expectKeyword("INTO"); err != nil {
+		return nil, err
+	}
+
+	table, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := insertStmt{table: table}
+
+	if !p.acceptPunct("<<") {
+		value, err := p.operand()
+		stmt.values = append(stmt.values, value)
+
+		return stmt, err
+	}
+
+	for {
+		value, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+
+		stmt.values = append(stmt.values, value)
+
+		if !p.acceptPunct(",") {
+			break
+		}
+	}
+
+	return stmt, p.expectPunct(">>")
+}
+
+func (p *parser) update() (interface{}, error) {
+	src, err := p.tableSource()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := updateStmt{source: src}
+
+	if err = p.expectKeyword("SET"); err != nil {
+		return nil, err
+	}
+
+	for {
+		path, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = p.expectPunct("="); err != nil {
+			return nil, err
+		}
+
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+
+		stmt.sets = append(stmt.sets, assignment{path: path, value: value})
+
+		if !p.acceptPunct(",") {
+			break
+		}
+	}
+
+	stmt.where, err = p.where()
+
+	return stmt, err
+}
+
+func (p *parser) delete() (interface{}, error) {
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+
+	src, err := p.tableSource()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := deleteStmt{source: src}
+	stmt.where, err = p.where()
+
+	return stmt, err
+}
+
+func (p *parser) selectStatement() (interface{}, error) {
+	stmt := selectStmt{}
+
+	if p.acceptPunct("*") {
+		stmt.star = true
+	} else {
+		for {
+			item, err := p.selectItem(len(stmt.items) + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			stmt.items = append(stmt.items, item)
+
+			if !p.acceptPunct(",") {
+				break
+			}
+		}
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+
+	src, err := p.selectSource()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt.source = src
+	stmt.where, err = p.where()
+
+	return stmt, err
+}
+
+func (p *parser) selectItem(position int) (selectItem, error) {
+	value, err := p.operand()
+	if err != nil {
+		return selectItem{}, err
+	}
+
+	item := selectItem{expr: value, name: "_" + strconv.Itoa(position)}
+
+	if path, isPath := value.(pathExpr); isPath {
+		item.name = path.parts[len(path.parts)-1]
+
+		if p.acceptPunct(".") {
+			if err = p.expectPunct("*"); err != nil {
+				return selectItem{}, err
+			}
+
+			item.spread = true
+		}
+	}
+
+	if p.acceptKeyword("AS") {
+		item.name, err = p.identifier()
+	}
+
+	return item, err
+}
+
+// tableSource parses the target of UPDATE and DELETE
+func (p *parser) tableSource() (source, error) {
+	table, err := p.identifier()
+	if err != nil {
+		return source{}, err
+	}
+
+	src := source{kind: sourceTable, table: table}
+
+	return src, p.aliases(&src)
+}
+
+func (p *parser) selectSource() (source, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return source{}, err
+	}
+
+	src := source{kind: sourceTable, table: name}
+
+	switch {
+	case strings.EqualFold(name, "history") && p.acceptPunct("("):
+		src.kind = sourceHistory
+
+		if src.table, err = p.identifier(); err != nil {
+			return source{}, err
+		}
+
+		if p.acceptPunct(",") {
+			if src.start, err = p.operand(); err != nil {
+				return source{}, err
+			}
+
+			if p.acceptPunct(",") {
+				if src.end, err = p.operand(); err != nil {
+					return source{}, err
+				}
+			}
+		}
+
+		if err = p.expectPunct(")"); err != nil {
+			return source{}, err
+		}
+	case strings.EqualFold(name, "information_schema") && p.acceptPunct("."):
+		table, err := p.identifier()
+		if err != nil {
+			return source{}, err
+		}
+
+		if !strings.EqualFold(table, "user_tables") {
+			return source{}, fmt.Errorf("unsupported information_schema view %q", table)
+		}
+
+		src.kind = sourceUserTables
+	case strings.HasPrefix(name, "_ql_committed_"):
+		src.kind = sourceCommitted
+		src.table = strings.TrimPrefix(name, "_ql_committed_")
+	}
+
+	return src, p.aliases(&src)
+}
+
+func (p *parser) aliases(src *source) (err error) {
+	if p.acceptKeyword("AS") {
+		if src.alias, err = p.identifier(); err != nil {
+			return err
+		}
+	} else if t := p.peek(); t.Kind == partiql.Word && !partiql.Reserved(t.Text) {
+		src.alias = p.next().Text
+	}
+
+	if p.acceptKeyword("BY") {
+		src.byAlias, err = p.identifier()
+	}
+
+	return err
+}
+
+func (p *parser) where() (expr, error) {
+	if !p.acceptKeyword("WHERE") {
+		return nil, nil
+	}
+
+	return p.expression()
+}
+
+func (p *parser) expression() (expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.acceptKeyword("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+
+		left = binaryExpr{op: "OR", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) and() (expr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.acceptKeyword("AND") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+
+		left = binaryExpr{op: "AND", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) not() (expr, error) {
+	if p.acceptKeyword("NOT") {
+		operand, err := p.not()
+		return notExpr{operand: operand}, err
+	}
+
+	return p.comparison()
+}
+
+func (p *parser) comparison() (expr, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range []string{"=", "<>", "!=", "<=", ">=", "<", ">"} {
+		if p.acceptPunct(op) {
+			right, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+
+			if op == "!=" {
+				op = "<>"
+			}
+
+			return binaryExpr{op: op, left: left, right: right}, nil
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) operand() (expr, error) {
+	t := p.peek()
+
+	switch t.Kind {
+	case partiql.Parameter:
+		p.next()
+		p.params++
+
+		return paramExpr{index: p.params - 1}, nil
+	case partiql.String:
+		p.next()
+		return literalExpr{value: t.Text}, nil
+	case partiql.Number:
+		p.next()
+		return numberLiteral(t.Text)
+	case partiql.Ion:
+		p.next()
+
+		value, err := decode([]byte(t.Text))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ion literal `%s`: %w", t.Text, err)
+		}
+
+		return literalExpr{value: value}, nil
+	case partiql.Punctuation:
+		if p.acceptPunct("(") {
+			inner, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+
+			return inner, p.expectPunct(")")
+		}
+	case partiql.Word, partiql.QuotedIdent:
+		if t.Kind == partiql.Word {
+			switch strings.ToUpper(t.Text) {
+			case "TRUE", "FALSE":
+				p.next()
+				return literalExpr{value: strings.EqualFold(t.Text, "true")}, nil
+			case "NULL":
+				p.next()
+				return literalExpr{value: nil}, nil
+			}
+
+			if p.tokens[p.pos+1].Kind == partiql.Punctuation && p.tokens[p.pos+1].Text == "(" {
+				return p.call()
+			}
+		}
+
+		return p.path()
+	}
+
+	return nil, fmt.Errorf("unexpected %q", t.Text)
+}
+
+func (p *parser) call() (expr, error) {
+	name := p.next().Text
+	p.next() // (
+
+	call := callExpr{name: strings.ToLower(name)}
+
+	if p.acceptPunct("*") {
+		call.star = true
+		return call, p.expectPunct(")")
+	}
+
+	for !p.acceptPunct(")") {
+		arg, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+
+		call.args = append(call.args, arg)
+
+		if !p.acceptPunct(",") {
+			if err = p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+
+			break
+		}
+	}
+
+	return call, nil
+}
+
+// path parses a.b.c, it leaves a trailing .* for the caller
+func (p *parser) path() (pathExpr, error) {
+	first, err := p.identifier()
+	if err != nil {
+		return pathExpr{}, err
+	}
+
+	path := pathExpr{parts: []string{first}}
+
+	for p.peek().Kind == partiql.Punctuation && p.peek().Text == "." {
+		nextToken := p.tokens[p.pos+1]
+		if nextToken.Kind != partiql.Word && nextToken.Kind != partiql.QuotedIdent {
+			break
+		}
+
+		p.pos += 2
+		path.parts = append(path.parts, nextToken.Text)
+	}
+
+	return path, nil
+}
+
+func numberLiteral(text string) (expr, error) {
+	if strings.ContainsAny(text, ".eE") {
+		f, err := strconv.ParseFloat(text, 64)
+		return literalExpr{value: f}, err
+	}
+
+	n, ok := new(big.Int).SetString(text, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", text)
+	}
+
+	return literalExpr{value: n}, nil
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+	"github.com/rs/zerolog/log"
+)
+
+// GoMigration is a migration written in Go, for data rewrites that can't be expressed as PartiQL statements,
+// e.g. backfilling a field of the documents written before it existed. Its version is interleaved with the
+// versions of the SQL files, a version is either a file or a Go migration.
+// Up and Down run in one ledger transaction that the driver retries on OCC conflicts, so they must only
+// change the ledger through txn and keep within the QLDB transaction limits
+type GoMigration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, txn qldbdriver.Transaction) error
+	Down        func(ctx context.Context, txn qldbdriver.Transaction) error // nil when the migration can't be rolled back
+}
+
+// name identifies the migration in plans and in the Migration table, e.g. go:3-backfill-contract-ids
+func (m GoMigration) name() string {
+	return fmt.Sprintf("go:%d-%s", m.Version, m.Description)
+}
+
+// runGoMigration runs the Up or Down function of a Go migration step in txn
+func runGoMigration(ctx context.Context, txn qldbdriver.Transaction, step PlanStep) error {
+	fn := step.Code.Up
+	if step.Direction == downMigration {
+		fn = step.Code.Down
+	}
+
+	log.Info().Msgf("go %s %s", step.File, step.Direction)
+
+	if err := fn(ctx, txn); err != nil {
+		// wrapped with %w so the driver still retries the OCC conflicts of the function
+		return fmt.Errorf("%s %s: %w", step.File, step.Direction, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+	"github.com/carflores-zh/qldb-go/pkg/storage/fake"
+)
+
+// backfillContractIDs sets the id of the contracts written without one to their document ID
+var backfillContractIDs = GoMigration{
+	Version:     2,
+	Description: "backfill-contract-ids",
+	Up: func(ctx context.Context, txn qldbdriver.Transaction) error {
+		contracts, err := QueryMany[struct {
+			DocID string `ion:"docId"`
+			ID    string `ion:"id"`
+		}](ctx, txn, "SELECT docId, c.id FROM Contract AS c BY docId")
+		if err != nil {
+			return err
+		}
+
+		for _, contract := range contracts {
+			if contract.ID != "" {
+				continue
+			}
+
+			if _, err = txn.Execute("UPDATE Contract AS c BY docId SET c.id = ? WHERE docId = ?", contract.DocID, contract.DocID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+func TestDBMigrator_MigrateQLDB_GoMigrations(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql":   "CREATE TABLE Migration;\nCREATE TABLE Contract;",
+		"up/3-migration.sql":   "CREATE INDEX ON Contract (id);",
+		"down/3-migration.sql": "DROP INDEX \"x\" ON Contract;",
+	})
+
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}, GoMigrations: []GoMigration{backfillContractIDs}}
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 1))
+
+	insert := func(contract model.Contract) string {
+		id, err := Execute(ctx, dbm.Driver, func(txn qldbdriver.Transaction) (string, error) {
+			return ExecReturningID(ctx, txn, "INSERT INTO Contract ?", contract)
+		})
+		require.NoError(t, err)
+
+		return id
+	}
+
+	docID := insert(model.Contract{Address: "0x1", Network: "ethereum"})
+	insert(model.Contract{ID: "c2", Address: "0x2", Network: "polygon"})
+
+	plan, err := dbm.Plan(ctx, fsys, 3)
+	require.NoError(t, err)
+	assert.Contains(t, plan.String(), "\n2-up go:2-backfill-contract-ids (go)\n")
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 3))
+
+	contracts, err := Execute(ctx, dbm.Driver, func(txn qldbdriver.Transaction) ([]model.Contract, error) {
+		return QueryMany[model.Contract](ctx, txn, "SELECT * FROM Contract AS c WHERE c.id = ? OR c.id = 'c2'", docID)
+	})
+	require.NoError(t, err)
+	assert.Len(t, contracts, 2, "the contract without id is backfilled, the other one is kept")
+
+	migrations, err := dbm.GetMigrations(ctx)
+	require.NoError(t, err)
+
+	sources := map[int]string{}
+	for _, migration := range migrations {
+		sources[migration.Version] = migration.Source
+	}
+
+	assert.Equal(t, map[int]string{1: "up/1-migration.sql", 2: "go:2-backfill-contract-ids", 3: "up/3-migration.sql"}, sources)
+	require.NoError(t, dbm.CheckDrift(ctx, fsys), "go migrations have no checksum")
+
+	err = dbm.MigrateQLDB(ctx, fsys, 1)
+	assert.ErrorIs(t, err, ErrInvalidMigration)
+	assert.ErrorContains(t, err, "go:2-backfill-contract-ids has no down function")
+}
+
+func TestDBMigrator_GoMigrationError(t *testing.T) {
+	fsys := migrationsFS(map[string]string{"up/1-migration.sql": "CREATE TABLE Migration;"})
+	errBackfill := errors.New("backfill failed")
+
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}, GoMigrations: []GoMigration{{
+		Version:     2,
+		Description: "fails",
+		Up: func(ctx context.Context, txn qldbdriver.Transaction) error {
+			if _, err := txn.Execute("CREATE TABLE Backfill"); err != nil {
+				return err
+			}
+
+			return errBackfill
+		},
+	}}}
+
+	err := dbm.MigrateQLDB(ctx, fsys, 2)
+	assert.ErrorIs(t, err, errBackfill)
+	assert.ErrorContains(t, err, "go:2-fails up: backfill failed")
+
+	tables, err := dbm.Driver.GetTableNames(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, tables, "Backfill", "the transaction is rolled back")
+
+	migrations, err := dbm.GetMigrations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, getMostRecentVersion(migrations).Version)
+}
+
+func TestLoadMigrations_GoMigrations(t *testing.T) {
+	fsys := migrationsFS(map[string]string{"up/1-migration.sql": "CREATE TABLE Migration;"})
+	up := func(ctx context.Context, txn qldbdriver.Transaction) error { return nil }
+
+	files, err := loadMigrations(fsys, GoMigration{Version: 5, Description: "b", Up: up}, GoMigration{Version: 3, Description: "a", Up: up})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 3, 5}, files.versions)
+
+	_, err = loadMigrations(fsys, GoMigration{Version: 1, Description: "a", Up: up})
+	assert.ErrorContains(t, err, "up/1-migration.sql and go:1-a have the same version")
+
+	_, err = loadMigrations(fsys, GoMigration{Version: 2, Description: "a", Up: up}, GoMigration{Version: 2, Description: "b", Up: up})
+	assert.ErrorContains(t, err, "go:2-a and go:2-b have the same version")
+
+	_, err = loadMigrations(fsys, GoMigration{Version: 2, Description: "a"})
+	assert.ErrorContains(t, err, "go:2-a has no up function")
+}
@@ -1,69 +1,113 @@
 package storage
 
 import (
-	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
-	"strings"
+	"os/user"
 	"time"
 
 	"github.com/rs/zerolog/log"
-	"github.com/spf13/cast"
 
 	"github.com/carflores-zh/qldb-go/pkg/model"
 )
 
-func closeFile(file *os.File) {
+func closeFile(file io.Closer) {
 	if err := file.Close(); err != nil {
 		log.Error().Err(err).Msg("error closing file")
 	}
 }
 
-func getFileScanner(path string, migrationType string, index int) (*bufio.Scanner, *os.File, error) {
-	fullPath := path + migrationType + "/" + cast.ToString(index) + "-migration.sql"
+func getMigrationDirection(mostRecent model.Migration, version int) string {
+	if mostRecent.Version > version {
+		return downMigration
+	}
+
+	return upMigration
+}
+
+// getMostRecentVersion returns the latest applied record, the zero Migration when there is none.
+// Failed runs didn't change the version and are skipped
+func getMostRecentVersion(migrations []model.Migration) model.Migration {
+	var mostRecent model.Migration
 
-	file, err := os.Open(fullPath)
-	if err != nil {
-		return nil, nil, err
+	for _, migration := range migrations {
+		if migration.Status != migrationFailed && recordedAfter(migration, mostRecent) {
+			mostRecent = migration
+		}
 	}
 
-	scanner := bufio.NewScanner(file)
+	log.Info().Int("version", mostRecent.Version).Int64("sequence", mostRecent.Sequence).Msg("most recent version")
 
-	return scanner, file, nil
+	return mostRecent
 }
 
-func isSQLValid(fileLine string) bool {
-	if strings.Contains(fileLine, "CREATE") ||
-		strings.Contains(fileLine, "INSERT") ||
-		strings.Contains(fileLine, "UPDATE") ||
-		strings.Contains(fileLine, "DELETE") ||
-		strings.Contains(fileLine, "DROP") {
-		return true
+// getLastRun returns the latest record, applied or failed
+func getLastRun(migrations []model.Migration) model.Migration {
+	var last model.Migration
+
+	for _, migration := range migrations {
+		if recordedAfter(migration, last) {
+			last = migration
+		}
 	}
 
-	return false
+	return last
 }
 
-func getMigrationDirection(mostRecent model.Migration, version int) string {
-	if mostRecent.Version > version {
-		return downMigration
+// recordedAfter orders the records by Sequence, which doesn't depend on the clocks of the migrators.
+// Records without one were written before sequences and come first, ordered by MigratedAt, then by
+// Version so that records written at the same instant always give the same answer
+func recordedAfter(a model.Migration, b model.Migration) bool {
+	switch {
+	case a.Sequence != b.Sequence:
+		return a.Sequence > b.Sequence
+	case !a.MigratedAt.Equal(b.MigratedAt):
+		return a.MigratedAt.After(b.MigratedAt)
+	default:
+		return a.Version > b.Version
 	}
+}
 
-	return upMigration
+// contextError makes sure an error caused by a done context matches ctx.Err() with errors.Is
+func contextError(ctx context.Context, err error) error {
+	errCtx := ctx.Err()
+	if errCtx == nil || errors.Is(err, errCtx) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", errCtx, err)
 }
 
-func getMostRecentVersion(migrations []model.Migration) model.Migration {
-	mostRecent := model.Migration{
-		Version:    0,
-		MigratedAt: time.Time{},
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
+}
 
-	for _, migration := range migrations {
-		if migration.MigratedAt.After(mostRecent.MigratedAt) {
-			mostRecent = migration
-		}
+// principal is who runs the migrations: the configured Principal, or user@host of the OS user
+func (dbm *DBMigrator) principal() string {
+	if dbm.Principal != "" {
+		return dbm.Principal
 	}
 
-	log.Info().Int("version", mostRecent.Version).Msg("most recent version")
+	name := "unknown"
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
 
-	return mostRecent
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+
+	return name
 }
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+
+	"github.com/carflores-zh/qldb-go/pkg/internal/partiql"
+	"github.com/carflores-zh/qldb-go/pkg/model"
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
+)
+
+// Revision is a committed revision of a document of type T, as returned by history()
+type Revision[T any] struct {
+	Data         *T                       `ion:"data"`     // nil when the revision deletes the document or was redacted
+	DataHash     []byte                   `ion:"dataHash"` // hash of the redacted data, set instead of Data
+	Metadata     metadata.HistoryMetadata `ion:"metadata"`
+	BlockAddress metadata.BlockAddress    `ion:"blockAddress"`
+	Hash         []byte                   `ion:"hash"`
+}
+
+// Deleted tells if the revision is the deletion of the document
+func (r Revision[T]) Deleted() bool {
+	return r.Data == nil && r.DataHash == nil
+}
+
+// Redacted tells if the data of the revision was redacted, only its hash is left
+func (r Revision[T]) Redacted() bool {
+	return r.DataHash != nil
+}
+
+// HistoryOption bounds the revisions returned by SelectHistory by their commit time
+type HistoryOption func(*historyBounds)
+
+type historyBounds struct {
+	start time.Time
+	end   time.Time
+}
+
+// HistorySince returns the revisions committed at or after start
+func HistorySince(start time.Time) HistoryOption {
+	return func(b *historyBounds) {
+		b.start = start
+	}
+}
+
+// HistoryUntil returns the revisions committed at or before end
+func HistoryUntil(end time.Time) HistoryOption {
+	return func(b *historyBounds) {
+		b.end = end
+	}
+}
+
+// SelectHistory returns the revisions of the document id of tableName in version order, with their data,
+// metadata, block address and hash. The document is looked up by its document id (metadata.id), which
+// the ledger indexes. The bounds are passed to history(T, start, end) as Ion timestamps
+func SelectHistory[T any](ctx context.Context, driver QLDBDriver, tableName string, id string, opts ...HistoryOption) ([]Revision[T], error) {
+	statement, err := historyStatement(tableName, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := Execute(ctx, driver, func(txn qldbdriver.Transaction) ([]Revision[T], error) {
+		return QueryMany[Revision[T]](ctx, txn, statement, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Metadata.Version < revisions[j].Metadata.Version
+	})
+
+	return revisions, nil
+}
+
+// historyStatement builds the history query of the document id of tableName
+func historyStatement(tableName string, opts ...HistoryOption) (string, error) {
+	source, err := historySource(tableName, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("SELECT * FROM %s AS h WHERE h.metadata.id = ?", source), nil
+}
+
+// historySource renders history(T, start, end), QLDB only takes the bounds as timestamp literals
+func historySource(tableName string, opts ...HistoryOption) (string, error) {
+	if err := validTableName(tableName); err != nil {
+		return "", err
+	}
+
+	var bounds historyBounds
+	for _, opt := range opts {
+		opt(&bounds)
+	}
+
+	args := []string{tableName}
+
+	if !bounds.start.IsZero() || !bounds.end.IsZero() {
+		// the start can't be omitted when there is an end, the epoch is before any revision
+		args = append(args, ionTimestamp(bounds.start))
+	}
+
+	if !bounds.end.IsZero() {
+		args = append(args, ionTimestamp(bounds.end))
+	}
+
+	return fmt.Sprintf("history(%s)", strings.Join(args, ", ")), nil
+}
+
+// validTableName checks tableName before it is put in a statement, the table of history(T) can't be a parameter
+func validTableName(tableName string) error {
+	if tokens, err := partiql.Lex(tableName); err != nil || len(tokens) != 1 || tokens[0].Kind != partiql.Word || partiql.Reserved(tableName) {
+		return fmt.Errorf("%w: %q is not a table name", ErrInvalidStatement, tableName)
+	}
+
+	return nil
+}
+
+func ionTimestamp(t time.Time) string {
+	if t.IsZero() {
+		t = time.Unix(0, 0)
+	}
+
+	return "`" + t.UTC().Format(time.RFC3339Nano) + "`"
+}
+
+// ContractHistory returns the revisions of the contract id, its id is the document id, see SelectHistory
+func (db *DB) ContractHistory(ctx context.Context, id string, opts ...HistoryOption) ([]Revision[model.Contract], error) {
+	return SelectHistory[model.Contract](ctx, db.Driver, "Contract", id, opts...)
+}
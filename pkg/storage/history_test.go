@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+	"github.com/carflores-zh/qldb-go/pkg/storage/fake"
+	"github.com/carflores-zh/qldb-go/sql"
+)
+
+func TestDB_ContractHistory(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	db := &DB{Driver: fake.NewDriver(fake.WithClock(func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	})), LedgerName: "test"}
+	require.NoError(t, (&DBMigrator{DB: db}).MigrateQLDB(ctx, sql.FS, 1))
+
+	contract := &model.Contract{Address: "0x1", Network: "ethereum"}
+	id, err := db.InsertContractTx(ctx, contract)
+	require.NoError(t, err)
+
+	contract.Network = "polygon"
+	require.NoError(t, db.UpdateContract(ctx, contract))
+
+	_, err = Execute(ctx, db.Driver, func(txn qldbdriver.Transaction) (qldbdriver.Result, error) {
+		return txn.Execute("DELETE FROM Contract AS c WHERE c.id = ?", id)
+	})
+	require.NoError(t, err)
+
+	revisions, err := db.ContractHistory(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+
+	for i, revision := range revisions {
+		assert.Equal(t, i, revision.Metadata.Version)
+		assert.Equal(t, id, revision.Metadata.ID)
+		assert.NotEmpty(t, revision.Metadata.TxID)
+		assert.NotEmpty(t, revision.Hash)
+		assert.False(t, revision.Redacted())
+
+		if i > 0 {
+			assert.True(t, revision.Metadata.TxTime.After(revisions[i-1].Metadata.TxTime))
+			assert.Greater(t, revision.BlockAddress.SequenceNo, revisions[i-1].BlockAddress.SequenceNo)
+		}
+	}
+
+	require.NotNil(t, revisions[0].Data)
+	assert.Equal(t, model.Contract{ID: id, Address: "0x1", Network: "ethereum"}, *revisions[0].Data, "the insert commits the id")
+	require.NotNil(t, revisions[1].Data)
+	assert.Equal(t, model.Contract{ID: id, Address: "0x1", Network: "polygon"}, *revisions[1].Data)
+	assert.True(t, revisions[2].Deleted())
+
+	since, err := db.ContractHistory(ctx, id, HistorySince(revisions[1].Metadata.TxTime))
+	require.NoError(t, err)
+	assert.Equal(t, revisions[1:], since)
+
+	until, err := db.ContractHistory(ctx, id, HistoryUntil(revisions[1].Metadata.TxTime))
+	require.NoError(t, err)
+	assert.Equal(t, revisions[:2], until)
+
+	between, err := db.ContractHistory(ctx, id,
+		HistorySince(revisions[1].Metadata.TxTime), HistoryUntil(revisions[1].Metadata.TxTime))
+	require.NoError(t, err)
+	assert.Equal(t, revisions[1:2], between)
+
+	missing, err := db.ContractHistory(ctx, "missing")
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+}
+
+func TestHistoryStatement(t *testing.T) {
+	start := time.Date(2023, 3, 1, 10, 0, 0, 0, time.FixedZone("CET", 3600))
+	end := time.Date(2023, 3, 2, 10, 0, 0, 500000000, time.UTC)
+
+	tests := []struct {
+		opts []HistoryOption
+		want string
+	}{
+		{nil, "SELECT * FROM history(Contract) AS h WHERE h.metadata.id = ?"},
+		{[]HistoryOption{HistorySince(start)}, "SELECT * FROM history(Contract, `2023-03-01T09:00:00Z`) AS h WHERE h.metadata.id = ?"},
+		{[]HistoryOption{HistoryUntil(end)}, "SELECT * FROM history(Contract, `1970-01-01T00:00:00Z`, `2023-03-02T10:00:00.5Z`) AS h WHERE h.metadata.id = ?"},
+		{[]HistoryOption{HistorySince(start), HistoryUntil(end)},
+			"SELECT * FROM history(Contract, `2023-03-01T09:00:00Z`, `2023-03-02T10:00:00.5Z`) AS h WHERE h.metadata.id = ?"},
+	}
+
+	for _, tt := range tests {
+		statement, err := historyStatement("Contract", tt.opts...)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, statement)
+	}
+
+	for _, name := range []string{"", "Contract)", "Contract, `2023-03-01T00:00:00Z`", "'Contract'", "User"} {
+		_, err := historyStatement(name)
+		assert.ErrorIs(t, err, ErrInvalidStatement, name)
+	}
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/amzn/ion-go/ion"
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+
+	"github.com/carflores-zh/qldb-go/pkg/verify"
+)
+
+// RevisionHashCheck is the result of re-hashing one revision of a document
+type RevisionHashCheck struct {
+	Version  int
+	Stored   []byte // hash recorded by the ledger
+	Computed []byte // hash computed locally from data (or dataHash) and metadata
+}
+
+func (c RevisionHashCheck) Match() bool {
+	return bytes.Equal(c.Stored, c.Computed)
+}
+
+// CheckRevisionHashes re-hashes every revision of the document id in tableName.
+// All the checks are returned, with a *HashMismatchError listing the versions that don't match
+func (db *DB) CheckRevisionHashes(ctx context.Context, tableName string, id string) ([]RevisionHashCheck, error) {
+	if err := validTableName(tableName); err != nil {
+		return nil, err
+	}
+
+	revisions, err := Execute(ctx, db.Driver, func(txn qldbdriver.Transaction) ([][]byte, error) {
+		return QueryRaw(ctx, txn, fmt.Sprintf("SELECT * FROM history(%s) AS h WHERE h.metadata.id = ?", tableName), id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 {
+		return nil, &NotFoundError{Table: tableName, ID: id}
+	}
+
+	checks := make([]RevisionHashCheck, 0, len(revisions))
+	mismatch := &HashMismatchError{Table: tableName, ID: id}
+
+	for _, raw := range revisions {
+		var revision struct {
+			Hash     []byte `ion:"hash"`
+			Metadata struct {
+				Version int `ion:"version"`
+			} `ion:"metadata"`
+		}
+
+		if err = ion.Unmarshal(raw, &revision); err != nil {
+			return nil, err
+		}
+
+		computed, err := verify.RevisionHash(raw)
+		if err != nil {
+			return nil, fmt.Errorf("hash %s %q version %d: %w", tableName, id, revision.Metadata.Version, err)
+		}
+
+		check := RevisionHashCheck{Version: revision.Metadata.Version, Stored: revision.Hash, Computed: computed}
+		if !check.Match() {
+			mismatch.Versions = append(mismatch.Versions, check.Version)
+		}
+
+		checks = append(checks, check)
+	}
+
+	if len(mismatch.Versions) > 0 {
+		return checks, mismatch
+	}
+
+	return checks, nil
+}
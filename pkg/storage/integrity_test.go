@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+	"github.com/carflores-zh/qldb-go/pkg/storage/mocks"
+)
+
+// contractRevision is a Contract revision in the form history() returns it, the revision of the verify testdata
+const contractRevision = `{blockAddress:{sequenceNo:3,strandId:"JdxjkR9bSYB5jMHWcI464T"},` +
+	`data:{address:"0x1",id:"QAMMHTXhu2zerDt2mcGGzy",network:"ethereum",sendFunds:true},` +
+	`hash:{{RN197nx8XG2bpgEGVETksn5DoXV2Yelx31AKSRXgrW8=}},` +
+	`metadata:{id:"QAMMHTXhu2zerDt2mcGGzy",txId:"7q6aB1ML2vZPI1kc3sU4OC",txTime:2023-03-01T10:03:00.000Z,version:1}}`
+
+func TestDB_CheckRevisionHashes(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeMigrator(t, 1).DB
+
+	contract := &model.Contract{Address: "0x1", Network: "ethereum"}
+	id, err := db.InsertContractTx(ctx, contract)
+	require.NoError(t, err)
+
+	contract.Network = "polygon"
+	require.NoError(t, db.UpdateContract(ctx, contract))
+
+	checks, err := db.CheckRevisionHashes(ctx, "Contract", id)
+	require.NoError(t, err)
+	require.Len(t, checks, 2)
+
+	for i, check := range checks {
+		assert.Equal(t, i, check.Version)
+		assert.True(t, check.Match())
+	}
+
+	_, err = db.CheckRevisionHashes(ctx, "Contract", "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = db.CheckRevisionHashes(ctx, "Contract) AS c, history(Image", id)
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+}
+
+func TestDB_CheckRevisionHashes_Tampered(t *testing.T) {
+	mDriver := mocks.NewMockQLDBDriver()
+
+	result := &mocks.MockResult{}
+	result.On("Next", mock.Anything).Return(true).Twice()
+	result.On("Next", mock.Anything).Return(false).Once()
+	result.On("GetCurrentData").Return([]byte(contractRevision)).Once()
+	result.On("GetCurrentData").Return([]byte(strings.Replace(contractRevision, "version:1", "version:2", 1))).Once()
+	result.On("Err").Return(nil)
+
+	mDriver.Txn.On("Execute", "SELECT * FROM history(Contract) AS h WHERE h.metadata.id = ?", []interface{}{"QAMMHTXhu2zerDt2mcGGzy"}).
+		Return(result, nil)
+
+	db := &DB{Driver: mDriver}
+
+	checks, err := db.CheckRevisionHashes(context.Background(), "Contract", "QAMMHTXhu2zerDt2mcGGzy")
+	require.Len(t, checks, 2)
+	assert.True(t, checks[0].Match())
+	assert.False(t, checks[1].Match())
+
+	var mismatch *HashMismatchError
+
+	require.ErrorAs(t, err, &mismatch)
+	assert.ErrorIs(t, err, ErrHashMismatch)
+	assert.Equal(t, []int{2}, mismatch.Versions)
+}
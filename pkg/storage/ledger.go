@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/qldb"
+	"github.com/aws/aws-sdk-go-v2/service/qldb/types"
+	"github.com/rs/zerolog/log"
+)
+
+// EnsureLedger creates the ledger if it does not exist and waits, checking every pollInterval, until it is ACTIVE
+func (dbm *DBMigrator) EnsureLedger(ctx context.Context, pollInterval time.Duration) error {
+	for {
+		ledger, err := dbm.Client.DescribeLedger(ctx, &qldb.DescribeLedgerInput{Name: aws.String(dbm.LedgerName)})
+
+		var notFound *types.ResourceNotFoundException
+
+		switch {
+		case errors.As(err, &notFound):
+			log.Info().Str("ledger", dbm.LedgerName).Msg("ledger not found, creating it")
+
+			_, err = dbm.Client.CreateLedger(ctx, &qldb.CreateLedgerInput{
+				Name:            aws.String(dbm.LedgerName),
+				PermissionsMode: types.PermissionsModeStandard,
+			})
+			if err != nil {
+				return translateError(contextError(ctx, err))
+			}
+
+			continue
+		case err != nil:
+			return translateError(contextError(ctx, err))
+		}
+
+		switch ledger.State {
+		case types.LedgerStateActive:
+			return nil
+		case types.LedgerStateDeleting, types.LedgerStateDeleted:
+			return fmt.Errorf("ledger %s is %s: %w", dbm.LedgerName, ledger.State, ErrLedgerNotActive)
+		}
+
+		log.Info().Str("ledger", dbm.LedgerName).Str("state", string(ledger.State)).
+			Dur("wait", pollInterval).Msg("waiting for ledger to be active")
+
+		if err = sleep(ctx, pollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// DeleteAllLedgers disables the deletion protection of every ledger in the account and deletes it,
+// a failure on one ledger does not stop the others and all the errors are returned joined
+func (dbm *DBMigrator) DeleteAllLedgers(ctx context.Context) error {
+	var errs []error
+
+	paginator := qldb.NewListLedgersPaginator(dbm.Client, &qldb.ListLedgersInput{})
+
+	for paginator.HasMorePages() {
+		list, err := paginator.NextPage(ctx)
+		if err != nil {
+			return translateError(contextError(ctx, err))
+		}
+
+		for _, ledger := range list.Ledgers {
+			if ledger.State == types.LedgerStateDeleting || ledger.State == types.LedgerStateDeleted {
+				continue
+			}
+
+			if err = dbm.deleteLedger(ctx, aws.ToString(ledger.Name)); err != nil {
+				errs = append(errs, fmt.Errorf("ledger %s: %w", aws.ToString(ledger.Name), err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (dbm *DBMigrator) deleteLedger(ctx context.Context, ledgerName string) error {
+	log.Info().Str("ledger", ledgerName).Msg("deleting ledger")
+
+	_, err := dbm.Client.UpdateLedger(ctx, &qldb.UpdateLedgerInput{
+		Name:               aws.String(ledgerName),
+		DeletionProtection: aws.Bool(false),
+	})
+	if err != nil {
+		return translateError(contextError(ctx, err))
+	}
+
+	_, err = dbm.Client.DeleteLedger(ctx, &qldb.DeleteLedgerInput{Name: aws.String(ledgerName)})
+	if err != nil {
+		return translateError(contextError(ctx, err))
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/qldb"
+	"github.com/aws/aws-sdk-go-v2/service/qldb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/storage/fake"
+)
+
+func TestDBMigrator_EnsureLedger(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	client := fake.NewClient(fake.WithClientClock(func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}), fake.WithTransitionDelay(3*time.Second))
+
+	dbm := &DBMigrator{DB: &DB{LedgerName: "test"}, Client: client}
+
+	require.NoError(t, dbm.EnsureLedger(ctx, time.Millisecond))
+
+	ledger, err := client.DescribeLedger(ctx, &qldb.DescribeLedgerInput{Name: aws.String("test")})
+	require.NoError(t, err)
+	assert.Equal(t, types.LedgerStateActive, ledger.State)
+
+	// already active, nothing to create
+	require.NoError(t, dbm.EnsureLedger(ctx, time.Millisecond))
+}
+
+func TestDBMigrator_EnsureLedger_Canceled(t *testing.T) {
+	now := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	dbm := &DBMigrator{
+		DB:     &DB{LedgerName: "test"},
+		Client: fake.NewClient(fake.WithClientClock(func() time.Time { return now }), fake.WithTransitionDelay(time.Hour)),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, dbm.EnsureLedger(ctx, time.Millisecond), context.DeadlineExceeded)
+}
+
+func TestDBMigrator_DeleteAllLedgers(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClient()
+	dbm := &DBMigrator{DB: &DB{}, Client: client}
+
+	for _, name := range []string{"one", "two"} {
+		_, err := client.CreateLedger(ctx, &qldb.CreateLedgerInput{Name: aws.String(name), PermissionsMode: types.PermissionsModeStandard})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, dbm.DeleteAllLedgers(ctx))
+
+	list, err := client.ListLedgers(ctx, &qldb.ListLedgersInput{})
+	require.NoError(t, err)
+	assert.Empty(t, list.Ledgers, "the deletion completes with no transition delay")
+}
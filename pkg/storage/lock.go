@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+	"github.com/rs/zerolog/log"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+)
+
+const (
+	// lockTable is created by the migrator and holds at most one document, it has no index and its
+	// statements, which name it with %s, read it whole
+	lockTable      = "MigrationLock"
+	lockID         = "migration"
+	defaultLockTTL = 30 * time.Minute
+	releaseTimeout = 30 * time.Second
+)
+
+// AcquireLock takes the migration lock for LockTTL, so concurrent migrators don't run the same migrations.
+// The lock document is read and written in one transaction, two migrators racing for it conflict on commit
+// and the retried one finds it held. A lock held by another migrator returns a *LockedError, unless it expired
+func (dbm *DBMigrator) AcquireLock(ctx context.Context) (model.MigrationLock, error) {
+	if err := dbm.ensureTable(ctx, lockTable); err != nil {
+		return model.MigrationLock{}, err
+	}
+
+	token, err := newLockToken()
+	if err != nil {
+		return model.MigrationLock{}, err
+	}
+
+	ttl := dbm.lockTTL()
+
+	return Execute(ctx, dbm.Driver, func(txn qldbdriver.Transaction) (model.MigrationLock, error) {
+		now := time.Now().UTC()
+
+		lock := model.MigrationLock{
+			ID:         lockID,
+			Owner:      dbm.principal(),
+			Token:      token,
+			AcquiredAt: now,
+			ExpiresAt:  now.Add(ttl),
+		}
+
+		current, found, err := QueryOne[model.MigrationLock](ctx, txn, fmt.Sprintf("SELECT * FROM %s AS l WHERE l.id = ?", lockTable), lockID)
+		if err != nil {
+			return lock, err
+		}
+
+		if !found {
+			_, err = txn.Execute(fmt.Sprintf("INSERT INTO %s ?", lockTable), lock)
+			return lock, err
+		}
+
+		if now.Before(current.ExpiresAt) {
+			return lock, &LockedError{Owner: current.Owner, AcquiredAt: current.AcquiredAt, ExpiresAt: current.ExpiresAt}
+		}
+
+		log.Warn().Str("owner", current.Owner).Time("expiresAt", current.ExpiresAt).Msg("taking over an expired migration lock")
+
+		_, err = txn.Execute(fmt.Sprintf("UPDATE %s AS l SET l = ? WHERE l.id = ?", lockTable), lock, lockID)
+
+		return lock, err
+	})
+}
+
+// RenewLock extends a lock returned by AcquireLock for LockTTL from now, MigrateQLDB renews it before each
+// step. The lock is read and written in one transaction, so a takeover that commits first is seen on retry.
+// It returns an error matching ErrLockLost if the lock expired and was taken over or force unlocked
+func (dbm *DBMigrator) RenewLock(ctx context.Context, lock model.MigrationLock) (model.MigrationLock, error) {
+	ttl := dbm.lockTTL()
+
+	return Execute(ctx, dbm.Driver, func(txn qldbdriver.Transaction) (model.MigrationLock, error) {
+		current, found, err := QueryOne[model.MigrationLock](ctx, txn, fmt.Sprintf("SELECT * FROM %s AS l WHERE l.id = ?", lockTable), lockID)
+		if err != nil {
+			return lock, err
+		}
+
+		if !found {
+			return lock, fmt.Errorf("%w: it was released", ErrLockLost)
+		}
+
+		if current.Token != lock.Token {
+			return lock, fmt.Errorf("%w: taken over by %s since %s", ErrLockLost, current.Owner, current.AcquiredAt.Format(time.RFC3339))
+		}
+
+		renewed := current
+		renewed.ExpiresAt = time.Now().UTC().Add(ttl)
+
+		_, err = txn.Execute(fmt.Sprintf("UPDATE %s AS l SET l.expiresAt = ? WHERE l.id = ?", lockTable), renewed.ExpiresAt, lockID)
+
+		return renewed, err
+	})
+}
+
+// ReleaseLock releases a lock returned by AcquireLock. It does nothing if the lock expired and
+// was taken over, the new holder keeps it
+func (dbm *DBMigrator) ReleaseLock(ctx context.Context, lock model.MigrationLock) error {
+	_, err := Execute(ctx, dbm.Driver, func(txn qldbdriver.Transaction) (qldbdriver.Result, error) {
+		return txn.Execute(fmt.Sprintf("DELETE FROM %s AS l WHERE l.id = ? AND l.token = ?", lockTable), lockID, lock.Token)
+	})
+
+	return err
+}
+
+// releaseLock releases the lock at the end of a migration, even when its context is done
+func (dbm *DBMigrator) releaseLock(lock model.MigrationLock) {
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+
+	if err := dbm.ReleaseLock(ctx, lock); err != nil {
+		log.Error().Err(err).Time("expiresAt", lock.ExpiresAt).Msg("error releasing the migration lock")
+	}
+}
+
+// ForceUnlock deletes the migration lock whoever holds it, for locks left by a migrator that died.
+// It returns the lock it deleted, found is false when there was none
+func (dbm *DBMigrator) ForceUnlock(ctx context.Context) (lock model.MigrationLock, found bool, err error) {
+	if err = dbm.ensureTable(ctx, lockTable); err != nil {
+		return lock, false, err
+	}
+
+	_, err = Execute(ctx, dbm.Driver, func(txn qldbdriver.Transaction) (interface{}, error) {
+		var errQuery error
+
+		lock, found, errQuery = QueryOne[model.MigrationLock](ctx, txn, fmt.Sprintf("SELECT * FROM %s AS l WHERE l.id = ?", lockTable), lockID)
+		if errQuery != nil || !found {
+			return nil, errQuery
+		}
+
+		return txn.Execute(fmt.Sprintf("DELETE FROM %s AS l WHERE l.id = ?", lockTable), lockID)
+	})
+
+	return lock, found, err
+}
+
+// ensureTable creates a table of the migrator the first time it runs on the ledger, e.g. the lock table.
+// If another migrator creates it at the same time the CREATE fails, and finding the table is enough
+func (dbm *DBMigrator) ensureTable(ctx context.Context, name string) error {
+	exists, err := dbm.hasTable(ctx, name)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = Execute(ctx, dbm.Driver, func(txn qldbdriver.Transaction) (qldbdriver.Result, error) {
+		return txn.Execute("CREATE TABLE " + name)
+	})
+	if err == nil {
+		return nil
+	}
+
+	if exists, errTable := dbm.hasTable(ctx, name); errTable != nil || !exists {
+		return errors.Join(err, errTable)
+	}
+
+	return nil
+}
+
+func (dbm *DBMigrator) hasTable(ctx context.Context, name string) (bool, error) {
+	tables, err := dbm.Driver.GetTableNames(ctx)
+	if err != nil {
+		return false, translateError(contextError(ctx, err))
+	}
+
+	for _, table := range tables {
+		if table == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (dbm *DBMigrator) lockTTL() time.Duration {
+	if dbm.LockTTL <= 0 {
+		return defaultLockTTL
+	}
+
+	return dbm.LockTTL
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
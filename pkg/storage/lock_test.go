@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/storage/fake"
+)
+
+func TestDBMigrator_AcquireLock(t *testing.T) {
+	ctx := context.Background()
+	driver := fake.NewDriver()
+
+	first := &DBMigrator{DB: &DB{Driver: driver}, Principal: "deploy-1"}
+	second := &DBMigrator{DB: &DB{Driver: driver}, Principal: "deploy-2"}
+
+	lock, err := first.AcquireLock(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "deploy-1", lock.Owner)
+	assert.Equal(t, lock.AcquiredAt.Add(defaultLockTTL), lock.ExpiresAt)
+
+	_, err = second.AcquireLock(ctx)
+	assert.ErrorIs(t, err, ErrMigrationLocked)
+
+	var locked *LockedError
+	require.ErrorAs(t, err, &locked)
+	assert.Equal(t, "deploy-1", locked.Owner)
+
+	require.NoError(t, first.ReleaseLock(ctx, lock))
+
+	lock, err = second.AcquireLock(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "deploy-2", lock.Owner)
+
+	forced, found, err := first.ForceUnlock(ctx)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, lock.Token, forced.Token)
+
+	_, found, err = first.ForceUnlock(ctx)
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestDBMigrator_AcquireLock_Expired(t *testing.T) {
+	ctx := context.Background()
+	driver := fake.NewDriver()
+
+	stale := &DBMigrator{DB: &DB{Driver: driver}, Principal: "crashed", LockTTL: time.Millisecond}
+	next := &DBMigrator{DB: &DB{Driver: driver}, Principal: "deploy"}
+
+	staleLock, err := stale.AcquireLock(ctx)
+	require.NoError(t, err)
+
+	time.Sleep(5 * time.Millisecond)
+
+	lock, err := next.AcquireLock(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "deploy", lock.Owner)
+
+	// the stale holder releasing late doesn't free the lock taken over
+	require.NoError(t, stale.ReleaseLock(ctx, staleLock))
+
+	_, err = stale.AcquireLock(ctx)
+	assert.ErrorIs(t, err, ErrMigrationLocked)
+}
+
+func TestDBMigrator_AcquireLock_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	driver := fake.NewDriver()
+
+	const migrators = 8
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		acquired int
+	)
+
+	for i := 0; i < migrators; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			dbm := &DBMigrator{DB: &DB{Driver: driver}}
+			if _, err := dbm.AcquireLock(ctx); err == nil {
+				mu.Lock()
+				acquired++
+				mu.Unlock()
+			} else {
+				assert.ErrorIs(t, err, ErrMigrationLocked)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 1, acquired)
+}
+
+func TestDBMigrator_MigrateQLDB_Locked(t *testing.T) {
+	fsys := migrationsFS(map[string]string{"up/1-migration.sql": "CREATE TABLE Migration;"})
+
+	ctx := context.Background()
+	driver := fake.NewDriver()
+
+	holder := &DBMigrator{DB: &DB{Driver: driver}, Principal: "deploy-1"}
+	dbm := &DBMigrator{DB: &DB{Driver: driver}, Principal: "deploy-2"}
+
+	lock, err := holder.AcquireLock(ctx)
+	require.NoError(t, err)
+
+	err = dbm.MigrateQLDB(ctx, fsys, 1)
+	assert.ErrorIs(t, err, ErrMigrationLocked)
+	assert.ErrorContains(t, err, "migration locked by deploy-1")
+
+	require.NoError(t, holder.ReleaseLock(ctx, lock))
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 1))
+
+	_, found, err := dbm.ForceUnlock(ctx)
+	require.NoError(t, err)
+	assert.False(t, found, "MigrateQLDB releases the lock")
+}
+
+func TestDBMigrator_RenewLock(t *testing.T) {
+	ctx := context.Background()
+	driver := fake.NewDriver()
+
+	stale := &DBMigrator{DB: &DB{Driver: driver}, Principal: "slow", LockTTL: time.Millisecond}
+	next := &DBMigrator{DB: &DB{Driver: driver}, Principal: "deploy"}
+
+	lock, err := next.AcquireLock(ctx)
+	require.NoError(t, err)
+
+	renewed, err := next.RenewLock(ctx, lock)
+	require.NoError(t, err)
+	assert.Equal(t, lock.Token, renewed.Token)
+	assert.False(t, renewed.ExpiresAt.Before(lock.ExpiresAt))
+
+	stored, _, err := next.ForceUnlock(ctx)
+	require.NoError(t, err)
+	assert.WithinDuration(t, renewed.ExpiresAt, stored.ExpiresAt, time.Millisecond)
+
+	_, err = next.RenewLock(ctx, renewed)
+	assert.ErrorIs(t, err, ErrLockLost)
+	assert.ErrorContains(t, err, "it was released")
+
+	staleLock, err := stale.AcquireLock(ctx)
+	require.NoError(t, err)
+
+	time.Sleep(5 * time.Millisecond)
+
+	_, err = next.AcquireLock(ctx)
+	require.NoError(t, err)
+
+	_, err = stale.RenewLock(ctx, staleLock)
+	assert.ErrorIs(t, err, ErrLockLost)
+	assert.ErrorContains(t, err, "taken over by deploy")
+}
+
+func TestDBMigrator_RunSteps_LockLost(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql": "CREATE TABLE Migration;",
+		"up/2-migration.sql": "CREATE TABLE A;",
+	})
+
+	ctx := context.Background()
+	driver := fake.NewDriver()
+
+	stale := &DBMigrator{DB: &DB{Driver: driver}, Principal: "slow", LockTTL: time.Millisecond}
+	next := &DBMigrator{DB: &DB{Driver: driver}, Principal: "deploy"}
+
+	files, err := loadMigrations(fsys)
+	require.NoError(t, err)
+
+	lock, err := stale.AcquireLock(ctx)
+	require.NoError(t, err)
+
+	// the lock expires during a step, e.g. while its indexes are built, and another migrator takes it over
+	time.Sleep(5 * time.Millisecond)
+
+	_, err = next.AcquireLock(ctx)
+	require.NoError(t, err)
+
+	err = stale.runSteps(ctx, 0, stale.planSteps(files, 0, 2), &lock)
+	assert.ErrorIs(t, err, ErrLockLost)
+
+	tables, err := driver.GetTableNames(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, tables, "A", "no step runs without the lock")
+}
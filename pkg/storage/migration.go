@@ -4,187 +4,409 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
+	"sort"
 	"time"
 
-	"github.com/amzn/ion-go/ion"
 	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
 	"github.com/rs/zerolog/log"
 
+	"github.com/carflores-zh/qldb-go/pkg/internal/partiql"
 	"github.com/carflores-zh/qldb-go/pkg/model"
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
 )
 
 // InsertMigration inserts a migration into the database
-func (dbm *DBMigrator) InsertMigration(migration model.Migration) error {
+func (dbm *DBMigrator) InsertMigration(ctx context.Context, migration model.Migration) error {
+	_, err := Execute(ctx, dbm.Driver, func(txn qldbdriver.Transaction) (interface{}, error) {
+		return nil, dbm.insertMigration(ctx, txn, migration)
+	})
+
+	return err
+}
+
+// insertMigration records migration in txn with the next sequence, the records written by concurrent
+// transactions conflict on the Migration table so two records never get the same sequence
+func (dbm *DBMigrator) insertMigration(ctx context.Context, txn qldbdriver.Transaction, migration model.Migration) error {
 	migration.MigratedAt = time.Now()
 
-	_, err := dbm.Driver.Execute(context.Background(), func(txn qldbdriver.Transaction) (interface{}, error) {
-		return txn.Execute("INSERT INTO Migration ?", migration)
-	})
+	if migration.Principal == "" {
+		migration.Principal = dbm.principal()
+	}
+
+	// qldb:scan the Migration table has a record per run and is read whole
+	recorded, err := QueryMany[model.Migration](ctx, txn, "SELECT sequence FROM Migration")
 	if err != nil {
 		return err
 	}
 
-	return nil
+	for _, previous := range recorded {
+		if previous.Sequence >= migration.Sequence {
+			migration.Sequence = previous.Sequence + 1
+		}
+	}
+
+	if migration.Sequence == 0 {
+		migration.Sequence = 1
+	}
+
+	_, err = txn.Execute("INSERT INTO Migration ?", migration)
+
+	return err
 }
 
 // GetMigrations returns all migrations from the database
-func (dbm *DBMigrator) GetMigrations() ([]model.Migration, error) {
-	var resultMigrations []model.Migration
+func (dbm *DBMigrator) GetMigrations(ctx context.Context) ([]model.Migration, error) {
+	return Execute(ctx, dbm.Driver, func(txn qldbdriver.Transaction) ([]model.Migration, error) {
+		// qldb:scan the Migration table has a record per run and is read whole
+		return QueryMany[model.Migration](ctx, txn, "SELECT * FROM Migration")
+	})
+}
 
-	c, err := dbm.Driver.Execute(context.Background(), func(txn qldbdriver.Transaction) (interface{}, error) {
-		result, err := txn.Execute("SELECT version, migratedAt, active FROM Migration")
-		if err != nil {
-			return nil, err
-		}
+// appliedMigrations returns the migrations recorded in the ledger, none until the first migration creates
+// the Migration table. Any other error is returned: a ledger that can't be read is not at version 0
+func (dbm *DBMigrator) appliedMigrations(ctx context.Context) ([]model.Migration, error) {
+	exists, err := dbm.hasTable(ctx, migrationTable)
+	if err != nil || !exists {
+		return nil, err
+	}
 
-		var versions []model.Migration
-		for result.Next(txn) {
-			ionBinary := result.GetCurrentData()
+	return dbm.GetMigrations(ctx)
+}
+
+// MigrateDown runs the down migrations of fsys from the mostRecent version to version, version must not be
+// above mostRecent. Unlike MigrateQLDB it doesn't take the migration lock
+func (dbm *DBMigrator) MigrateDown(ctx context.Context, mostRecent model.Migration, version int, fsys fs.FS) error {
+	return dbm.migrate(ctx, downMigration, mostRecent, version, fsys)
+}
+
+// MigrateUp runs the up migrations of fsys from the mostRecent version to version, version must not be
+// below mostRecent. Unlike MigrateQLDB it doesn't take the migration lock
+func (dbm *DBMigrator) MigrateUp(ctx context.Context, mostRecent model.Migration, version int, fsys fs.FS) error {
+	return dbm.migrate(ctx, upMigration, mostRecent, version, fsys)
+}
+
+func (dbm *DBMigrator) migrate(ctx context.Context, direction string, mostRecent model.Migration, version int, fsys fs.FS) error {
+	if direction == upMigration && version < mostRecent.Version || direction == downMigration && version > mostRecent.Version {
+		return fmt.Errorf("%w: can't migrate %s from version %d to %d", ErrInvalidMigration, direction, mostRecent.Version, version)
+	}
+
+	files, err := loadMigrations(fsys, dbm.GoMigrations...)
+	if err != nil {
+		return err
+	}
+
+	return dbm.runSteps(ctx, mostRecent.Version, dbm.planSteps(files, mostRecent.Version, version), nil)
+}
+
+// runSteps runs the steps in order from version from and stops at the first one that fails.
+// Nothing runs when a step is invalid, rather than stopping the run halfway at that step.
+// A lock, nil when the caller holds none, is renewed before each step, the run stops if it was lost
+func (dbm *DBMigrator) runSteps(ctx context.Context, from int, steps []PlanStep, lock *model.MigrationLock) error {
+	for _, step := range steps {
+		if err := step.Valid(); err != nil {
+			log.Error().Err(err).Msg("invalid migration")
+			return err
+		}
+	}
 
-			temp := new(model.Migration)
-			err = ion.Unmarshal(ionBinary, temp)
+	for _, step := range steps {
+		if lock != nil {
+			renewed, err := dbm.RenewLock(ctx, *lock)
 			if err != nil {
-				return nil, err
+				log.Error().Err(err).Int("version", from).Msg("migration stopped")
+				return err
 			}
 
-			versions = append(versions, *temp)
+			*lock = renewed
 		}
-		if result.Err() != nil {
-			return nil, result.Err()
+
+		if err := dbm.runStep(ctx, from, step); err != nil {
+			return err
 		}
 
-		return versions, nil
-	})
+		from = step.Target
+	}
+
+	return nil
+}
+
+// runStep executes the statements, or the Go function, of a step and records its version in the same
+// transaction, so the step either applies with its record or not at all. A step the ledger rejects is
+// recorded as failed, with the version unchanged, and returned as a *MigrationFailedError
+func (dbm *DBMigrator) runStep(ctx context.Context, from int, step PlanStep) error {
+	var executed []Statement
+
+	start := time.Now()
+
+	_, err := Execute(ctx, dbm.Driver, func(txn qldbdriver.Transaction) (interface{}, error) {
+		executed = executed[:0]
+
+		if step.Code != nil {
+			if errRun := runGoMigration(ctx, txn, step); errRun != nil {
+				return nil, errRun
+			}
+		}
+
+		for _, statement := range step.statements() {
+			statement, errResolve := resolveStatement(ctx, txn, statement)
+			if errResolve != nil {
+				return nil, errResolve
+			}
+
+			log.Info().Msgf("sql %s: %s", statement.Position(), statement.Text)
 
+			if _, errExec := txn.Execute(statement.Text); errExec != nil {
+				return nil, &StatementError{Statement: statement.Text, Position: statement.Position(), Reason: "rejected by the ledger", Err: errExec}
+			}
+
+			executed = append(executed, statement)
+		}
+
+		return nil, dbm.insertMigration(ctx, txn, model.Migration{
+			Version:    step.Target,
+			Direction:  step.Direction,
+			Checksum:   step.Checksum,
+			DurationMs: time.Since(start).Milliseconds(),
+			Source:     step.File,
+		})
+	})
 	if err != nil {
-		return nil, err
+		err = &MigrationFailedError{From: from, Direction: step.Direction, Source: step.File, Err: err}
+		log.Error().Err(err).Msg("migration rolled back")
+
+		dbm.recordFailure(from, step, err)
+
+		return err
+	}
+
+	log.Info().Msgf("migration %d-%s executed", step.Version, step.Direction)
+
+	// the version is recorded, the run stops rather than run the next step on tables and indexes still being
+	// built. A timeout matches ErrNotReady and not ErrMigrationFailed, migrating again resumes from this version
+	if err = dbm.WaitForTables(ctx, dbm.ReadyTimeout, readyConditions(executed)...); err != nil {
+		return fmt.Errorf("%s %s applied, the ledger is at version %d: %w", step.File, step.Direction, step.Target, err)
 	}
 
-	resultMigrations = c.([]model.Migration)
+	return nil
+}
+
+// resolveStatement rewrites the statements of migration files that name what QLDB identifies by id: the
+// index of DROP INDEX ON T(path) and the table of UNDROP TABLE T. Other statements are kept
+func resolveStatement(ctx context.Context, txn qldbdriver.Transaction, statement Statement) (Statement, error) {
+	if _, byName, ok := undropTable(statement.Text); ok && byName {
+		return resolveUndrop(ctx, txn, statement)
+	}
 
-	return resultMigrations, nil
+	return resolveIndexDrop(ctx, txn, statement)
 }
 
-func (dbm *DBMigrator) MigrateDown(mostRecent model.Migration, version int, path string, migrationType string) error {
-	for i := mostRecent.Version; i > version; i-- {
-		fileLines, file, err := getFileScanner(path, migrationType, i)
-		defer closeFile(file)
+// resolveIndexDrop rewrites DROP INDEX ON T(path) as DROP INDEX "id" ON T WITH (purge = true). QLDB drops an
+// index by its id, which differs from ledger to ledger, so migration files name the index by its path and the
+// id is read from information_schema.user_tables in the transaction of the step. Other statements are kept
+func resolveIndexDrop(ctx context.Context, txn qldbdriver.Transaction, statement Statement) (Statement, error) {
+	tableName, path, ok := indexDropByPath(statement.Text)
+	if !ok {
+		return statement, nil
+	}
 
-		// creates a transaction and executes statements
-		_, err = dbm.Driver.Execute(context.Background(), func(txn qldbdriver.Transaction) (interface{}, error) {
-			for fileLines.Scan() {
-				_, errScan := txn.Execute(fileLines.Text())
-				if errScan != nil {
-					log.Error().Msgf("Error creating table {%s}: %v", fileLines.Text(), err)
-				}
+	tables, err := QueryMany[metadata.UserTable](ctx, txn, "SELECT * FROM information_schema.user_tables WHERE name = ?", tableName)
+	if err != nil {
+		return statement, err
+	}
+
+	if table := findActiveTable(tables, tableName); table != nil {
+		for _, index := range table.Indexes {
+			if indexPath(index.Expr) == indexPath(path) {
+				statement.Text = fmt.Sprintf("DROP INDEX \"%s\" ON %s WITH (purge = true)", index.IndexID, tableName)
+				return statement, nil
 			}
-			return nil, nil
-		})
-		if err != nil {
-			log.Error().Err(err).Msg("Error creating tables")
 		}
+	}
 
-		migration := model.Migration{
-			Version:    i - 1,
-			MigratedAt: time.Now(),
-		}
+	return statement, &StatementError{Statement: statement.Text, Position: statement.Position(),
+		Reason: fmt.Sprintf("there is no index on %s(%s)", tableName, path)}
+}
 
-		time.Sleep(waitForTables)
+// indexDropByPath matches DROP INDEX ON T(path) and returns the table and the path
+func indexDropByPath(statement string) (tableName string, path string, ok bool) {
+	tokens, err := partiql.Lex(statement)
+	if err != nil || len(tokens) < 6 || !tokens[0].Keyword("DROP") || !tokens[1].Keyword("INDEX") || !tokens[2].Keyword("ON") {
+		return "", "", false
+	}
 
-		err = dbm.InsertMigration(migration)
-		if err != nil {
-			log.Error().Err(err).Msg("Error inserting migration")
+	return tokens[3].Text, joinTokens(tokens[4:]), true
+}
+
+// resolveUndrop rewrites UNDROP TABLE T as UNDROP TABLE "id". QLDB undrops a table by its id, which the ledger
+// assigns when the table is created, so migration files name the table and the id of its dropped table is read
+// from information_schema.user_tables in the transaction of the step. A name dropped more than once is ambiguous
+func resolveUndrop(ctx context.Context, txn qldbdriver.Transaction, statement Statement) (Statement, error) {
+	tableName, _, _ := undropTable(statement.Text)
+
+	tables, err := QueryMany[metadata.UserTable](ctx, txn, "SELECT * FROM information_schema.user_tables WHERE name = ?", tableName)
+	if err != nil {
+		return statement, err
+	}
+
+	var ids []string
+
+	for _, table := range tables {
+		if table.Name == tableName && table.Status == tableInactive {
+			ids = append(ids, table.TableID)
 		}
+	}
 
-		log.Printf("migration %d-%s executed", i, migrationType)
+	switch len(ids) {
+	case 0:
+		return statement, &StatementError{Statement: statement.Text, Position: statement.Position(),
+			Reason: fmt.Sprintf("there is no dropped table %s", tableName)}
+	case 1:
+		statement.Text = fmt.Sprintf("UNDROP TABLE \"%s\"", ids[0])
+		return statement, nil
+	default:
+		return statement, &StatementError{Statement: statement.Text, Position: statement.Position(),
+			Reason: fmt.Sprintf("table %s was dropped %d times, undrop it by id", tableName, len(ids))}
 	}
+}
 
-	return nil
+// undropTable matches UNDROP TABLE T and UNDROP TABLE "id", byName tells if the table is named. Table ids are
+// quoted, they may start with a digit
+func undropTable(statement string) (table string, byName bool, ok bool) {
+	tokens, err := partiql.Lex(statement)
+	if err != nil || len(tokens) != 3 || !tokens[0].Keyword("UNDROP") || !tokens[1].Keyword("TABLE") {
+		return "", false, false
+	}
+
+	return tokens[2].Text, tokens[2].Kind == partiql.Word, true
 }
 
-func (dbm *DBMigrator) MigrateUp(mostRecent model.Migration, version int, path string, migrationType string) error {
-	for i := mostRecent.Version + 1; i <= version; i++ {
-		fileLines, file, err := getFileScanner(path, migrationType, i)
-		if err != nil {
-			log.Printf("error getting file scanner: %v", err)
-		}
+// recordFailure records a step that was rolled back, the ledger stays at version from. It runs even if the
+// context of the migration is done, with its own timeout, and only logs when the record can't be written,
+// e.g. when the failed step is the one that creates the Migration table
+func (dbm *DBMigrator) recordFailure(from int, step PlanStep, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+
+	errRecord := dbm.InsertMigration(ctx, model.Migration{
+		Version:   from,
+		Direction: step.Direction,
+		Checksum:  step.Checksum,
+		Source:    step.File,
+		Status:    migrationFailed,
+		Error:     err.Error(),
+	})
+	if errRecord != nil {
+		log.Error().Err(errRecord).Msg("error recording the failed migration")
+	}
+}
 
-		defer closeFile(file)
+// CheckDrift compares the applied up migrations with the files of fsys and returns a MigrationDriftError,
+// joined with errors.Join, for each file that is missing or changed since it was applied. A ledger never migrated has none
+func (dbm *DBMigrator) CheckDrift(ctx context.Context, fsys fs.FS) error {
+	files, err := loadMigrations(fsys, dbm.GoMigrations...)
+	if err != nil {
+		return err
+	}
 
-		// creates a transaction and executes statements
-		_, err = dbm.Driver.Execute(context.Background(), func(txn qldbdriver.Transaction) (interface{}, error) {
-			var errScan error
+	migrations, err := dbm.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
 
-			for fileLines.Scan() {
-				log.Info().Msgf("sql: %s", fileLines.Text())
-				isValid := isSQLValid(fileLines.Text())
-				if !isValid {
-					log.Error().Msgf("invalid sql: %s", fileLines.Text())
-					return nil, errors.New("invalid sql")
-				}
+	return checkDrift(migrations, getMostRecentVersion(migrations), files)
+}
 
-				_, errScan = txn.Execute(fileLines.Text())
-				if errScan != nil {
-					return nil, errScan
-				}
-			}
+// checkDrift checks the up files of the versions up to mostRecent, each against the checksum of its
+// latest up run. Versions without a checksum were applied before checksums were recorded and are skipped
+func checkDrift(migrations []model.Migration, mostRecent model.Migration, files *migrationFiles) error {
+	applied := map[int]model.Migration{}
 
-			return nil, errScan
-		})
-		if err != nil {
-			log.Error().Err(err).Msg("Error creating tables")
-			return err
+	for _, migration := range migrations {
+		if migration.Direction != upMigration || migration.Status == migrationFailed || migration.Checksum == "" ||
+			migration.Version > mostRecent.Version {
+			continue
 		}
 
-		migration := model.Migration{
-			Version:    i,
-			MigratedAt: time.Now(),
+		if previous, ok := applied[migration.Version]; !ok || recordedAfter(migration, previous) {
+			applied[migration.Version] = migration
 		}
+	}
+
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+
+	sort.Ints(versions)
+
+	var errs []error
 
-		// wait for creation of tables
-		time.Sleep(waitForTables)
+	for _, version := range versions {
+		migration := applied[version]
 
-		err = dbm.InsertMigration(migration)
+		statements, err := files.read(upMigration, version)
 		if err != nil {
-			log.Error().Err(err).Msg("Error inserting migration")
+			errs = append(errs, &MigrationDriftError{Version: version, Reason: err.Error()})
+			continue
 		}
 
-		log.Info().Msgf("migration %d-%s executed", i, migrationType)
+		if sum := checksum(statements); sum != migration.Checksum {
+			errs = append(errs, &MigrationDriftError{
+				Version: version,
+				Reason: fmt.Sprintf("%s changed since it was applied on %s: checksum %s, recorded %s",
+					files.name(upMigration, version), migration.MigratedAt.Format(time.RFC3339), sum, migration.Checksum),
+			})
+		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
-func (dbm *DBMigrator) MigrateQLDB(path string, version int) error {
-	migrations, err := dbm.GetMigrations()
+// MigrateQLDB takes the ledger to version with the migrations of fsys, e.g. os.DirFS("sql") or sql.FS.
+// fsys holds the up and down directories, see loadMigrations for the file names.
+// It holds the migration lock from planning to the last migration and renews it before each one, see AcquireLock
+func (dbm *DBMigrator) MigrateQLDB(ctx context.Context, fsys fs.FS, version int) error {
+	files, err := loadMigrations(fsys, dbm.GoMigrations...)
 	if err != nil {
-		log.Info().Msg("no migrations found")
+		return err
 	}
 
-	fmt.Printf("migrations: %v", migrations)
+	lock, err := dbm.AcquireLock(ctx)
+	if err != nil {
+		return err
+	}
 
-	mostRecent := getMostRecentVersion(migrations)
+	defer func() { dbm.releaseLock(lock) }()
 
-	if mostRecent.Version == version {
+	migrations, err := dbm.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	plan, err := dbm.plan(migrations, files, version)
+	if err != nil {
+		return err
+	}
+
+	if plan.Drift != nil {
+		if !dbm.IgnoreDrift {
+			return plan.Drift
+		}
+
+		log.Warn().Err(plan.Drift).Msg("applied migrations changed on disk, migrating anyway")
+	}
+
+	if plan.Direction == "" {
 		log.Info().Msgf("database is already at version %d", version)
 		return nil
 	}
 
-	migrationType := getMigrationDirection(mostRecent, version)
+	log.Info().Msgf("migrations from %d to %d", plan.From, version)
 
-	log.Info().Msgf("migrations from %d to %d", mostRecent.Version, version)
-
-	if migrationType == "up" {
-		err = dbm.MigrateUp(mostRecent, version, path, migrationType)
-		if err != nil {
-			return err
-		}
-	} else {
-		err = dbm.MigrateDown(mostRecent, version, path, migrationType)
-		if err != nil {
-			return err
-		}
+	if last := getLastRun(migrations); last.Status == migrationFailed {
+		log.Warn().Str("source", last.Source).Str("error", last.Error).Msg("the last migration run failed and was rolled back, retrying")
 	}
 
-	return nil
+	return dbm.runSteps(ctx, plan.From, plan.Steps, &lock)
 }
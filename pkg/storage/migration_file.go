@@ -0,0 +1,354 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/carflores-zh/qldb-go/pkg/internal/partiql"
+)
+
+// Statement is a statement of a migration file, Line is where it starts
+type Statement struct {
+	Text string
+	File string
+	Line int
+}
+
+func (s Statement) Position() string {
+	return fmt.Sprintf("%s:%d", s.File, s.Line)
+}
+
+// migrationName matches the migration files: the version, a dash or an underscore and a description,
+// e.g. 1-migration.sql or 20240312_contract_index.sql. Versions don't need to be contiguous
+var migrationName = regexp.MustCompile(`^(\d+)[-_].*\.sql$`)
+
+// migrationFiles are the migrations found in the up and down directories of a file system, and the Go migrations
+type migrationFiles struct {
+	fsys     fs.FS
+	up       map[int]string // file names by version
+	down     map[int]string
+	code     map[int]GoMigration
+	versions []int // versions with an up file or a Go migration, ascending
+}
+
+// loadMigrations discovers the migration files of fsys, e.g. os.DirFS("sql") or an embed.FS holding up and down,
+// and merges the Go migrations. Every .sql file must be named after its version, and a version can only have
+// one file per direction or a Go migration
+func loadMigrations(fsys fs.FS, code ...GoMigration) (*migrationFiles, error) {
+	files := &migrationFiles{fsys: fsys, up: map[int]string{}, down: map[int]string{}, code: map[int]GoMigration{}}
+
+	for _, dir := range []string{upMigration, downMigration} {
+		entries, err := fs.ReadDir(fsys, dir)
+		if dir == downMigration && errors.Is(err, fs.ErrNotExist) {
+			// migrations that never go down don't need the directory
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		byVersion := files.up
+		if dir == downMigration {
+			byVersion = files.down
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+				continue
+			}
+
+			name := path.Join(dir, entry.Name())
+
+			match := migrationName.FindStringSubmatch(entry.Name())
+			if match == nil {
+				return nil, fmt.Errorf("%w: %s is not named <version>-<description>.sql", ErrInvalidMigration, name)
+			}
+
+			version, err := strconv.Atoi(match[1])
+			if err != nil || version == 0 {
+				return nil, fmt.Errorf("%w: %s has an invalid version, versions start at 1", ErrInvalidMigration, name)
+			}
+
+			if other, ok := byVersion[version]; ok {
+				return nil, fmt.Errorf("%w: %s and %s have the same version", ErrInvalidMigration, other, name)
+			}
+
+			byVersion[version] = name
+		}
+	}
+
+	for version, name := range files.down {
+		if _, ok := files.up[version]; !ok {
+			return nil, fmt.Errorf("%w: %s has no up migration", ErrInvalidMigration, name)
+		}
+	}
+
+	for _, migration := range code {
+		switch {
+		case migration.Version <= 0:
+			return nil, fmt.Errorf("%w: %s has an invalid version, versions start at 1", ErrInvalidMigration, migration.name())
+		case migration.Up == nil:
+			return nil, fmt.Errorf("%w: %s has no up function", ErrInvalidMigration, migration.name())
+		}
+
+		if name, ok := files.up[migration.Version]; ok {
+			return nil, fmt.Errorf("%w: %s and %s have the same version", ErrInvalidMigration, name, migration.name())
+		}
+
+		if other, ok := files.code[migration.Version]; ok {
+			return nil, fmt.Errorf("%w: %s and %s have the same version", ErrInvalidMigration, other.name(), migration.name())
+		}
+
+		files.code[migration.Version] = migration
+	}
+
+	for version := range files.up {
+		files.versions = append(files.versions, version)
+	}
+
+	for version := range files.code {
+		files.versions = append(files.versions, version)
+	}
+
+	sort.Ints(files.versions)
+
+	return files, nil
+}
+
+func (f *migrationFiles) has(version int) bool {
+	_, isFile := f.up[version]
+	_, isCode := f.code[version]
+
+	return isFile || isCode
+}
+
+// name returns the file or the Go migration of a version, or a pattern naming the missing file
+func (f *migrationFiles) name(migrationType string, version int) string {
+	if migration, ok := f.code[version]; ok {
+		return migration.name()
+	}
+
+	byVersion := f.up
+	if migrationType == downMigration {
+		byVersion = f.down
+	}
+
+	if name, ok := byVersion[version]; ok {
+		return name
+	}
+
+	return path.Join(migrationType, strconv.Itoa(version)+"-*.sql")
+}
+
+// read parses the statements of a migration version, a missing file matches fs.ErrNotExist
+func (f *migrationFiles) read(migrationType string, version int) ([]Statement, error) {
+	name := f.name(migrationType, version)
+
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer closeFile(file)
+
+	return ParseMigration(name, file)
+}
+
+// checksum identifies the content of a migration file. It is computed from the tokens of the statements,
+// so editing comments or whitespace is not a change, but editing any statement is
+func checksum(statements []Statement) string {
+	hash := sha256.New()
+
+	for _, statement := range statements {
+		tokens, err := partiql.Lex(statement.Text)
+		if err != nil {
+			// a statement the lexer rejects can't run either, its text is enough to tell it changed
+			fmt.Fprintf(hash, "%q;", statement.Text)
+			continue
+		}
+
+		for _, t := range tokens {
+			fmt.Fprintf(hash, "%d%q ", t.Kind, t.Text)
+		}
+
+		hash.Write([]byte{';'})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ParseMigration splits a migration file into statements separated by semicolons.
+// Semicolons inside 'strings', "quoted identifiers", `Ion literals`, -- line and /* block */ comments don't
+// split statements, and comments are left out of the statements. A file without any statement is an error
+func ParseMigration(name string, r io.Reader) ([]Statement, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &migrationParser{file: name, src: []rune(string(content)), line: 1}
+
+	statements, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(statements) == 0 {
+		return nil, &ParseError{File: name, Line: p.line, Reason: "no statements"}
+	}
+
+	return statements, nil
+}
+
+type migrationParser struct {
+	file string
+	src  []rune
+	pos  int
+	line int
+}
+
+func (p *migrationParser) parse() ([]Statement, error) {
+	var (
+		statements []Statement
+		current    strings.Builder
+		startLine  int
+	)
+
+	flush := func() {
+		text := strings.TrimSpace(current.String())
+		if text != "" {
+			statements = append(statements, Statement{Text: text, File: p.file, Line: startLine})
+		}
+
+		current.Reset()
+		startLine = 0
+	}
+
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+
+		switch {
+		case r == '-' && p.peek(1) == '-':
+			p.skipLineComment()
+			// a comment separates tokens like a space
+			current.WriteRune(' ')
+
+			continue
+		case r == '/' && p.peek(1) == '*':
+			if err := p.skipBlockComment(); err != nil {
+				return nil, err
+			}
+
+			current.WriteRune(' ')
+
+			continue
+		case r == ';':
+			p.pos++
+			flush()
+
+			continue
+		}
+
+		if startLine == 0 && !isSpace(r) {
+			startLine = p.line
+		}
+
+		switch r {
+		case '\'', '"', '`':
+			text, err := p.quoted(r)
+			if err != nil {
+				return nil, err
+			}
+
+			current.WriteString(text)
+		default:
+			p.advance()
+			current.WriteRune(r)
+		}
+	}
+
+	flush()
+
+	return statements, nil
+}
+
+func (p *migrationParser) peek(offset int) rune {
+	if p.pos+offset >= len(p.src) {
+		return 0
+	}
+
+	return p.src[p.pos+offset]
+}
+
+func (p *migrationParser) advance() {
+	if p.src[p.pos] == '\n' {
+		p.line++
+	}
+
+	p.pos++
+}
+
+func (p *migrationParser) skipLineComment() {
+	for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+		p.pos++
+	}
+}
+
+func (p *migrationParser) skipBlockComment() error {
+	line := p.line
+	p.pos += 2
+
+	for p.pos < len(p.src) {
+		if p.src[p.pos] == '*' && p.peek(1) == '/' {
+			p.pos += 2
+			return nil
+		}
+
+		p.advance()
+	}
+
+	return &ParseError{File: p.file, Line: line, Reason: "unterminated block comment"}
+}
+
+// quoted returns the literal starting at the current position, quotes included.
+// A doubled quote escapes it in strings and identifiers, Ion literals end at the next backtick
+func (p *migrationParser) quoted(quote rune) (string, error) {
+	line := p.line
+	start := p.pos
+
+	p.advance()
+
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		p.advance()
+
+		if r != quote {
+			continue
+		}
+
+		if quote != '`' && p.pos < len(p.src) && p.src[p.pos] == quote {
+			p.advance()
+			continue
+		}
+
+		return string(p.src[start:p.pos]), nil
+	}
+
+	kind := map[rune]string{'\'': "string", '"': "quoted identifier", '`': "Ion literal"}[quote]
+
+	return "", &ParseError{File: p.file, Line: line, Reason: "unterminated " + kind}
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
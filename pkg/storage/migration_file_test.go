@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/storage/fake"
+)
+
+func TestParseMigration(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		statements []Statement
+	}{
+		{
+			name:    "one per line",
+			content: "CREATE TABLE A;\nCREATE INDEX ON A(id);\n",
+			statements: []Statement{
+				{Text: "CREATE TABLE A", File: "m.sql", Line: 1},
+				{Text: "CREATE INDEX ON A(id)", File: "m.sql", Line: 2},
+			},
+		},
+		{
+			name:    "several per line and no trailing semicolon",
+			content: "DROP TABLE A;CREATE TABLE B",
+			statements: []Statement{
+				{Text: "DROP TABLE A", File: "m.sql", Line: 1},
+				{Text: "CREATE TABLE B", File: "m.sql", Line: 1},
+			},
+		},
+		{
+			name:    "multi-line with comments and blank lines",
+			content: "-- contracts\n\nUPDATE Contract AS c -- the whole table\n  SET c.network = 'eth;mainnet'\n  /* no WHERE;\n     on purpose */\n;\n\nINSERT INTO A `{a:\"x;y\"}`;",
+			statements: []Statement{
+				{Text: "UPDATE Contract AS c  \n  SET c.network = 'eth;mainnet'\n   \n", File: "m.sql", Line: 3},
+				{Text: "INSERT INTO A `{a:\"x;y\"}`", File: "m.sql", Line: 9},
+			},
+		},
+		{
+			name:    "escaped quotes",
+			content: "INSERT INTO A << {'name': 'it''s; fine'} >>;\nCREATE TABLE \"My;Table\";",
+			statements: []Statement{
+				{Text: "INSERT INTO A << {'name': 'it''s; fine'} >>", File: "m.sql", Line: 1},
+				{Text: "CREATE TABLE \"My;Table\"", File: "m.sql", Line: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements, err := ParseMigration("m.sql", strings.NewReader(tt.content))
+			require.NoError(t, err)
+
+			for i := range tt.statements {
+				tt.statements[i].Text = strings.TrimSpace(tt.statements[i].Text)
+			}
+
+			assert.Equal(t, tt.statements, statements)
+		})
+	}
+}
+
+func TestParseMigration_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{name: "empty", content: "", err: "m.sql:1: no statements"},
+		{name: "only comments", content: "-- nothing yet\n;\n", err: "m.sql:3: no statements"},
+		{name: "unterminated string", content: "CREATE TABLE A;\nINSERT INTO A << {'a': 'b} >>;", err: "m.sql:2: unterminated string"},
+		{name: "unterminated comment", content: "CREATE TABLE A;\n\n/* drop B;", err: "m.sql:3: unterminated block comment"},
+		{name: "unterminated ion literal", content: "INSERT INTO A `{a:1}", err: "m.sql:1: unterminated Ion literal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMigration("m.sql", strings.NewReader(tt.content))
+			assert.ErrorIs(t, err, ErrInvalidMigration)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestDBMigrator_MigrateUp_Positions(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql": "CREATE TABLE Migration;\n\nCREATE TABLE\n  Contract;\nCREATE TABLE Contract;\n",
+	})
+
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}}
+
+	err := dbm.MigrateQLDB(context.Background(), fsys, 1)
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+	assert.ErrorContains(t, err, "up/1-migration.sql:5")
+
+	tables, err := dbm.Driver.GetTableNames(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{lockTable}, tables, "the migration runs in one transaction")
+
+	fsys["up/1-migration.sql"].Data = nil
+
+	err = dbm.MigrateQLDB(context.Background(), fsys, 1)
+	assert.ErrorIs(t, err, ErrInvalidMigration)
+}
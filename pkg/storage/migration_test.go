@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
+	"github.com/carflores-zh/qldb-go/pkg/storage/fake"
+	"github.com/carflores-zh/qldb-go/sql"
+)
+
+func migrationsFS(files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for name, content := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+
+	return fsys
+}
+
+func TestLoadMigrations(t *testing.T) {
+	files, err := loadMigrations(migrationsFS(map[string]string{
+		"up/1-migration.sql":             "CREATE TABLE Migration;",
+		"up/20240312_contract_index.sql": "CREATE INDEX ON Contract (id);",
+		"up/5-share.sql":                 "CREATE TABLE Share;",
+		"up/README.md":                   "not a migration",
+		"down/5-share.sql":               "DROP TABLE Share;",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{1, 5, 20240312}, files.versions)
+	assert.Equal(t, "up/20240312_contract_index.sql", files.name(upMigration, 20240312))
+	assert.Equal(t, "down/1-*.sql", files.name(downMigration, 1))
+
+	_, err = files.read(downMigration, 1)
+	assert.ErrorContains(t, err, "open down/1-*.sql: file does not exist")
+
+	files, err = loadMigrations(sql.FS)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, files.versions, "the embedded migrations")
+
+	for name, tt := range map[string]struct {
+		files map[string]string
+		err   string
+	}{
+		"no-up":        {files: map[string]string{"down/1-m.sql": "DROP TABLE A;"}, err: "open up: file does not exist"},
+		"bad-name":     {files: map[string]string{"up/migration.sql": "CREATE TABLE A;"}, err: "up/migration.sql is not named <version>-<description>.sql"},
+		"zero-version": {files: map[string]string{"up/0-m.sql": "CREATE TABLE A;"}, err: "versions start at 1"},
+		"duplicate":    {files: map[string]string{"up/1-a.sql": "CREATE TABLE A;", "up/01-b.sql": "CREATE TABLE B;"}, err: "have the same version"},
+		"orphan-down":  {files: map[string]string{"up/1-a.sql": "CREATE TABLE A;", "down/2-b.sql": "DROP TABLE B;"}, err: "down/2-b.sql has no up migration"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := loadMigrations(migrationsFS(tt.files))
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestDBMigrator_MigrateQLDB_Versions(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql":   "CREATE TABLE Migration;",
+		"up/10-contract.sql":   "CREATE TABLE Contract;",
+		"up/20-share.sql":      "CREATE TABLE Share;",
+		"down/10-contract.sql": "DROP TABLE Contract;",
+		"down/20-share.sql":    "DROP TABLE Share;",
+	})
+
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}}
+
+	assert.ErrorContains(t, dbm.MigrateQLDB(ctx, fsys, 2), "there is no migration for version 2, versions are [1 10 20]")
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 20))
+
+	plan, err := dbm.Plan(ctx, fsys, 1)
+	require.NoError(t, err)
+	require.Len(t, plan.Steps, 2)
+	assert.Equal(t, []int{20, 10}, []int{plan.Steps[0].Version, plan.Steps[1].Version})
+	assert.Equal(t, []int{10, 1}, []int{plan.Steps[0].Target, plan.Steps[1].Target})
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 1))
+
+	migrations, err := dbm.GetMigrations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, getMostRecentVersion(migrations).Version)
+
+	tables, err := dbm.Driver.GetTableNames(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Migration", lockTable}, tables)
+}
+
+func TestDBMigrator_MigrateUpDown(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql":  "CREATE TABLE Migration;",
+		"up/2-contract.sql":   "CREATE TABLE Contract;",
+		"down/2-contract.sql": "DROP TABLE Contract;",
+	})
+
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}}
+
+	assert.ErrorIs(t, dbm.MigrateDown(ctx, model.Migration{}, 2, fsys), ErrInvalidMigration)
+	require.NoError(t, dbm.MigrateUp(ctx, model.Migration{}, 2, fsys))
+
+	migrations, err := dbm.GetMigrations(ctx)
+	require.NoError(t, err)
+
+	mostRecent := getMostRecentVersion(migrations)
+	assert.Equal(t, 2, mostRecent.Version)
+
+	assert.ErrorIs(t, dbm.MigrateUp(ctx, mostRecent, 1, fsys), ErrInvalidMigration)
+	require.NoError(t, dbm.MigrateDown(ctx, mostRecent, 1, fsys))
+
+	tables, err := dbm.Driver.GetTableNames(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Migration"}, tables)
+}
+
+func TestDBMigrator_MigrateQLDB_DropIndexByPath(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql":   "CREATE TABLE Migration;\nCREATE TABLE A;\nCREATE INDEX ON A(ID);",
+		"up/2-migration.sql":   "DROP INDEX ON A(ID);\nCREATE INDEX ON A(id);",
+		"down/2-migration.sql": "DROP INDEX ON A(id);\nCREATE INDEX ON A(ID);",
+		"up/3-migration.sql":   "DROP INDEX ON A(missing);",
+	})
+
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}}
+
+	indexes := func() []string {
+		tables, err := dbm.UserTables(ctx)
+		require.NoError(t, err)
+
+		var exprs []string
+
+		for _, table := range tables {
+			if table.Name == "A" {
+				for _, index := range table.Indexes {
+					exprs = append(exprs, index.Expr)
+				}
+			}
+		}
+
+		return exprs
+	}
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 2))
+	assert.Equal(t, []string{"[id]"}, indexes())
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 1))
+	assert.Equal(t, []string{"[ID]"}, indexes())
+
+	err := dbm.MigrateQLDB(ctx, fsys, 3)
+	assert.ErrorIs(t, err, ErrMigrationFailed)
+	assert.ErrorContains(t, err, "there is no index on A(missing)")
+	assert.Equal(t, []string{"[id]"}, indexes(), "2 applied before 3 failed")
+}
+
+func TestDBMigrator_MigrateQLDB_UndropByName(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql":   "CREATE TABLE Migration;\nCREATE TABLE A;\nCREATE INDEX ON A(\"to\");",
+		"up/2-migration.sql":   "DROP TABLE A;",
+		"down/2-migration.sql": "UNDROP TABLE A;",
+	})
+
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}}
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 1))
+
+	_, err := Execute(ctx, dbm.Driver, func(txn qldbdriver.Transaction) (qldbdriver.Result, error) {
+		return txn.Execute(`INSERT INTO A ?`, map[string]interface{}{"to": "0x1"})
+	})
+	require.NoError(t, err)
+
+	tables := func() []metadata.UserTable {
+		var found []metadata.UserTable
+
+		all, err := dbm.UserTables(ctx)
+		require.NoError(t, err)
+
+		for _, table := range all {
+			if table.Name == "A" {
+				found = append(found, table)
+			}
+		}
+
+		return found
+	}
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 2))
+	require.Len(t, tables(), 1)
+	assert.Equal(t, tableInactive, tables()[0].Status)
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 1))
+	require.Len(t, tables(), 1)
+	assert.Equal(t, tableActive, tables()[0].Status, "the table is undropped by id")
+	assert.Equal(t, "[to]", tables()[0].Indexes[0].Expr)
+
+	documents, err := Execute(ctx, dbm.Driver, func(txn qldbdriver.Transaction) ([][]byte, error) {
+		return QueryRaw(ctx, txn, `SELECT * FROM A AS a WHERE a."to" = '0x1'`)
+	})
+	require.NoError(t, err)
+	assert.Len(t, documents, 1, "the documents are undropped with the table")
+
+	// dropped twice, the name is ambiguous
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 2))
+	_, err = Execute(ctx, dbm.Driver, func(txn qldbdriver.Transaction) (qldbdriver.Result, error) {
+		if _, errCreate := txn.Execute("CREATE TABLE A"); errCreate != nil {
+			return nil, errCreate
+		}
+
+		return txn.Execute("DROP TABLE A")
+	})
+	require.NoError(t, err)
+
+	err = dbm.MigrateQLDB(ctx, fsys, 1)
+	assert.ErrorIs(t, err, ErrMigrationFailed)
+	assert.ErrorContains(t, err, "table A was dropped 2 times, undrop it by id")
+}
+
+func TestDBMigrator_MigrateQLDB_Records(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql":   "CREATE TABLE Migration;\nCREATE TABLE A;",
+		"up/2-migration.sql":   "CREATE TABLE B;",
+		"down/2-migration.sql": "DROP TABLE B;",
+	})
+
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}, Principal: "arn:aws:iam::123456789012:user/migrator"}
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 2))
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 1))
+
+	migrations, err := dbm.GetMigrations(ctx)
+	require.NoError(t, err)
+	require.Len(t, migrations, 3)
+
+	upTwo, down := migrations[1], migrations[2]
+	if upTwo.Direction != upMigration {
+		upTwo, down = down, upTwo
+	}
+
+	assert.Equal(t, 2, upTwo.Version)
+	assert.Equal(t, checksum([]Statement{{Text: "CREATE TABLE B"}}), upTwo.Checksum)
+	assert.Equal(t, 1, down.Version)
+	assert.Equal(t, downMigration, down.Direction)
+	assert.Equal(t, checksum([]Statement{{Text: "DROP TABLE B"}}), down.Checksum)
+
+	for _, migration := range migrations {
+		assert.Equal(t, "arn:aws:iam::123456789012:user/migrator", migration.Principal)
+		assert.GreaterOrEqual(t, migration.DurationMs, int64(0))
+	}
+}
+
+func TestDBMigrator_MigrateQLDB_Drift(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql": "CREATE TABLE Migration;\nCREATE TABLE A;",
+		"up/2-migration.sql": "CREATE TABLE B;",
+	})
+
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}}
+
+	require.NoError(t, dbm.CheckDrift(ctx, fsys), "a ledger never migrated has no Migration table")
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 1))
+
+	// comments and formatting are not drift
+	fsys["up/1-migration.sql"].Data = []byte("-- tables\nCREATE TABLE Migration;\n\nCREATE TABLE   A;\n")
+	require.NoError(t, dbm.CheckDrift(ctx, fsys))
+
+	fsys["up/1-migration.sql"].Data = []byte("CREATE TABLE Migration;\nCREATE TABLE A;\nCREATE INDEX ON A (id);")
+
+	err := dbm.CheckDrift(ctx, fsys)
+	assert.ErrorIs(t, err, ErrMigrationDrift)
+	assert.ErrorContains(t, err, "up/1-migration.sql changed since it was applied")
+
+	err = dbm.MigrateQLDB(ctx, fsys, 2)
+	assert.ErrorIs(t, err, ErrMigrationDrift)
+
+	tables, err := dbm.Driver.GetTableNames(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, tables, "B", "no migration runs on drift")
+
+	fsys["up/1-renamed.sql"] = fsys["up/1-migration.sql"]
+	delete(fsys, "up/1-migration.sql")
+	assert.ErrorContains(t, dbm.CheckDrift(ctx, fsys), "up/1-renamed.sql changed since it was applied")
+
+	delete(fsys, "up/1-renamed.sql")
+	assert.ErrorContains(t, dbm.CheckDrift(ctx, fsys), "open up/1-*.sql: file does not exist")
+
+	dbm.IgnoreDrift = true
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 2))
+}
+
+func TestGetMostRecentVersion(t *testing.T) {
+	now := time.Now()
+
+	migrations := []model.Migration{
+		{Version: 1, MigratedAt: now.Add(-time.Hour)},                                   // written before sequences
+		{Version: 3, MigratedAt: now.Add(-2 * time.Minute), Sequence: 2},                // written by a migrator whose clock is behind
+		{Version: 2, MigratedAt: now, Sequence: 1},                                      // written by a migrator whose clock is ahead
+		{Version: 3, MigratedAt: now, Sequence: 3, Status: migrationFailed, Error: "x"}, // rolled back
+	}
+
+	assert.Equal(t, 3, getMostRecentVersion(migrations).Version)
+	assert.Equal(t, int64(2), getMostRecentVersion(migrations).Sequence)
+	assert.Equal(t, migrationFailed, getLastRun(migrations).Status)
+
+	legacy := []model.Migration{{Version: 2, MigratedAt: now}, {Version: 1, MigratedAt: now}, {Version: 1, MigratedAt: now.Add(-time.Second)}}
+	assert.Equal(t, 2, getMostRecentVersion(legacy).Version, "records written at the same instant are ordered by version")
+	assert.Equal(t, model.Migration{}, getMostRecentVersion(nil))
+}
+
+func TestDBMigrator_MigrateQLDB_Failure(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql":   "CREATE TABLE Migration;",
+		"up/2-migration.sql":   "CREATE TABLE A;\nCREATE TABLE A;",
+		"down/2-migration.sql": "DROP TABLE B;",
+	})
+
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}}
+
+	err := dbm.MigrateQLDB(ctx, fsys, 2)
+	assert.ErrorIs(t, err, ErrMigrationFailed)
+	assert.ErrorIs(t, err, ErrConstraintViolation)
+	assert.ErrorContains(t, err, "up/2-migration.sql up, the ledger stays at version 1")
+
+	tables, err := dbm.Driver.GetTableNames(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Migration", lockTable}, tables, "the first statement of the failed step is rolled back")
+
+	status, err := dbm.Status(ctx, fsys)
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.Current.Version)
+	require.NotNil(t, status.Failed)
+	assert.Equal(t, 1, status.Failed.Version)
+	assert.Equal(t, int64(2), status.Failed.Sequence)
+	assert.Contains(t, status.Failed.Error, "Table with name: A already exists")
+	assert.Contains(t, status.String(), "last run failed: up/2-migration.sql up on ")
+
+	fsys["up/2-migration.sql"].Data = []byte("CREATE TABLE A;")
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 2), "the fixed step runs again")
+
+	status, err = dbm.Status(ctx, fsys)
+	require.NoError(t, err)
+	assert.Equal(t, 2, status.Current.Version)
+	assert.Equal(t, int64(3), status.Current.Sequence)
+	assert.Nil(t, status.Failed)
+
+	// a down statement the ledger rejects stops the run instead of recording the version
+	err = dbm.MigrateQLDB(ctx, fsys, 1)
+	assert.ErrorIs(t, err, ErrMigrationFailed)
+	assert.ErrorContains(t, err, "the ledger stays at version 2")
+
+	migrations, err := dbm.GetMigrations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, getMostRecentVersion(migrations).Version)
+
+	last := getLastRun(migrations)
+	assert.Equal(t, []interface{}{2, downMigration, migrationFailed, int64(4)}, []interface{}{last.Version, last.Direction, last.Status, last.Sequence})
+}
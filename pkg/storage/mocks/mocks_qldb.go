@@ -2,29 +2,49 @@ package mocks
 
 import (
 	"context"
+
 	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
 	"github.com/stretchr/testify/mock"
 )
 
+// MockQLDBDriver runs the transaction functions against Txn, honoring the context like the real driver does
 type MockQLDBDriver struct {
-	Txn MockTransaction
+	Txn *MockTransaction
+}
+
+func NewMockQLDBDriver() *MockQLDBDriver {
+	return &MockQLDBDriver{
+		Txn: &MockTransaction{},
+	}
 }
 
-func (mqd MockQLDBDriver) Execute(ctx context.Context, fn func(txn qldbdriver.Transaction) (interface{}, error)) (interface{}, error) {
+func (mqd *MockQLDBDriver) Execute(ctx context.Context, fn func(txn qldbdriver.Transaction) (interface{}, error)) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	result, err := fn(mqd.Txn)
+	if err != nil {
+		return nil, err
+	}
 
-	return result, err
+	// the real driver fails the commit when the context is done while the transaction runs
+	if errCtx := ctx.Err(); errCtx != nil {
+		return nil, errCtx
+	}
+
+	return result, nil
 }
 
-func (mqd MockQLDBDriver) SetRetryPolicy(retryPolicy qldbdriver.RetryPolicy) {
+func (mqd *MockQLDBDriver) SetRetryPolicy(retryPolicy qldbdriver.RetryPolicy) {
 	panic("not used")
 }
 
-func (mqd MockQLDBDriver) GetTableNames(ctx context.Context) ([]string, error) {
+func (mqd *MockQLDBDriver) GetTableNames(ctx context.Context) ([]string, error) {
 	panic("not used")
 }
 
-func (mqd MockQLDBDriver) Shutdown(ctx context.Context) {
+func (mqd *MockQLDBDriver) Shutdown(ctx context.Context) {
 	panic("not used")
 }
 
@@ -32,20 +52,20 @@ type MockTransaction struct {
 	mock.Mock
 }
 
-func (mt MockTransaction) Execute(statement string, parameters ...interface{}) (qldbdriver.Result, error) {
+func (mt *MockTransaction) Execute(statement string, parameters ...interface{}) (qldbdriver.Result, error) {
 	args := mt.Called(statement, parameters)
-	return args.Get(0).(MockResult), args.Error(1)
+	return args.Get(0).(*MockResult), args.Error(1)
 }
 
-func (mt MockTransaction) BufferResult(res qldbdriver.Result) (qldbdriver.BufferedResult, error) {
+func (mt *MockTransaction) BufferResult(res qldbdriver.Result) (qldbdriver.BufferedResult, error) {
 	panic("not used")
 }
 
-func (mt MockTransaction) Abort() error {
+func (mt *MockTransaction) Abort() error {
 	panic("not used")
 }
 
-func (mt MockTransaction) ID() string {
+func (mt *MockTransaction) ID() string {
 	panic("not used")
 }
 
@@ -53,24 +73,25 @@ type MockResult struct {
 	mock.Mock
 }
 
-func (mr MockResult) Next(txn qldbdriver.Transaction) bool {
+func (mr *MockResult) Next(txn qldbdriver.Transaction) bool {
 	args := mr.Called(txn)
 	return args.Get(0).(bool)
 }
 
-func (mr MockResult) GetCurrentData() []byte {
+func (mr *MockResult) GetCurrentData() []byte {
 	args := mr.Called()
 	return args.Get(0).([]byte)
 }
 
-func (mr MockResult) Err() error {
-	panic("not used")
+func (mr *MockResult) Err() error {
+	args := mr.Called()
+	return args.Error(0)
 }
 
-func (mr MockResult) GetConsumedIOs() *qldbdriver.IOUsage {
+func (mr *MockResult) GetConsumedIOs() *qldbdriver.IOUsage {
 	panic("not used")
 }
 
-func (mr MockResult) GetTimingInformation() *qldbdriver.TimingInformation {
+func (mr *MockResult) GetTimingInformation() *qldbdriver.TimingInformation {
 	panic("not used")
 }
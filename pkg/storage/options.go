@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+	"github.com/rs/zerolog"
+)
+
+const defaultRetryMaxAttempts = 3
+
+// options are the knobs of New and NewMigrator, zero values keep the SDK and driver defaults
+type options struct {
+	retryMaxAttempts          int
+	endpoint                  string
+	maxConcurrentTransactions int
+	retryPolicy               *qldbdriver.RetryPolicy
+	logger                    qldbdriver.Logger
+	loggerVerbosity           qldbdriver.LogLevel
+	migrationPolicy           *MigrationPolicy
+	principal                 string
+	ignoreDrift               bool
+	lockTTL                   time.Duration
+	readyTimeout              time.Duration
+	goMigrations              []GoMigration
+}
+
+// Option configures the clients created by New and NewMigrator
+type Option func(*options)
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		retryMaxAttempts: defaultRetryMaxAttempts,
+		loggerVerbosity:  qldbdriver.LogInfo,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithRetryMaxAttempts sets the max attempts of the SDK clients for every API call (default 3)
+func WithRetryMaxAttempts(attempts int) Option {
+	return func(o *options) {
+		o.retryMaxAttempts = attempts
+	}
+}
+
+// WithEndpoint overrides the QLDB endpoints, e.g. http://localhost:4566 for LocalStack
+func WithEndpoint(url string) Option {
+	return func(o *options) {
+		o.endpoint = url
+	}
+}
+
+// WithMaxConcurrentTransactions sets the size of the driver session pool (driver default 50)
+func WithMaxConcurrentTransactions(transactions int) Option {
+	return func(o *options) {
+		o.maxConcurrentTransactions = transactions
+	}
+}
+
+// WithRetryPolicy sets how the driver retries transactions on OCC conflicts and recoverable errors
+func WithRetryPolicy(retryPolicy qldbdriver.RetryPolicy) Option {
+	return func(o *options) {
+		o.retryPolicy = &retryPolicy
+	}
+}
+
+// WithLogger sends the driver logs to logger instead of the standard log package
+func WithLogger(logger zerolog.Logger) Option {
+	return func(o *options) {
+		o.logger = &driverLogger{logger: logger}
+	}
+}
+
+// WithLoggerVerbosity sets the driver log level (default qldbdriver.LogInfo)
+func WithLoggerVerbosity(verbosity qldbdriver.LogLevel) Option {
+	return func(o *options) {
+		o.loggerVerbosity = verbosity
+	}
+}
+
+// WithMigrationPolicy sets the statement classes NewMigrator allows in up and down migrations (default DefaultMigrationPolicy)
+func WithMigrationPolicy(policy MigrationPolicy) Option {
+	return func(o *options) {
+		o.migrationPolicy = &policy
+	}
+}
+
+// WithPrincipal sets who NewMigrator records as running the migrations, e.g. the caller IAM ARN (default the OS user)
+func WithPrincipal(principal string) Option {
+	return func(o *options) {
+		o.principal = principal
+	}
+}
+
+// WithIgnoreDrift makes NewMigrator log applied migrations whose files changed instead of refusing to migrate
+func WithIgnoreDrift() Option {
+	return func(o *options) {
+		o.ignoreDrift = true
+	}
+}
+
+// WithLockTTL sets how long NewMigrator holds the migration lock before it is considered stale (default 30 minutes)
+func WithLockTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.lockTTL = ttl
+	}
+}
+
+// WithReadyTimeout sets how long NewMigrator waits for the tables and indexes of a migration to be ready (default 5 minutes)
+func WithReadyTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.readyTimeout = timeout
+	}
+}
+
+// WithGoMigrations registers Go migrations that NewMigrator runs interleaved with the SQL files by version
+func WithGoMigrations(migrations ...GoMigration) Option {
+	return func(o *options) {
+		o.goMigrations = append(o.goMigrations, migrations...)
+	}
+}
+
+// driverLogger adapts a zerolog.Logger to qldbdriver.Logger
+type driverLogger struct {
+	logger zerolog.Logger
+}
+
+func (l *driverLogger) Log(message string, verbosity qldbdriver.LogLevel) {
+	if verbosity == qldbdriver.LogDebug {
+		l.logger.Debug().Msg(strings.TrimPrefix(message, "[DEBUG] "))
+		return
+	}
+
+	l.logger.Info().Msg(strings.TrimPrefix(message, "[INFO] "))
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOptions(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		o := newOptions()
+
+		assert.Equal(t, defaultRetryMaxAttempts, o.retryMaxAttempts)
+		assert.Equal(t, qldbdriver.LogInfo, o.loggerVerbosity)
+		assert.Nil(t, o.retryPolicy)
+		assert.Nil(t, o.logger)
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		retryPolicy := qldbdriver.RetryPolicy{
+			MaxRetryLimit: 10,
+			Backoff:       qldbdriver.ExponentialBackoffStrategy{SleepBase: time.Millisecond, SleepCap: time.Second},
+		}
+
+		o := newOptions(
+			WithRetryMaxAttempts(5),
+			WithEndpoint("http://localhost:4566"),
+			WithMaxConcurrentTransactions(7),
+			WithRetryPolicy(retryPolicy),
+			WithLoggerVerbosity(qldbdriver.LogDebug),
+		)
+
+		assert.Equal(t, 5, o.retryMaxAttempts)
+		assert.Equal(t, "http://localhost:4566", o.endpoint)
+		assert.Equal(t, 7, o.maxConcurrentTransactions)
+		assert.Equal(t, &retryPolicy, o.retryPolicy)
+		assert.Equal(t, qldbdriver.LogDebug, o.loggerVerbosity)
+	})
+}
+
+func TestDriverLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	o := newOptions(WithLogger(zerolog.New(&buf)))
+	o.logger.Log("[INFO] A recoverable error has occurred.", qldbdriver.LogInfo)
+
+	assert.Contains(t, buf.String(), `"level":"info"`)
+	assert.Contains(t, buf.String(), `"message":"A recoverable error has occurred."`)
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/carflores-zh/qldb-go/pkg/internal/partiql"
+)
+
+// StatementClass is the kind of a PartiQL statement
+type StatementClass int
+
+const (
+	ClassUnknown   StatementClass = iota
+	ClassDDL                      // CREATE/DROP/UNDROP TABLE, CREATE/DROP INDEX
+	ClassDML                      // INSERT, UPDATE, DELETE and FROM ... SET/INSERT/REMOVE
+	ClassQuery                    // SELECT
+	ClassProcedure                // EXEC of a stored procedure, e.g. redact_revision
+)
+
+func (c StatementClass) String() string {
+	switch c {
+	case ClassDDL:
+		return "DDL"
+	case ClassDML:
+		return "DML"
+	case ClassQuery:
+		return "query"
+	case ClassProcedure:
+		return "stored procedure"
+	default:
+		return "unknown"
+	}
+}
+
+// ClassifyStatement returns the class of a PartiQL statement from its leading keywords,
+// the error gives the reason when the statement can't be classified
+func ClassifyStatement(statement string) (StatementClass, error) {
+	tokens, err := partiql.Lex(statement)
+	if err != nil {
+		return ClassUnknown, err
+	}
+
+	if len(tokens) == 0 {
+		return ClassUnknown, fmt.Errorf("empty statement")
+	}
+
+	word := func(i int) partiql.Token {
+		if i < len(tokens) {
+			return tokens[i]
+		}
+
+		return partiql.Token{}
+	}
+
+	first := word(0)
+
+	switch {
+	case first.Keyword("CREATE"):
+		if word(1).Keyword("TABLE") || word(1).Keyword("INDEX") {
+			return ClassDDL, nil
+		}
+
+		return ClassUnknown, fmt.Errorf("CREATE must be followed by TABLE or INDEX")
+	case first.Keyword("DROP"):
+		if word(1).Keyword("TABLE") || word(1).Keyword("INDEX") {
+			return ClassDDL, nil
+		}
+
+		return ClassUnknown, fmt.Errorf("DROP must be followed by TABLE or INDEX")
+	case first.Keyword("UNDROP"):
+		if word(1).Keyword("TABLE") {
+			return ClassDDL, nil
+		}
+
+		return ClassUnknown, fmt.Errorf("UNDROP must be followed by TABLE")
+	case first.Keyword("INSERT"):
+		if word(1).Keyword("INTO") {
+			return ClassDML, nil
+		}
+
+		return ClassUnknown, fmt.Errorf("INSERT must be followed by INTO")
+	case first.Keyword("DELETE"):
+		if word(1).Keyword("FROM") {
+			return ClassDML, nil
+		}
+
+		return ClassUnknown, fmt.Errorf("DELETE must be followed by FROM")
+	case first.Keyword("UPDATE"):
+		return ClassDML, nil
+	case first.Keyword("FROM"):
+		// FROM x [WHERE ...] SET | INSERT INTO | REMOVE
+		for _, t := range tokens[1:] {
+			if t.Keyword("SET") || t.Keyword("INSERT") || t.Keyword("REMOVE") {
+				return ClassDML, nil
+			}
+		}
+
+		return ClassUnknown, fmt.Errorf("FROM statement without SET, INSERT or REMOVE")
+	case first.Keyword("SELECT"):
+		return ClassQuery, nil
+	case first.Keyword("EXEC"):
+		if word(1).Kind == partiql.Word {
+			return ClassProcedure, nil
+		}
+
+		return ClassUnknown, fmt.Errorf("EXEC must be followed by a stored procedure name")
+	default:
+		return ClassUnknown, fmt.Errorf("unknown statement %q", first.Text)
+	}
+}
+
+// MigrationPolicy lists the statement classes allowed in up and down migrations
+type MigrationPolicy struct {
+	Up   []StatementClass
+	Down []StatementClass
+}
+
+// DefaultMigrationPolicy allows DDL and DML in both directions, queries have no effect and
+// stored procedures (redactions) must not be run as part of a schema migration
+var DefaultMigrationPolicy = MigrationPolicy{
+	Up:   []StatementClass{ClassDDL, ClassDML},
+	Down: []StatementClass{ClassDDL, ClassDML},
+}
+
+// Check returns a *StatementError when statement is not allowed in a migrationType (up or down) migration
+func (p MigrationPolicy) Check(migrationType string, statement Statement) error {
+	class, err := ClassifyStatement(statement.Text)
+	if err != nil {
+		return &StatementError{Statement: statement.Text, Position: statement.Position(), Reason: err.Error()}
+	}
+
+	allowed := p.Up
+	if migrationType == downMigration {
+		allowed = p.Down
+	}
+
+	for _, c := range allowed {
+		if c == class {
+			return nil
+		}
+	}
+
+	return &StatementError{
+		Statement: statement.Text,
+		Position:  statement.Position(),
+		Reason:    fmt.Sprintf("%s statements are not allowed in %s migrations", class, migrationType),
+	}
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/storage/fake"
+)
+
+func TestClassifyStatement(t *testing.T) {
+	tests := []struct {
+		statement string
+		class     StatementClass
+		err       string
+	}{
+		{statement: "CREATE TABLE Contract", class: ClassDDL},
+		{statement: "create index on Contract (id)", class: ClassDDL},
+		{statement: "DROP TABLE Share", class: ClassDDL},
+		{statement: "DROP INDEX \"2Z8sXZ\" ON Contract WITH (purge = true)", class: ClassDDL},
+		{statement: "UNDROP TABLE \"5PLf9SXwndd63lPaSIa0O6\"", class: ClassDDL},
+		{statement: "INSERT INTO Contract << {'id': 'c1'} >>", class: ClassDML},
+		{statement: "UPDATE Contract AS c SET c.network = ? WHERE c.id = ?", class: ClassDML},
+		{statement: "DELETE FROM Contract AS c WHERE c.id = 'c1'", class: ClassDML},
+		{statement: "FROM Contract AS c WHERE c.id = 'c1' REMOVE c.input", class: ClassDML},
+		{statement: "SELECT * FROM X WHERE note = 'DROP'", class: ClassQuery},
+		{statement: "-- fix the data\nSELECT * FROM X", class: ClassQuery},
+		{statement: "/* EXEC */ EXEC redact_revision ?, ?, ?", class: ClassProcedure},
+		{statement: "SELECT '-- not a comment', `{a:\"/*\"}` FROM X", class: ClassQuery},
+		{statement: "  ", err: "empty statement"},
+		{statement: "CREATE VIEW V", err: "CREATE must be followed by TABLE or INDEX"},
+		{statement: "FROM Contract AS c WHERE c.id = 'SET'", err: "FROM statement without SET"},
+		{statement: "TRUNCATE Contract", err: `unknown statement "TRUNCATE"`},
+		{statement: "SELECT 'DROP", err: "unterminated string at offset 7"},
+		{statement: "SELECT 1 /* x", err: "unterminated block comment at offset 9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.statement, func(t *testing.T) {
+			class, err := ClassifyStatement(tt.statement)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.class, class)
+		})
+	}
+}
+
+func TestMigrationPolicy_Check(t *testing.T) {
+	statement := func(text string) Statement {
+		return Statement{Text: text, File: "up/3-migration.sql", Line: 4}
+	}
+
+	assert.NoError(t, DefaultMigrationPolicy.Check(upMigration, statement("CREATE TABLE A")))
+	assert.NoError(t, DefaultMigrationPolicy.Check(downMigration, statement("DROP TABLE A")))
+
+	err := DefaultMigrationPolicy.Check(upMigration, statement("SELECT * FROM A"))
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+	assert.ErrorContains(t, err, "up/3-migration.sql:4")
+	assert.ErrorContains(t, err, "query statements are not allowed in up migrations")
+
+	err = DefaultMigrationPolicy.Check(downMigration, statement("EXEC redact_revision ?, ?, ?"))
+	assert.ErrorContains(t, err, "stored procedure statements are not allowed in down migrations")
+
+	err = DefaultMigrationPolicy.Check(upMigration, statement("GRANT ALL"))
+	assert.ErrorContains(t, err, `unknown statement "GRANT"`)
+
+	ddlOnly := MigrationPolicy{Up: []StatementClass{ClassDDL}, Down: []StatementClass{ClassDDL}}
+	assert.ErrorContains(t, ddlOnly.Check(upMigration, statement("INSERT INTO A ?")), "DML statements are not allowed in up migrations")
+}
+
+func TestDBMigrator_MigrateQLDB_Policy(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql":   "CREATE TABLE Migration;\nCREATE TABLE A;",
+		"up/2-migration.sql":   "CREATE TABLE B;\nSELECT * FROM A;",
+		"down/1-migration.sql": "DROP TABLE A;",
+		"down/2-migration.sql": "DROP TABLE B;",
+	})
+
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}}
+
+	err := dbm.MigrateQLDB(ctx, fsys, 2)
+	assert.ErrorContains(t, err, "2-migration.sql:2")
+
+	tables, err := dbm.Driver.GetTableNames(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{lockTable}, tables, "a plan with a rejected statement runs no migration")
+
+	dbm.Policy = &MigrationPolicy{Up: []StatementClass{ClassDDL, ClassQuery}}
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 2))
+
+	// the custom policy allows nothing in down migrations
+	assert.ErrorContains(t, dbm.MigrateQLDB(ctx, fsys, 0), "DDL statements are not allowed in down migrations")
+}
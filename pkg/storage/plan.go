@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+)
+
+// MigrationPlan is what MigrateQLDB would execute to take the ledger from version From to To
+type MigrationPlan struct {
+	From      int
+	To        int
+	Direction string // up or down, empty when the ledger is already at To
+	Steps     []PlanStep
+	Drift     error // applied migrations changed on disk, see CheckDrift
+}
+
+// PlanStep is a migration file of the plan, in execution order
+type PlanStep struct {
+	Version    int // version of the file
+	Target     int // version the ledger is at after the step, the previous version for a down step
+	Direction  string
+	File       string
+	Checksum   string
+	Statements []PlannedStatement
+	Code       *GoMigration // set for a Go migration, which has no statements
+	Err        error        // the file can't be read or parsed
+}
+
+// PlannedStatement is a statement of a step with its class and the policy validation result
+type PlannedStatement struct {
+	Statement
+	Class StatementClass
+	Err   error
+}
+
+// Plan reads and validates the migration files of fsys MigrateQLDB would execute to reach version.
+// It only reads the Migration table and never writes to the ledger
+func (dbm *DBMigrator) Plan(ctx context.Context, fsys fs.FS, version int) (*MigrationPlan, error) {
+	files, err := loadMigrations(fsys, dbm.GoMigrations...)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := dbm.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return dbm.plan(migrations, files, version)
+}
+
+func (dbm *DBMigrator) plan(migrations []model.Migration, files *migrationFiles, version int) (*MigrationPlan, error) {
+	mostRecent := getMostRecentVersion(migrations)
+
+	plan := &MigrationPlan{
+		From:  mostRecent.Version,
+		To:    version,
+		Drift: checkDrift(migrations, mostRecent, files),
+	}
+
+	if mostRecent.Version == version {
+		return plan, nil
+	}
+
+	if version != 0 && !files.has(version) {
+		return nil, fmt.Errorf("%w: there is no migration for version %d, versions are %v", ErrInvalidMigration, version, files.versions)
+	}
+
+	plan.Direction = getMigrationDirection(mostRecent, version)
+	plan.Steps = dbm.planSteps(files, mostRecent.Version, version)
+
+	return plan, nil
+}
+
+// planSteps lists the steps from version from to version to: the up files of the versions in between in
+// ascending order, or the down files of the applied versions in descending order
+func (dbm *DBMigrator) planSteps(files *migrationFiles, from int, to int) []PlanStep {
+	var steps []PlanStep
+
+	if from < to {
+		for _, version := range files.versions {
+			if version > from && version <= to {
+				steps = append(steps, dbm.planStep(files, upMigration, version, version))
+			}
+		}
+
+		return steps
+	}
+
+	// from is applied even when its file is gone, its down step reports the missing file
+	versions := []int{from}
+
+	for i := len(files.versions) - 1; i >= 0; i-- {
+		if version := files.versions[i]; version > to && version < from {
+			versions = append(versions, version)
+		}
+	}
+
+	for i, version := range versions {
+		target := to
+		if i+1 < len(versions) {
+			target = versions[i+1]
+		}
+
+		steps = append(steps, dbm.planStep(files, downMigration, version, target))
+	}
+
+	return steps
+}
+
+// planStep reads a migration file and checks each of its statements against the policy
+func (dbm *DBMigrator) planStep(files *migrationFiles, migrationType string, version int, target int) PlanStep {
+	step := PlanStep{
+		Version:   version,
+		Target:    target,
+		Direction: migrationType,
+		File:      files.name(migrationType, version),
+	}
+
+	if migration, ok := files.code[version]; ok {
+		step.Code = &migration
+
+		if migrationType == downMigration && migration.Down == nil {
+			step.Err = fmt.Errorf("%w: %s has no down function", ErrInvalidMigration, migration.name())
+		}
+
+		return step
+	}
+
+	statements, err := files.read(migrationType, version)
+	if err != nil {
+		step.Err = err
+
+		// the down file of an applied version must exist, the ledger can't be taken back without it
+		if migrationType == downMigration && errors.Is(err, fs.ErrNotExist) {
+			step.Err = &MigrationDriftError{Version: version, Reason: err.Error()}
+		}
+
+		return step
+	}
+
+	policy := DefaultMigrationPolicy
+	if dbm.Policy != nil {
+		policy = *dbm.Policy
+	}
+
+	step.Checksum = checksum(statements)
+
+	for _, statement := range statements {
+		class, _ := ClassifyStatement(statement.Text)
+
+		step.Statements = append(step.Statements, PlannedStatement{
+			Statement: statement,
+			Class:     class,
+			Err:       policy.Check(migrationType, statement),
+		})
+	}
+
+	return step
+}
+
+func (s PlanStep) statements() []Statement {
+	statements := make([]Statement, 0, len(s.Statements))
+	for _, statement := range s.Statements {
+		statements = append(statements, statement.Statement)
+	}
+
+	return statements
+}
+
+// Valid returns the errors of the step: the file error or every statement rejected by the policy
+func (s PlanStep) Valid() error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	var errs []error
+
+	for _, statement := range s.Statements {
+		if statement.Err != nil {
+			errs = append(errs, statement.Err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Valid returns the drift and all the step errors that would stop MigrateQLDB
+func (p *MigrationPlan) Valid() error {
+	errs := []error{p.Drift}
+
+	for _, step := range p.Steps {
+		errs = append(errs, step.Valid())
+	}
+
+	return errors.Join(errs...)
+}
+
+// String renders the plan for review, one line per step and statement with its validation result
+func (p *MigrationPlan) String() string {
+	var b strings.Builder
+
+	if p.Direction == "" {
+		fmt.Fprintf(&b, "ledger is already at version %d\n", p.To)
+	} else {
+		fmt.Fprintf(&b, "migrate %s from version %d to %d, %d files\n", p.Direction, p.From, p.To, len(p.Steps))
+	}
+
+	if p.Drift != nil {
+		fmt.Fprintf(&b, "DRIFT: %s\n", strings.ReplaceAll(p.Drift.Error(), "\n", "\nDRIFT: "))
+	}
+
+	for _, step := range p.Steps {
+		fmt.Fprintf(&b, "\n%d-%s %s", step.Version, step.Direction, step.File)
+
+		if step.Err != nil {
+			fmt.Fprintf(&b, "\n  ERROR: %v\n", step.Err)
+			continue
+		}
+
+		if step.Code != nil {
+			fmt.Fprintf(&b, " (go)\n")
+			continue
+		}
+
+		fmt.Fprintf(&b, " (sha256 %s)\n", step.Checksum)
+
+		for _, statement := range step.Statements {
+			fmt.Fprintf(&b, "  %d: [%s] %s\n", statement.Line, statement.Class, statement.Text)
+
+			if statement.Err != nil {
+				fmt.Fprintf(&b, "     ERROR: %v\n", statement.Err)
+			}
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/storage/fake"
+	"github.com/carflores-zh/qldb-go/sql"
+)
+
+func TestDBMigrator_Plan(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql":   "CREATE TABLE Migration;\n-- contracts\nCREATE TABLE Contract;",
+		"up/2-migration.sql":   "CREATE INDEX ON Contract (id);\nSELECT * FROM Contract;",
+		"down/2-migration.sql": "DROP INDEX \"i\" ON Contract;",
+	})
+
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}}
+
+	plan, err := dbm.Plan(ctx, fsys, 2)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, plan.From)
+	assert.Equal(t, upMigration, plan.Direction)
+	require.Len(t, plan.Steps, 2)
+	assert.NoError(t, plan.Steps[0].Valid())
+	assert.Equal(t, 3, plan.Steps[0].Statements[1].Line)
+	assert.Equal(t, ClassDDL, plan.Steps[0].Statements[1].Class)
+	assert.Equal(t, ClassQuery, plan.Steps[1].Statements[1].Class)
+	assert.ErrorContains(t, plan.Valid(), "query statements are not allowed in up migrations")
+
+	text := plan.String()
+	assert.Contains(t, text, "migrate up from version 0 to 2, 2 files")
+	assert.Contains(t, text, "  3: [DDL] CREATE TABLE Contract\n")
+	assert.Contains(t, text, "ERROR: invalid statement at up/2-migration.sql:2")
+
+	tables, err := dbm.Driver.GetTableNames(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, tables, "planning doesn't touch the ledger")
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 1))
+
+	plan, err = dbm.Plan(ctx, fsys, 1)
+	require.NoError(t, err)
+	assert.Empty(t, plan.Steps)
+	assert.NoError(t, plan.Valid())
+	assert.Equal(t, "ledger is already at version 1\n", plan.String())
+
+	plan, err = dbm.Plan(ctx, fsys, 0)
+	require.NoError(t, err)
+	assert.Equal(t, downMigration, plan.Direction)
+	require.Len(t, plan.Steps, 1)
+	assert.ErrorIs(t, plan.Steps[0].Err, ErrMigrationDrift, "the down file of an applied version is missing")
+}
+
+// unreadableDriver fails every transaction, e.g. a throttled or unauthorized ledger
+type unreadableDriver struct {
+	QLDBDriver
+	err error
+}
+
+func (d unreadableDriver) Execute(context.Context, func(txn qldbdriver.Transaction) (interface{}, error)) (interface{}, error) {
+	return nil, d.err
+}
+
+func TestDBMigrator_Plan_UnreadableMigrations(t *testing.T) {
+	ctx := context.Background()
+	dbm := newFakeMigrator(t, 1)
+
+	errRead := errors.New("rate exceeded")
+	dbm.Driver = unreadableDriver{QLDBDriver: dbm.Driver, err: errRead}
+
+	_, err := dbm.Plan(ctx, sql.FS, 2)
+	assert.ErrorIs(t, err, errRead, "a ledger whose migrations can't be read is not planned from version 0")
+
+	_, err = dbm.Status(ctx, sql.FS)
+	assert.ErrorIs(t, err, errRead)
+}
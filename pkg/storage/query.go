@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/amzn/ion-go/ion"
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
+)
+
+var errMissingDocumentID = errors.New("statement did not return a documentId")
+
+// Execute runs fn inside a ledger transaction bound to ctx and returns its typed result.
+// The driver may retry fn, only the result of the committed attempt is returned.
+// When ctx is done the returned error always wraps ctx.Err(), so callers can match
+// context.DeadlineExceeded or context.Canceled regardless of what the driver returned.
+// Ledger errors are mapped to the storage sentinel errors (ErrOCCConflict, ErrInvalidStatement...)
+func Execute[T any](ctx context.Context, driver QLDBDriver, fn func(txn qldbdriver.Transaction) (T, error)) (T, error) {
+	var committed T
+
+	if err := ctx.Err(); err != nil {
+		return committed, err
+	}
+
+	_, err := driver.Execute(ctx, func(txn qldbdriver.Transaction) (interface{}, error) {
+		attempt, err := fn(txn)
+		if err != nil {
+			return nil, err
+		}
+
+		committed = attempt
+
+		return nil, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, translateError(contextError(ctx, err))
+	}
+
+	return committed, nil
+}
+
+// QueryMany executes statement and unmarshals every returned document into a T
+func QueryMany[T any](ctx context.Context, txn qldbdriver.Transaction, statement string, parameters ...interface{}) ([]T, error) {
+	result, err := txn.Execute(statement, parameters...)
+	if err != nil {
+		return nil, err
+	}
+
+	var documents []T
+	for result.Next(txn) {
+		if errCtx := ctx.Err(); errCtx != nil {
+			return nil, errCtx
+		}
+
+		var document T
+		if err = ion.Unmarshal(result.GetCurrentData(), &document); err != nil {
+			return nil, err
+		}
+
+		documents = append(documents, document)
+	}
+	if result.Err() != nil {
+		return nil, result.Err()
+	}
+
+	return documents, nil
+}
+
+// QueryRaw executes statement and returns every document as the Ion binary received from the ledger
+func QueryRaw(ctx context.Context, txn qldbdriver.Transaction, statement string, parameters ...interface{}) ([][]byte, error) {
+	result, err := txn.Execute(statement, parameters...)
+	if err != nil {
+		return nil, err
+	}
+
+	var documents [][]byte
+	for result.Next(txn) {
+		if errCtx := ctx.Err(); errCtx != nil {
+			return nil, errCtx
+		}
+
+		documents = append(documents, result.GetCurrentData())
+	}
+	if result.Err() != nil {
+		return nil, result.Err()
+	}
+
+	return documents, nil
+}
+
+// QueryOne executes statement and unmarshals the first returned document into a T,
+// found is false when the statement returned no documents
+func QueryOne[T any](ctx context.Context, txn qldbdriver.Transaction, statement string, parameters ...interface{}) (document T, found bool, err error) {
+	result, err := txn.Execute(statement, parameters...)
+	if err != nil {
+		return document, false, err
+	}
+
+	if !result.Next(txn) {
+		return document, false, result.Err()
+	}
+
+	if err = ctx.Err(); err != nil {
+		return document, false, err
+	}
+
+	if err = ion.Unmarshal(result.GetCurrentData(), &document); err != nil {
+		return document, false, err
+	}
+
+	return document, true, nil
+}
+
+// ExecReturningID executes an INSERT (or any statement returning a documentId) and returns the document ID
+func ExecReturningID(ctx context.Context, txn qldbdriver.Transaction, statement string, parameters ...interface{}) (string, error) {
+	result, found, err := QueryOne[metadata.Result](ctx, txn, statement, parameters...)
+	if err != nil {
+		return "", err
+	}
+
+	if !found || result.DocumentID == "" {
+		return "", errMissingDocumentID
+	}
+
+	return result.DocumentID, nil
+}
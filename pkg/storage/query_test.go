@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/amzn/ion-go/ion"
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
+	"github.com/carflores-zh/qldb-go/pkg/storage/mocks"
+)
+
+func TestQueryMany(t *testing.T) {
+	first, _ := ion.MarshalBinary(model.Contract{ID: "c1", Network: "ethereum"})
+	second, _ := ion.MarshalBinary(model.Contract{ID: "c2", Network: "polygon"})
+
+	mDriver := mocks.NewMockQLDBDriver()
+
+	result := &mocks.MockResult{}
+	result.On("Next", mock.Anything).Return(true).Twice()
+	result.On("Next", mock.Anything).Return(false).Once()
+	result.On("GetCurrentData").Return(first).Once()
+	result.On("GetCurrentData").Return(second).Once()
+	result.On("Err").Return(nil)
+
+	mDriver.Txn.On("Execute", "SELECT * FROM Contract", []interface{}(nil)).Return(result, nil).Once()
+
+	ctx := context.Background()
+
+	contracts, err := Execute(ctx, mDriver, func(txn qldbdriver.Transaction) ([]model.Contract, error) {
+		return QueryMany[model.Contract](ctx, txn, "SELECT * FROM Contract")
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Contract{{ID: "c1", Network: "ethereum"}, {ID: "c2", Network: "polygon"}}, contracts)
+}
+
+func TestQueryOne(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("not-found", func(t *testing.T) {
+		mDriver := mocks.NewMockQLDBDriver()
+
+		result := &mocks.MockResult{}
+		result.On("Next", mock.Anything).Return(false)
+		result.On("Err").Return(nil)
+
+		mDriver.Txn.On("Execute", mock.Anything, mock.Anything).Return(result, nil)
+
+		_, err := Execute(ctx, mDriver, func(txn qldbdriver.Transaction) (model.Contract, error) {
+			contract, found, err := QueryOne[model.Contract](ctx, txn, "SELECT * FROM Contract AS c WHERE c.id = ?", "c1")
+			assert.False(t, found)
+
+			return contract, err
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("missing-document-id", func(t *testing.T) {
+		mDriver := mocks.NewMockQLDBDriver()
+		empty, _ := ion.MarshalBinary(metadata.Result{})
+
+		result := &mocks.MockResult{}
+		result.On("Next", mock.Anything).Return(true)
+		result.On("GetCurrentData").Return(empty)
+
+		mDriver.Txn.On("Execute", mock.Anything, mock.Anything).Return(result, nil)
+
+		id, err := Execute(ctx, mDriver, func(txn qldbdriver.Transaction) (string, error) {
+			return ExecReturningID(ctx, txn, "INSERT INTO Contract ?", model.Contract{})
+		})
+		assert.ErrorIs(t, err, errMissingDocumentID)
+		assert.Empty(t, id)
+	})
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+	"github.com/rs/zerolog/log"
+
+	"github.com/carflores-zh/qldb-go/pkg/internal/partiql"
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
+)
+
+const (
+	tableActive         = "ACTIVE"
+	tableInactive       = "INACTIVE"
+	indexOnline         = "ONLINE"
+	indexFailed         = "FAILED"
+	defaultReadyTimeout = 5 * time.Minute
+	readyPollMin        = 100 * time.Millisecond
+	readyPollMax        = 5 * time.Second
+)
+
+// ReadyCondition reports what a table or index is still waiting for, pending is empty once it is ready.
+// An error stops the wait, e.g. an index whose build failed
+type ReadyCondition func(tables []metadata.UserTable) (pending string, err error)
+
+// TableActive is ready when the table exists and is ACTIVE
+func TableActive(name string) ReadyCondition {
+	return func(tables []metadata.UserTable) (string, error) {
+		if findActiveTable(tables, name) == nil {
+			return fmt.Sprintf("table %s is not active", name), nil
+		}
+
+		return "", nil
+	}
+}
+
+// TableIDActive is ready when the table with the ID is ACTIVE, e.g. after UNDROP TABLE
+func TableIDActive(tableID string) ReadyCondition {
+	return func(tables []metadata.UserTable) (string, error) {
+		for _, table := range tables {
+			if table.TableID == tableID && table.Status == tableActive {
+				return "", nil
+			}
+		}
+
+		return fmt.Sprintf("table %s is not active", tableID), nil
+	}
+}
+
+// TableDropped is ready when no ACTIVE table has the name
+func TableDropped(name string) ReadyCondition {
+	return func(tables []metadata.UserTable) (string, error) {
+		if findActiveTable(tables, name) != nil {
+			return fmt.Sprintf("table %s is still active", name), nil
+		}
+
+		return "", nil
+	}
+}
+
+// IndexOnline is ready when the index of the table on path, e.g. id or address.network, is ONLINE
+func IndexOnline(tableName string, path string) ReadyCondition {
+	return func(tables []metadata.UserTable) (string, error) {
+		table := findActiveTable(tables, tableName)
+		if table == nil {
+			return fmt.Sprintf("table %s is not active", tableName), nil
+		}
+
+		for _, index := range table.Indexes {
+			if indexPath(index.Expr) != indexPath(path) {
+				continue
+			}
+
+			switch index.Status {
+			case indexOnline:
+				return "", nil
+			case indexFailed:
+				return "", fmt.Errorf("index %s on %s failed: %s", index.Expr, tableName, index.Message)
+			default:
+				return fmt.Sprintf("index %s on %s is %s", index.Expr, tableName, index.Status), nil
+			}
+		}
+
+		return fmt.Sprintf("index [%s] on %s doesn't exist", path, tableName), nil
+	}
+}
+
+// IndexDropped is ready when the table no longer has the index with the ID
+func IndexDropped(tableName string, indexID string) ReadyCondition {
+	return func(tables []metadata.UserTable) (string, error) {
+		if table := findActiveTable(tables, tableName); table != nil {
+			for _, index := range table.Indexes {
+				if index.IndexID == indexID {
+					return fmt.Sprintf("index %s on %s is %s", indexID, tableName, index.Status), nil
+				}
+			}
+		}
+
+		return "", nil
+	}
+}
+
+func findActiveTable(tables []metadata.UserTable, name string) *metadata.UserTable {
+	for i := range tables {
+		if tables[i].Name == name && tables[i].Status == tableActive {
+			return &tables[i]
+		}
+	}
+
+	return nil
+}
+
+// indexPath normalizes an indexed path for comparison, [address.network] and address.network are the same
+func indexPath(expr string) string {
+	return strings.Join(strings.Fields(strings.Trim(strings.TrimSpace(expr), "[]")), "")
+}
+
+// UserTables lists the active and dropped tables of the ledger with their indexes
+func (db *DB) UserTables(ctx context.Context) ([]metadata.UserTable, error) {
+	return Execute(ctx, db.Driver, func(txn qldbdriver.Transaction) ([]metadata.UserTable, error) {
+		return QueryMany[metadata.UserTable](ctx, txn, "SELECT * FROM information_schema.user_tables")
+	})
+}
+
+// WaitForTables polls information_schema.user_tables, backing off from 100ms to 5s, until all the
+// conditions are ready. It gives up after timeout (0 for 5 minutes) with a *NotReadyError listing what is pending
+func (db *DB) WaitForTables(ctx context.Context, timeout time.Duration, conditions ...ReadyCondition) error {
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	if timeout <= 0 {
+		timeout = defaultReadyTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var pending []string
+
+	for wait := readyPollMin; ; wait = minDuration(2*wait, readyPollMax) {
+		tables, err := db.UserTables(ctx)
+		if err != nil {
+			if ctx.Err() != nil && pending != nil {
+				return &NotReadyError{Pending: pending, Err: ctx.Err()}
+			}
+
+			return err
+		}
+
+		pending = pending[:0]
+
+		for _, condition := range conditions {
+			what, errCondition := condition(tables)
+			if errCondition != nil {
+				return errCondition
+			}
+
+			if what != "" {
+				pending = append(pending, what)
+			}
+		}
+
+		if len(pending) == 0 {
+			return nil
+		}
+
+		log.Info().Strs("pending", pending).Dur("wait", wait).Msg("waiting for tables")
+
+		if err = sleep(ctx, wait); err != nil {
+			return &NotReadyError{Pending: pending, Err: err}
+		}
+	}
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// readyConditions lists what the DDL statements of a migration wait for, nil when there is no DDL
+func readyConditions(statements []Statement) []ReadyCondition {
+	var conditions []ReadyCondition
+
+	for _, statement := range statements {
+		tokens, err := partiql.Lex(statement.Text)
+		if err != nil || len(tokens) < 3 {
+			continue
+		}
+
+		switch {
+		case tokens[0].Keyword("CREATE") && tokens[1].Keyword("TABLE"):
+			conditions = append(conditions, TableActive(tokens[2].Text))
+		case tokens[0].Keyword("DROP") && tokens[1].Keyword("TABLE"):
+			conditions = append(conditions, TableDropped(tokens[2].Text))
+		case tokens[0].Keyword("UNDROP") && tokens[1].Keyword("TABLE"):
+			conditions = append(conditions, TableIDActive(tokens[2].Text))
+		case tokens[0].Keyword("CREATE") && tokens[1].Keyword("INDEX") && tokens[2].Keyword("ON") && len(tokens) > 4:
+			conditions = append(conditions, IndexOnline(tokens[3].Text, joinTokens(tokens[4:])))
+		case tokens[0].Keyword("DROP") && tokens[1].Keyword("INDEX") && len(tokens) > 4 && tokens[3].Keyword("ON"):
+			conditions = append(conditions, IndexDropped(tokens[4].Text, tokens[2].Text))
+		}
+	}
+
+	return conditions
+}
+
+// joinTokens joins the tokens of a parenthesized path, (address.network) is address.network
+func joinTokens(tokens []partiql.Token) string {
+	var b strings.Builder
+
+	for _, t := range tokens {
+		if t.Text == "(" {
+			continue
+		}
+
+		if t.Text == ")" {
+			break
+		}
+
+		b.WriteString(t.Text)
+	}
+
+	return b.String()
+}
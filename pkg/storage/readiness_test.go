@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
+	"github.com/carflores-zh/qldb-go/pkg/storage/fake"
+)
+
+func TestReadyConditions(t *testing.T) {
+	tables := []metadata.UserTable{
+		{Name: "Contract", TableID: "t1", Status: tableActive, Indexes: []metadata.UserTableIndex{
+			{IndexID: "i1", Expr: "[id]", Status: indexOnline},
+			{IndexID: "i2", Expr: "[address.network]", Status: "BUILDING"},
+			{IndexID: "i3", Expr: "[owner]", Status: indexFailed, Message: "duplicate values"},
+		}},
+		{Name: "Share", TableID: "t2", Status: "INACTIVE"},
+	}
+
+	tests := []struct {
+		statement string
+		pending   string
+		err       string
+	}{
+		{statement: "CREATE TABLE Contract"},
+		{statement: "CREATE TABLE Image", pending: "table Image is not active"},
+		{statement: "CREATE INDEX ON Contract (id)"},
+		{statement: "create index on Contract (address . network)", pending: "index [address.network] on Contract is BUILDING"},
+		{statement: "CREATE INDEX ON Contract (owner)", err: "index [owner] on Contract failed: duplicate values"},
+		{statement: "CREATE INDEX ON Contract (note)", pending: "index [note] on Contract doesn't exist"},
+		{statement: "DROP TABLE Share"},
+		{statement: "DROP TABLE Contract", pending: "table Contract is still active"},
+		{statement: "UNDROP TABLE \"t2\"", pending: "table t2 is not active"},
+		{statement: "DROP INDEX \"i1\" ON Contract", pending: "index i1 on Contract is ONLINE"},
+		{statement: "DROP INDEX \"i9\" ON Contract"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.statement, func(t *testing.T) {
+			conditions := readyConditions([]Statement{{Text: tt.statement}})
+			require.Len(t, conditions, 1)
+
+			pending, err := conditions[0](tables)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.pending, pending)
+		})
+	}
+
+	assert.Empty(t, readyConditions([]Statement{{Text: "INSERT INTO Contract ?"}, {Text: "UPDATE Contract SET a = 1"}}))
+}
+
+func TestDB_WaitForTables(t *testing.T) {
+	ctx := context.Background()
+	db := &DB{Driver: fake.NewDriver(fake.WithIndexBuildTime(300 * time.Millisecond))}
+
+	_, err := Execute(ctx, db.Driver, func(txn qldbdriver.Transaction) (interface{}, error) {
+		if _, err := txn.Execute("CREATE TABLE Contract"); err != nil {
+			return nil, err
+		}
+
+		return txn.Execute("CREATE INDEX ON Contract (id)")
+	})
+	require.NoError(t, err)
+
+	err = db.WaitForTables(ctx, 150*time.Millisecond, TableActive("Contract"), IndexOnline("Contract", "id"))
+	assert.ErrorIs(t, err, ErrNotReady)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "index [id] on Contract is BUILDING")
+
+	start := time.Now()
+	require.NoError(t, db.WaitForTables(ctx, time.Second, TableActive("Contract"), IndexOnline("Contract", "id")))
+	assert.Less(t, time.Since(start), time.Second)
+
+	require.NoError(t, db.WaitForTables(ctx, time.Millisecond), "nothing to wait for")
+}
+
+func TestDBMigrator_MigrateQLDB_WaitsForIndexes(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql": "CREATE TABLE Migration;\nCREATE TABLE Contract;\nCREATE INDEX ON Contract (id);",
+	})
+
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver(fake.WithIndexBuildTime(200 * time.Millisecond))}}
+
+	start := time.Now()
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 1))
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	tables, err := dbm.UserTables(ctx)
+	require.NoError(t, err)
+
+	for _, table := range tables {
+		for _, index := range table.Indexes {
+			assert.Equal(t, indexOnline, index.Status, "the migration returns once its indexes are online")
+		}
+	}
+
+	migrations, err := dbm.GetMigrations(ctx)
+	require.NoError(t, err)
+	require.Len(t, migrations, 1)
+}
+
+func TestDBMigrator_MigrateQLDB_NotReady(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql": "CREATE TABLE Migration;\nCREATE TABLE Contract;\nCREATE INDEX ON Contract (id);",
+		"up/2-migration.sql": "CREATE TABLE Image;",
+	})
+
+	ctx := context.Background()
+	dbm := &DBMigrator{
+		DB:           &DB{Driver: fake.NewDriver(fake.WithIndexBuildTime(300 * time.Millisecond))},
+		ReadyTimeout: 100 * time.Millisecond,
+	}
+
+	err := dbm.MigrateQLDB(ctx, fsys, 2)
+	assert.ErrorIs(t, err, ErrNotReady)
+	assert.NotErrorIs(t, err, ErrMigrationFailed)
+	assert.ErrorContains(t, err, "up/1-migration.sql up applied, the ledger is at version 1")
+
+	migrations, err := dbm.GetMigrations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, getMostRecentVersion(migrations).Version, "the step is recorded and the run stops")
+
+	tables, err := dbm.Driver.GetTableNames(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, tables, "Image")
+
+	dbm.ReadyTimeout = time.Second
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 2), "migrating again resumes from version 1")
+}
@@ -0,0 +1,323 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/carflores-zh/qldb-go/pkg/internal/partiql"
+	"github.com/carflores-zh/qldb-go/pkg/model"
+)
+
+// Schema is the tables of a ledger with the sorted paths of their indexes
+type Schema map[string][]string
+
+// DeclaredSchema reads the tables and the fields tagged qldb:"index" of tables, e.g. model.Tables
+func DeclaredSchema(tables []model.Table) (Schema, error) {
+	schema := Schema{}
+
+	for _, table := range tables {
+		if _, ok := schema[table.Name]; ok {
+			return nil, fmt.Errorf("table %s is declared twice", table.Name)
+		}
+
+		schema[table.Name] = []string{}
+
+		if table.Document == nil {
+			continue
+		}
+
+		documentType := reflect.TypeOf(table.Document)
+		if documentType.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("table %s: document %s is not a struct", table.Name, documentType)
+		}
+
+		for i := 0; i < documentType.NumField(); i++ {
+			field := documentType.Field(i)
+
+			tag, ok := field.Tag.Lookup("qldb")
+			if !ok {
+				continue
+			}
+
+			if tag != "index" {
+				return nil, fmt.Errorf("table %s: field %s has an unknown qldb tag %q", table.Name, field.Name, tag)
+			}
+
+			path := field.Name
+			if name, _, _ := strings.Cut(field.Tag.Get("ion"), ","); name != "" {
+				path = name
+			}
+
+			if path == "-" {
+				return nil, fmt.Errorf("table %s: field %s is indexed but not stored", table.Name, field.Name)
+			}
+
+			schema[table.Name] = append(schema[table.Name], path)
+		}
+
+		sort.Strings(schema[table.Name])
+	}
+
+	return schema, nil
+}
+
+// MigratedSchema replays the up migrations of fsys and returns the schema they create. Go migrations only
+// rewrite documents and are skipped. DROP INDEX "id" and UNDROP TABLE "id" name an index or a table of a given
+// ledger and are not replayed, files use DROP INDEX ON T(path) and UNDROP TABLE T, see resolveStatement
+func MigratedSchema(fsys fs.FS) (Schema, error) {
+	files, err := loadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return migratedSchema(files, math.MaxInt)
+}
+
+// migratedSchema replays the up migrations up to version
+func migratedSchema(files *migrationFiles, upTo int) (Schema, error) {
+	schema := Schema{}
+	dropped := Schema{}
+
+	for _, version := range files.versions {
+		if _, isCode := files.code[version]; isCode || version > upTo {
+			continue
+		}
+
+		statements, err := files.read(upMigration, version)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, statement := range statements {
+			tokens, err := partiql.Lex(statement.Text)
+			if err != nil || len(tokens) < 3 {
+				continue
+			}
+
+			if table, path, ok := indexDropByPath(statement.Text); ok {
+				schema[table] = remove(schema[table], path)
+				continue
+			}
+
+			switch {
+			case tokens[0].Keyword("CREATE") && tokens[1].Keyword("TABLE"):
+				schema[tokens[2].Text] = []string{}
+			case tokens[0].Keyword("DROP") && tokens[1].Keyword("TABLE"):
+				dropped[tokens[2].Text] = schema[tokens[2].Text]
+				delete(schema, tokens[2].Text)
+			case tokens[0].Keyword("UNDROP") && tokens[1].Keyword("TABLE"):
+				if table, byName, _ := undropTable(statement.Text); byName {
+					schema[table] = dropped[table]
+					continue
+				}
+
+				log.Warn().Str("statement", statement.Text).Str("position", statement.Position()).
+					Msg("the table undropped by id is not restored in the migrated schema")
+			case tokens[0].Keyword("CREATE") && tokens[1].Keyword("INDEX") && tokens[2].Keyword("ON") && len(tokens) > 4:
+				table := tokens[3].Text
+				schema[table] = append(schema[table], joinTokens(tokens[4:]))
+				sort.Strings(schema[table])
+			case tokens[0].Keyword("DROP") && tokens[1].Keyword("INDEX"):
+				log.Warn().Str("statement", statement.Text).Str("position", statement.Position()).
+					Msg("the index dropped by id is kept in the migrated schema")
+			}
+		}
+	}
+
+	return schema, nil
+}
+
+// SchemaDiff is the migration that takes a ledger from a schema to another
+type SchemaDiff struct {
+	Up   []string // statements of the up file
+	Down []string // statements of the down file, undoing Up in reverse order
+}
+
+// DiffSchema returns the statements that take the ledger from schema from to schema to. Tables missing from
+// to are dropped, which QLDB can undo with UNDROP TABLE, so the down migration undrops them by name. Indexes
+// are dropped by path, see resolveStatement. Reserved words and other names that aren't plain identifiers are
+// quoted, e.g. CREATE INDEX ON TransactionLog("to")
+func DiffSchema(from Schema, to Schema) SchemaDiff {
+	var diff SchemaDiff
+
+	step := func(up string, down string) {
+		diff.Up = append(diff.Up, up)
+		if down != "" {
+			diff.Down = append([]string{down}, diff.Down...)
+		}
+	}
+
+	createIndex := func(table, path string) string {
+		return fmt.Sprintf("CREATE INDEX ON %s(%s)", partiql.QuoteIdentifier(table), quotePath(path))
+	}
+
+	dropIndex := func(table, path string) string {
+		return fmt.Sprintf("DROP INDEX ON %s(%s)", partiql.QuoteIdentifier(table), quotePath(path))
+	}
+
+	for _, table := range sortedTables(to) {
+		indexes, exists := from[table]
+		if !exists {
+			step("CREATE TABLE "+partiql.QuoteIdentifier(table), "DROP TABLE "+partiql.QuoteIdentifier(table))
+		}
+
+		for _, path := range indexes {
+			if !contains(to[table], path) {
+				step(dropIndex(table, path), createIndex(table, path))
+			}
+		}
+
+		for _, path := range to[table] {
+			if contains(indexes, path) {
+				continue
+			}
+
+			// dropping a new table drops its indexes
+			down := ""
+			if exists {
+				down = dropIndex(table, path)
+			}
+
+			step(createIndex(table, path), down)
+		}
+	}
+
+	for _, table := range sortedTables(from) {
+		if _, ok := to[table]; !ok {
+			// a quoted name would be read as a table id, Validate rejects the tables that can't be undropped by name
+			step("DROP TABLE "+partiql.QuoteIdentifier(table), "UNDROP TABLE "+partiql.QuoteIdentifier(table))
+		}
+	}
+
+	return diff
+}
+
+// quotePath quotes the fields of an index path that aren't plain identifiers, e.g. address."to"
+func quotePath(path string) string {
+	fields := strings.Split(path, ".")
+	for i, field := range fields {
+		fields[i] = partiql.QuoteIdentifier(field)
+	}
+
+	return strings.Join(fields, ".")
+}
+
+// Empty tells if there is nothing to migrate
+func (d SchemaDiff) Empty() bool {
+	return len(d.Up) == 0 && len(d.Down) == 0
+}
+
+// UpFile renders the up migration file
+func (d SchemaDiff) UpFile() string {
+	return migrationFile(d.Up)
+}
+
+// DownFile renders the down migration file
+func (d SchemaDiff) DownFile() string {
+	return migrationFile(d.Down)
+}
+
+// Validate parses the up and down files of the migration file, e.g. 3-indexes.sql, and checks their
+// statements against DefaultMigrationPolicy, the migrator would refuse to run them otherwise. A table whose
+// name must be quoted can't be undropped by name, its down migration is rejected
+func (d SchemaDiff) Validate(file string) error {
+	var errs []error
+
+	for migrationType, content := range map[string]string{upMigration: d.UpFile(), downMigration: d.DownFile()} {
+		statements, err := ParseMigration(migrationType+"/"+file, strings.NewReader(content))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, statement := range statements {
+			if err = DefaultMigrationPolicy.Check(migrationType, statement); err != nil {
+				errs = append(errs, err)
+			}
+
+			if _, byName, ok := undropTable(statement.Text); ok && !byName {
+				errs = append(errs, &StatementError{Statement: statement.Text, Position: statement.Position(),
+					Reason: "the table can't be undropped by name, a quoted name is read as a table id"})
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func migrationFile(statements []string) string {
+	var b strings.Builder
+
+	for _, statement := range statements {
+		fmt.Fprintf(&b, "%s;\n", statement)
+	}
+
+	return b.String()
+}
+
+// GenerateMigration diffs the schema of the migration files of fsys with the declared tables and returns
+// the version of the next migration with its statements
+func GenerateMigration(fsys fs.FS, tables []model.Table) (int, SchemaDiff, error) {
+	declared, err := DeclaredSchema(tables)
+	if err != nil {
+		return 0, SchemaDiff{}, err
+	}
+
+	files, err := loadMigrations(fsys)
+	if err != nil {
+		return 0, SchemaDiff{}, err
+	}
+
+	migrated, err := migratedSchema(files, math.MaxInt)
+	if err != nil {
+		return 0, SchemaDiff{}, err
+	}
+
+	version := 1
+	if len(files.versions) > 0 {
+		version = files.versions[len(files.versions)-1] + 1
+	}
+
+	return version, DiffSchema(migrated, declared), nil
+}
+
+func sortedTables(schema Schema) []string {
+	tables := make([]string, 0, len(schema))
+	for table := range schema {
+		tables = append(tables, table)
+	}
+
+	sort.Strings(tables)
+
+	return tables
+}
+
+// remove returns values without value, values is not modified
+func remove(values []string, value string) []string {
+	kept := make([]string, 0, len(values))
+
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+
+	return kept
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
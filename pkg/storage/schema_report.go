@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
+)
+
+// SchemaIssueKind is a difference between the ledger and an expected schema
+type SchemaIssueKind string
+
+const (
+	IssueMissingTable   SchemaIssueKind = "missing table"
+	IssueDroppedTable   SchemaIssueKind = "dropped table" // the table exists but is INACTIVE
+	IssueExtraTable     SchemaIssueKind = "extra table"
+	IssueMissingIndex   SchemaIssueKind = "missing index" // queries on the path scan the whole table
+	IssueExtraIndex     SchemaIssueKind = "extra index"
+	IssueIndexCase      SchemaIssueKind = "index case mismatch" // QLDB paths are case sensitive, the index is never used
+	IssueIndexNotOnline SchemaIssueKind = "index not online"
+)
+
+// SchemaIssue is a table or index of the ledger that doesn't match the expected schema
+type SchemaIssue struct {
+	Kind   SchemaIssueKind
+	Table  string
+	Path   string // indexed path, empty for table issues
+	Detail string
+}
+
+// SchemaReport lists the issues of the ledger against an expected schema, Against names it, e.g. model
+type SchemaReport struct {
+	Against string
+	Issues  []SchemaIssue
+}
+
+// CompareSchema reads information_schema.user_tables and compares the tables and indexes of the ledger with
+// expected. The migration lock table is created by the migrator and never reported
+func (db *DB) CompareSchema(ctx context.Context, against string, expected Schema) (*SchemaReport, error) {
+	tables, err := db.UserTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return compareSchema(tables, against, expected), nil
+}
+
+// CompareDeclaredSchema compares the ledger with the tables declared in pkg/model, see model.Tables
+func (db *DB) CompareDeclaredSchema(ctx context.Context) (*SchemaReport, error) {
+	declared, err := DeclaredSchema(model.Tables)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.CompareSchema(ctx, "model", declared)
+}
+
+// CompareAppliedSchema compares the ledger with the schema created by the migrations of fsys up to the
+// version recorded in the Migration table, a ledger never migrated is compared with an empty schema
+func (dbm *DBMigrator) CompareAppliedSchema(ctx context.Context, fsys fs.FS) (*SchemaReport, error) {
+	files, err := loadMigrations(fsys, dbm.GoMigrations...)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := dbm.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version := getMostRecentVersion(migrations).Version
+
+	applied, err := migratedSchema(files, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return dbm.CompareSchema(ctx, fmt.Sprintf("migrations up to version %d", version), applied)
+}
+
+func compareSchema(tables []metadata.UserTable, against string, expected Schema) *SchemaReport {
+	report := &SchemaReport{Against: against}
+
+	live := map[string]*metadata.UserTable{}
+	dropped := map[string]bool{}
+
+	for i := range tables {
+		switch {
+		case tables[i].Name == lockTable:
+		case tables[i].Status == tableActive:
+			live[tables[i].Name] = &tables[i]
+		default:
+			dropped[tables[i].Name] = true
+		}
+	}
+
+	for _, name := range sortedTables(expected) {
+		table, ok := live[name]
+		if !ok {
+			kind := IssueMissingTable
+			if dropped[name] {
+				kind = IssueDroppedTable
+			}
+
+			report.Issues = append(report.Issues, SchemaIssue{Kind: kind, Table: name})
+
+			continue
+		}
+
+		report.Issues = append(report.Issues, compareIndexes(table, expected[name])...)
+	}
+
+	var extra []string
+
+	for name := range live {
+		if _, ok := expected[name]; !ok {
+			extra = append(extra, name)
+		}
+	}
+
+	sort.Strings(extra)
+
+	for _, name := range extra {
+		report.Issues = append(report.Issues, SchemaIssue{Kind: IssueExtraTable, Table: name})
+	}
+
+	return report
+}
+
+// compareIndexes matches the indexes of an active table with the expected paths, an index whose path only
+// differs in case is reported as a mismatch rather than a missing and an extra index
+func compareIndexes(table *metadata.UserTable, paths []string) []SchemaIssue {
+	var issues []SchemaIssue
+
+	matched := make([]bool, len(table.Indexes))
+
+	for _, path := range paths {
+		found := -1
+
+		for i, index := range table.Indexes {
+			if !matched[i] && indexPath(index.Expr) == indexPath(path) {
+				found = i
+				break
+			}
+		}
+
+		if found < 0 {
+			for i, index := range table.Indexes {
+				if !matched[i] && strings.EqualFold(indexPath(index.Expr), indexPath(path)) {
+					matched[i] = true
+					issues = append(issues, SchemaIssue{Kind: IssueIndexCase, Table: table.Name, Path: path,
+						Detail: fmt.Sprintf("index %s %s", index.IndexID, index.Expr)})
+					found = i
+
+					break
+				}
+			}
+
+			if found < 0 {
+				issues = append(issues, SchemaIssue{Kind: IssueMissingIndex, Table: table.Name, Path: path})
+			}
+
+			continue
+		}
+
+		matched[found] = true
+
+		if index := table.Indexes[found]; index.Status != indexOnline {
+			detail := fmt.Sprintf("index %s is %s", index.IndexID, index.Status)
+			if index.Message != "" {
+				detail += ": " + index.Message
+			}
+
+			issues = append(issues, SchemaIssue{Kind: IssueIndexNotOnline, Table: table.Name, Path: path, Detail: detail})
+		}
+	}
+
+	for i, index := range table.Indexes {
+		if !matched[i] {
+			issues = append(issues, SchemaIssue{Kind: IssueExtraIndex, Table: table.Name, Path: indexPath(index.Expr),
+				Detail: fmt.Sprintf("index %s", index.IndexID)})
+		}
+	}
+
+	return issues
+}
+
+// String renders the report as a table
+func (r *SchemaReport) String() string {
+	var b strings.Builder
+
+	if len(r.Issues) == 0 {
+		fmt.Fprintf(&b, "the ledger matches the %s schema\n", r.Against)
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "%d differences with the %s schema\n\n", len(r.Issues), r.Against)
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ISSUE\tTABLE\tPATH\tDETAIL")
+
+	for _, issue := range r.Issues {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", issue.Kind, issue.Table, issue.Path, issue.Detail)
+	}
+
+	_ = w.Flush()
+
+	return b.String()
+}
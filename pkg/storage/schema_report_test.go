@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
+	"github.com/carflores-zh/qldb-go/pkg/storage/fake"
+	"github.com/carflores-zh/qldb-go/sql"
+)
+
+func TestCompareSchema(t *testing.T) {
+	tables := []metadata.UserTable{
+		{Name: "Contract", Status: tableActive, Indexes: []metadata.UserTableIndex{
+			{IndexID: "i1", Expr: "[Hash]", Status: indexOnline},
+			{IndexID: "i2", Expr: "[ID]", Status: indexOnline},
+			{IndexID: "i3", Expr: "[address]", Status: "BUILDING"},
+		}},
+		{Name: "Share", Status: "INACTIVE"},
+		{Name: "Legacy", Status: tableActive},
+		{Name: "Image", Status: "INACTIVE"},
+		{Name: "Image", Status: tableActive, Indexes: []metadata.UserTableIndex{{IndexID: "i4", Expr: "[imageId]", Status: indexOnline}}},
+		{Name: lockTable, Status: tableActive},
+	}
+
+	report := compareSchema(tables, "model", Schema{
+		"Contract": {"address", "id"},
+		"Image":    {"imageId"},
+		"Share":    {},
+		"Signer":   {"publicAddress"},
+	})
+
+	assert.Equal(t, []SchemaIssue{
+		{Kind: IssueIndexNotOnline, Table: "Contract", Path: "address", Detail: "index i3 is BUILDING"},
+		{Kind: IssueIndexCase, Table: "Contract", Path: "id", Detail: "index i2 [ID]"},
+		{Kind: IssueExtraIndex, Table: "Contract", Path: "Hash", Detail: "index i1"},
+		{Kind: IssueDroppedTable, Table: "Share"},
+		{Kind: IssueMissingTable, Table: "Signer"},
+		{Kind: IssueExtraTable, Table: "Legacy"},
+	}, report.Issues)
+
+	text := report.String()
+	assert.Contains(t, text, "6 differences with the model schema")
+	assert.Regexp(t, `index case mismatch\s+Contract\s+id\s+index i2 \[ID\]`, text)
+
+	assert.Equal(t, "the ledger matches the migrations schema\n",
+		compareSchema(tables[4:], "migrations", Schema{"Image": {"imageId"}}).String())
+}
+
+func TestDB_CompareSchema_NeverMigrated(t *testing.T) {
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}}
+
+	applied, err := dbm.CompareAppliedSchema(ctx, sql.FS)
+	require.NoError(t, err)
+	assert.Equal(t, "migrations up to version 0", applied.Against)
+	assert.Empty(t, applied.Issues, "an empty ledger matches no migration")
+
+	declared, err := dbm.CompareDeclaredSchema(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, declared.Issues, SchemaIssue{Kind: IssueMissingTable, Table: "Contract"})
+}
+
+func TestDB_CompareSchema_Fake(t *testing.T) {
+	ctx := context.Background()
+	dbm := newFakeMigrator(t, 3)
+
+	applied, err := dbm.CompareAppliedSchema(ctx, sql.FS)
+	require.NoError(t, err)
+	assert.Equal(t, "migrations up to version 3", applied.Against)
+	assert.Empty(t, applied.Issues)
+
+	declared, err := dbm.CompareDeclaredSchema(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, declared.Issues, "3-model-indexes.sql creates the declared indexes and drops the others")
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, sql.FS, 2))
+
+	applied, err = dbm.CompareAppliedSchema(ctx, sql.FS)
+	require.NoError(t, err)
+	assert.Empty(t, applied.Issues)
+
+	declared, err = dbm.CompareDeclaredSchema(ctx)
+	require.NoError(t, err)
+
+	issues := map[string]SchemaIssueKind{}
+	for _, issue := range declared.Issues {
+		issues[issue.Table+"("+issue.Path+")"] = issue.Kind
+	}
+
+	// the indexes of 1-migration.sql don't match the Ion names of the model fields
+	assert.Equal(t, map[string]SchemaIssueKind{
+		"Contract(Hash)": IssueExtraIndex, "Contract(address)": IssueMissingIndex, "Contract(id)": IssueMissingIndex,
+		"ControlRecord(Status)": IssueExtraIndex, "ControlRecord(documentId)": IssueMissingIndex,
+		"Image(id)": IssueIndexCase, "Image(imageId)": IssueMissingIndex, "Signer(publicAddress)": IssueIndexCase,
+		"TransactionLog(ToAddress)": IssueExtraIndex, "TransactionLog(to)": IssueMissingIndex, "TransactionLog(txID)": IssueIndexCase,
+	}, issues)
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, sql.FS, 3))
+
+	declared, err = dbm.CompareDeclaredSchema(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, declared.Issues)
+}
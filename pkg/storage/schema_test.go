@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+	"github.com/carflores-zh/qldb-go/sql"
+)
+
+func TestDeclaredSchema(t *testing.T) {
+	type document struct {
+		ID      string `ion:"id" qldb:"index"`
+		Address string `ion:"address,omitempty" qldb:"index"`
+		Network string `ion:"network"`
+		Owner   string `qldb:"index"`
+	}
+
+	schema, err := DeclaredSchema([]model.Table{{Name: "A", Document: document{}}, {Name: "B"}})
+	require.NoError(t, err)
+	assert.Equal(t, Schema{"A": {"Owner", "address", "id"}, "B": {}}, schema)
+
+	_, err = DeclaredSchema([]model.Table{{Name: "A"}, {Name: "A"}})
+	assert.ErrorContains(t, err, "table A is declared twice")
+
+	_, err = DeclaredSchema([]model.Table{{Name: "A", Document: &document{}}})
+	assert.ErrorContains(t, err, "is not a struct")
+
+	type unique struct {
+		ID string `ion:"id" qldb:"unique"`
+	}
+
+	_, err = DeclaredSchema([]model.Table{{Name: "A", Document: unique{}}})
+	assert.ErrorContains(t, err, `field ID has an unknown qldb tag "unique"`)
+
+	schema, err = DeclaredSchema(model.Tables)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"txID", "to"}, []string{schema["TransactionLog"][1], schema["TransactionLog"][0]})
+	assert.NotContains(t, schema, "MigrationLock", "the migrator creates its lock table")
+}
+
+func TestMigratedSchema(t *testing.T) {
+	schema, err := MigratedSchema(migrationsFS(map[string]string{
+		"up/1-migration.sql": "CREATE TABLE Migration;\nCREATE TABLE A;\nCREATE INDEX ON A (id);\nCREATE TABLE B;",
+		"up/2-migration.sql": "DROP TABLE A;\nCREATE INDEX ON B (address.network);\nCREATE TABLE C;",
+		"up/3-migration.sql": "UNDROP TABLE A;\nDROP INDEX \"4tPW3fUhaVhDinRgKRLhGU\" ON B;",
+		"up/4-migration.sql": "CREATE INDEX ON C (id);\nCREATE INDEX ON C (address);\nDROP INDEX ON C (id);",
+		"up/5-migration.sql": "DROP TABLE C;\nUNDROP TABLE \"5PLf9SXwndd63lPaSIa0O6\";",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, Schema{"Migration": {}, "A": {"id"}, "B": {"address.network"}}, schema, "a table undropped by id is not replayed")
+}
+
+func TestDiffSchema(t *testing.T) {
+	diff := DiffSchema(
+		Schema{"Migration": {}, "A": {"ID", "id"}, "Old": {}},
+		Schema{"Migration": {}, "A": {"address", "id"}, "New": {"id"}},
+	)
+
+	assert.Equal(t, []string{"DROP INDEX ON A(ID)", "CREATE INDEX ON A(address)", "CREATE TABLE New", "CREATE INDEX ON New(id)",
+		"DROP TABLE Old"}, diff.Up)
+	assert.Equal(t, []string{"UNDROP TABLE Old", "DROP TABLE New", "DROP INDEX ON A(address)", "CREATE INDEX ON A(ID)"}, diff.Down)
+	assert.False(t, diff.Empty())
+	assert.NoError(t, diff.Validate("3-migration.sql"))
+
+	assert.Equal(t, "DROP INDEX ON A(ID);\nCREATE INDEX ON A(address);\nCREATE TABLE New;\nCREATE INDEX ON New(id);\nDROP TABLE Old;\n",
+		diff.UpFile())
+	assert.Equal(t, "UNDROP TABLE Old;\nDROP TABLE New;\nDROP INDEX ON A(address);\nCREATE INDEX ON A(ID);\n", diff.DownFile())
+
+	diff = DiffSchema(Schema{"A": {"ID"}}, Schema{"A": {}})
+	assert.Equal(t, SchemaDiff{Up: []string{"DROP INDEX ON A(ID)"}, Down: []string{"CREATE INDEX ON A(ID)"}}, diff)
+
+	assert.True(t, DiffSchema(Schema{"A": {"id"}}, Schema{"A": {"id"}}).Empty())
+
+	// reserved words are quoted
+	diff = DiffSchema(Schema{"TransactionLog": {"To"}, "User": {}}, Schema{"TransactionLog": {"from.address", "to"}})
+	assert.Equal(t, []string{`DROP INDEX ON TransactionLog("To")`, `CREATE INDEX ON TransactionLog("from".address)`,
+		`CREATE INDEX ON TransactionLog("to")`, `DROP TABLE "User"`}, diff.Up)
+	assert.Equal(t, []string{`UNDROP TABLE "User"`, `DROP INDEX ON TransactionLog("to")`,
+		`DROP INDEX ON TransactionLog("from".address)`, `CREATE INDEX ON TransactionLog("To")`}, diff.Down)
+
+	// a quoted name would be read as a table id
+	err := diff.Validate("4-migration.sql")
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+	assert.ErrorContains(t, err, "down/4-migration.sql:1")
+	assert.ErrorContains(t, err, "can't be undropped by name")
+
+	diff = DiffSchema(Schema{"TransactionLog": {}}, Schema{"TransactionLog": {"to"}})
+	assert.NoError(t, diff.Validate("4-migration.sql"))
+
+	err = SchemaDiff{Up: []string{"CREATE INDEX ON A(id)"}}.Validate("4-migration.sql")
+	assert.ErrorIs(t, err, ErrInvalidMigration)
+	assert.ErrorContains(t, err, "down/4-migration.sql")
+
+	err = SchemaDiff{Up: []string{"SELECT * FROM A"}, Down: []string{"DROP TABLE A"}}.Validate("4-migration.sql")
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+}
+
+func TestGenerateMigration(t *testing.T) {
+	version, diff, err := GenerateMigration(migrationsFS(map[string]string{
+		"up/1-migration.sql": "CREATE TABLE Migration;",
+		"up/5-migration.sql": "CREATE TABLE A;",
+	}), []model.Table{{Name: "Migration"}, {Name: "A"}, {Name: "B"}})
+	require.NoError(t, err)
+	assert.Equal(t, 6, version)
+	assert.Equal(t, []string{"CREATE TABLE B"}, diff.Up)
+
+	// the migrations in /sql create the schema declared in pkg/model, run cmd/generate-migration when it fails
+	version, diff, err = GenerateMigration(sql.FS, model.Tables)
+	require.NoError(t, err)
+	assert.True(t, diff.Empty(), "missing migration %d:\n%s", version, diff.UpFile())
+
+	dbm := newFakeMigrator(t, version-1)
+
+	tables, err := dbm.UserTables(context.Background())
+	require.NoError(t, err)
+
+	declared, err := DeclaredSchema(model.Tables)
+	require.NoError(t, err)
+
+	for name, paths := range declared {
+		for _, path := range paths {
+			pending, errIndex := IndexOnline(name, path)(tables)
+			require.NoError(t, errIndex)
+			assert.Empty(t, pending)
+		}
+	}
+}
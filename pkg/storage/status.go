@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+)
+
+const (
+	migrationTable    = "Migration"
+	baselineMigration = "baseline"
+	forceMigration    = "force"
+	migrationFailed   = "failed"
+)
+
+// MigrationStatus is the recorded version of the ledger and the state of every migration version
+type MigrationStatus struct {
+	Current  model.Migration  // most recent applied record, the zero Migration when nothing was recorded
+	Failed   *model.Migration // the last run when it failed and was rolled back
+	Versions []VersionStatus  // ascending
+}
+
+// VersionStatus is a migration version, applied when it is at or below the current version
+type VersionStatus struct {
+	Version  int
+	Source   string // file or Go migration, the recorded source when it is gone
+	Applied  bool
+	Record   *model.Migration // latest up run of an applied version, nil when baselined, forced or never run
+	Checksum string           // checksum of the file now, empty for Go migrations or when it can't be read
+	Err      error            // the file can't be read or parsed
+}
+
+// Drifted tells if the file changed since its recorded run
+func (s VersionStatus) Drifted() bool {
+	return s.Applied && s.Record != nil && s.Record.Checksum != "" && s.Record.Checksum != s.Checksum
+}
+
+func (s VersionStatus) state() string {
+	switch {
+	case !s.Applied:
+		return "pending"
+	case s.Drifted():
+		return "drifted"
+	case s.Record == nil:
+		return "assumed"
+	default:
+		return "applied"
+	}
+}
+
+// Status compares the Migration table with the migrations of fsys, it only reads the ledger
+func (dbm *DBMigrator) Status(ctx context.Context, fsys fs.FS) (*MigrationStatus, error) {
+	files, err := loadMigrations(fsys, dbm.GoMigrations...)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := dbm.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{Current: getMostRecentVersion(migrations)}
+
+	if last := getLastRun(migrations); last.Status == migrationFailed {
+		status.Failed = &last
+	}
+
+	runs := map[int]model.Migration{}
+
+	for _, migration := range migrations {
+		if migration.Direction != upMigration || migration.Status == migrationFailed {
+			continue
+		}
+
+		if previous, ok := runs[migration.Version]; !ok || recordedAfter(migration, previous) {
+			runs[migration.Version] = migration
+		}
+	}
+
+	versions := append([]int{}, files.versions...)
+
+	// applied versions whose file is gone are listed too
+	for version := range runs {
+		if !files.has(version) && version <= status.Current.Version {
+			versions = append(versions, version)
+		}
+	}
+
+	sort.Ints(versions)
+
+	for _, version := range versions {
+		s := VersionStatus{
+			Version: version,
+			Source:  files.name(upMigration, version),
+			Applied: version <= status.Current.Version,
+		}
+
+		if run, ok := runs[version]; ok && s.Applied {
+			s.Record = &run
+
+			if !files.has(version) && run.Source != "" {
+				s.Source = run.Source
+			}
+		}
+
+		if _, isCode := files.code[version]; !isCode {
+			statements, errRead := files.read(upMigration, version)
+			if errRead != nil {
+				s.Err = errRead
+			} else {
+				s.Checksum = checksum(statements)
+			}
+		}
+
+		status.Versions = append(status.Versions, s)
+	}
+
+	return status, nil
+}
+
+// String renders the status as a table, checksums are shortened to 12 characters
+func (s *MigrationStatus) String() string {
+	var b strings.Builder
+
+	if s.Current.MigratedAt.IsZero() {
+		b.WriteString("no migrations recorded\n\n")
+	} else {
+		fmt.Fprintf(&b, "ledger at version %d (%s on %s by %s)\n\n", s.Current.Version, s.Current.Direction,
+			s.Current.MigratedAt.UTC().Format(time.RFC3339), s.Current.Principal)
+	}
+
+	if s.Failed != nil {
+		fmt.Fprintf(&b, "last run failed: %s %s on %s by %s, rolled back: %s\n\n", s.Failed.Source, s.Failed.Direction,
+			s.Failed.MigratedAt.UTC().Format(time.RFC3339), s.Failed.Principal, s.Failed.Error)
+	}
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tCHECKSUM\tSOURCE")
+
+	for _, v := range s.Versions {
+		var appliedAt, sum string
+
+		if v.Record != nil {
+			appliedAt = v.Record.MigratedAt.UTC().Format(time.RFC3339)
+			sum = shortChecksum(v.Record.Checksum)
+		}
+
+		if v.Drifted() {
+			sum += " -> " + shortChecksum(v.Checksum)
+		} else if sum == "" {
+			sum = shortChecksum(v.Checksum)
+		}
+
+		source := v.Source
+		if v.Err != nil {
+			source += " (" + v.Err.Error() + ")"
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", v.Version, v.state(), appliedAt, sum, source)
+	}
+
+	_ = w.Flush()
+
+	return b.String()
+}
+
+func shortChecksum(sum string) string {
+	if len(sum) > 12 {
+		return sum[:12]
+	}
+
+	return sum
+}
+
+// Baseline records that a ledger created before the migrator, or by hand, is at version without running
+// any migration. The ledger must have no migration recorded, use Force to change a recorded version
+func (dbm *DBMigrator) Baseline(ctx context.Context, fsys fs.FS, version int) error {
+	return dbm.recordVersion(ctx, fsys, version, baselineMigration)
+}
+
+// Force records that the ledger is at version without running any migration, e.g. after a partial failure
+// left some statements of a migration applied and they were completed or reverted by hand
+func (dbm *DBMigrator) Force(ctx context.Context, fsys fs.FS, version int) error {
+	return dbm.recordVersion(ctx, fsys, version, forceMigration)
+}
+
+func (dbm *DBMigrator) recordVersion(ctx context.Context, fsys fs.FS, version int, direction string) error {
+	files, err := loadMigrations(fsys, dbm.GoMigrations...)
+	if err != nil {
+		return err
+	}
+
+	if version != 0 && !files.has(version) {
+		return fmt.Errorf("%w: there is no migration for version %d, versions are %v", ErrInvalidMigration, version, files.versions)
+	}
+
+	lock, err := dbm.AcquireLock(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer dbm.releaseLock(lock)
+
+	// a ledger created by hand may not have the Migration table the first migration creates
+	if err = dbm.ensureTable(ctx, migrationTable); err != nil {
+		return err
+	}
+
+	migrations, err := dbm.GetMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	if direction == baselineMigration && len(migrations) > 0 {
+		current := getMostRecentVersion(migrations)
+		return &MigrationDriftError{
+			Version: current.Version,
+			Reason:  fmt.Sprintf("can't baseline a ledger with %d migrations recorded, force the version instead", len(migrations)),
+		}
+	}
+
+	log.Info().Int("version", version).Str("direction", direction).Msg("recording version without running migrations")
+
+	return dbm.InsertMigration(ctx, model.Migration{
+		Version:   version,
+		Direction: direction,
+		Source:    direction,
+	})
+}
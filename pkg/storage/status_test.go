@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/storage/fake"
+)
+
+func TestDBMigrator_Status(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql": "CREATE TABLE Migration;",
+		"up/2-migration.sql": "CREATE TABLE A;",
+	})
+
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}, Principal: "deploy"}
+
+	status, err := dbm.Status(ctx, fsys)
+	require.NoError(t, err)
+	assert.Equal(t, 0, status.Current.Version)
+	assert.Equal(t, []string{"pending", "pending"}, []string{status.Versions[0].state(), status.Versions[1].state()})
+	assert.Contains(t, status.String(), "no migrations recorded")
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 1))
+
+	fsys["up/1-migration.sql"].Data = []byte("CREATE TABLE Migration;\nCREATE TABLE B;")
+
+	status, err = dbm.Status(ctx, fsys)
+	require.NoError(t, err)
+	require.Len(t, status.Versions, 2)
+	assert.True(t, status.Versions[0].Drifted())
+	assert.Equal(t, "up/1-migration.sql", status.Versions[0].Record.Source)
+	assert.False(t, status.Versions[1].Applied)
+
+	text := status.String()
+	assert.Contains(t, text, "ledger at version 1 (up on ")
+	assert.Contains(t, text, " by deploy)")
+	assert.Regexp(t, `1\s+drifted\s+\S+Z\s+`+shortChecksum(status.Versions[0].Record.Checksum)+` -> `+shortChecksum(status.Versions[0].Checksum)+`\s+up/1-migration.sql`, text)
+	assert.Regexp(t, `2\s+pending\s+`+shortChecksum(status.Versions[1].Checksum)+`\s+up/2-migration.sql`, text)
+
+	// an applied file that is gone is still listed, with the source recorded
+	delete(fsys, "up/1-migration.sql")
+	fsys["up/1-renamed.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE Migration;")}
+
+	status, err = dbm.Status(ctx, fsys)
+	require.NoError(t, err)
+	assert.Equal(t, "up/1-renamed.sql", status.Versions[0].Source)
+}
+
+func TestDBMigrator_Baseline(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql": "CREATE TABLE Migration;\nCREATE TABLE A;",
+		"up/2-migration.sql": "CREATE TABLE B;",
+		"up/3-migration.sql": "CREATE TABLE C;",
+	})
+
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}}
+
+	// a ledger created by hand, without the Migration table
+	_, err := Execute(ctx, dbm.Driver, func(txn qldbdriver.Transaction) (interface{}, error) {
+		if _, err := txn.Execute("CREATE TABLE A"); err != nil {
+			return nil, err
+		}
+
+		return txn.Execute("CREATE TABLE B")
+	})
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, dbm.Baseline(ctx, fsys, 4), "there is no migration for version 4")
+	require.NoError(t, dbm.Baseline(ctx, fsys, 2))
+
+	status, err := dbm.Status(ctx, fsys)
+	require.NoError(t, err)
+	assert.Equal(t, 2, status.Current.Version)
+	assert.Equal(t, baselineMigration, status.Current.Direction)
+	assert.Equal(t, []string{"assumed", "assumed", "pending"},
+		[]string{status.Versions[0].state(), status.Versions[1].state(), status.Versions[2].state()})
+
+	err = dbm.Baseline(ctx, fsys, 1)
+	assert.ErrorIs(t, err, ErrMigrationDrift)
+	assert.ErrorContains(t, err, "force the version instead")
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 3), "only the migrations after the baseline run")
+
+	tables, err := dbm.Driver.GetTableNames(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A", "B", "C", "Migration", lockTable}, tables)
+}
+
+func TestDBMigrator_Force(t *testing.T) {
+	fsys := migrationsFS(map[string]string{
+		"up/1-migration.sql": "CREATE TABLE Migration;",
+		"up/2-migration.sql": "CREATE TABLE A;\nCREATE TABLE A;",
+	})
+
+	ctx := context.Background()
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver()}}
+
+	assert.ErrorIs(t, dbm.MigrateQLDB(ctx, fsys, 2), ErrInvalidStatement)
+
+	// the second statement is removed and the first one run by hand
+	fsys["up/2-migration.sql"].Data = []byte("CREATE TABLE A;")
+	_, err := Execute(ctx, dbm.Driver, func(txn qldbdriver.Transaction) (qldbdriver.Result, error) {
+		return txn.Execute("CREATE TABLE A")
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, dbm.Force(ctx, fsys, 2))
+
+	migrations, err := dbm.GetMigrations(ctx)
+	require.NoError(t, err)
+
+	current := getMostRecentVersion(migrations)
+	assert.Equal(t, 2, current.Version)
+	assert.Equal(t, forceMigration, current.Direction)
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, fsys, 2))
+}
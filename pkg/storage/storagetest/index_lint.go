@@ -0,0 +1,511 @@
+package storagetest
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/carflores-zh/qldb-go/pkg/internal/partiql"
+	"github.com/carflores-zh/qldb-go/pkg/storage"
+)
+
+// scanComment marks a statement that reads a whole table on purpose, e.g. // qldb:scan the table has one document.
+// The linter skips the statements on the line of the comment and on the next one
+const scanComment = "qldb:scan"
+
+// dynamicTable replaces the %s of a statement built with fmt.Sprintf, its table is only known at run time
+const dynamicTable = "_dynamic_"
+
+// queryFuncs are the functions whose string arguments are linted as statements
+var queryFuncs = map[string]bool{
+	"Execute":         true,
+	"QueryMany":       true,
+	"QueryOne":        true,
+	"QueryRaw":        true,
+	"ExecReturningID": true,
+}
+
+// indexWarning is a statement that QLDB can only run by reading a whole table or history
+type indexWarning struct {
+	Position  string // file:line of the statement, statements[i] for the statements given to LintIndexes
+	Statement string
+	Reason    string
+}
+
+func (w indexWarning) String() string {
+	return fmt.Sprintf("%s: %s {%s}", w.Position, w.Reason, w.Statement)
+}
+
+// lintSource lints the statements passed as string literals, or as fmt.Sprintf formats, to Execute, QueryMany,
+// QueryOne, QueryRaw and ExecReturningID in the Go files of dir, test files excluded
+func lintSource(dir string, schema storage.Schema) ([]indexWarning, error) {
+	fset := token.NewFileSet()
+
+	packages, err := parser.ParseDir(fset, dir, func(info fs.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+
+	files := map[string]*ast.File{}
+
+	for _, pkg := range packages {
+		for name, file := range pkg.Files {
+			names = append(names, name)
+			files[name] = file
+		}
+	}
+
+	sort.Strings(names)
+
+	var warnings []indexWarning
+
+	for _, name := range names {
+		warnings = append(warnings, lintFile(fset, files[name], schema)...)
+	}
+
+	return warnings, nil
+}
+
+func lintFile(fset *token.FileSet, file *ast.File, schema storage.Schema) []indexWarning {
+	scans := map[int]bool{}
+
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			if strings.Contains(comment.Text, scanComment) {
+				line := fset.Position(comment.Pos()).Line
+				scans[line] = true
+				scans[line+1] = true
+			}
+		}
+	}
+
+	var warnings []indexWarning
+
+	ast.Inspect(file, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok || !queryFuncs[funcName(call.Fun)] {
+			return true
+		}
+
+		for _, arg := range call.Args {
+			lit := statementLiteral(arg)
+			if lit == nil {
+				continue
+			}
+
+			statement, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				continue
+			}
+
+			position := fset.Position(lit.Pos())
+			if scans[position.Line] || scans[fset.Position(call.Pos()).Line] {
+				continue
+			}
+
+			statement = strings.ReplaceAll(statement, "%s", dynamicTable)
+
+			if reason := lintStatement(schema, statement); reason != "" {
+				warnings = append(warnings, indexWarning{
+					Position:  fmt.Sprintf("%s:%d", filepath.Base(position.Filename), position.Line),
+					Statement: statement,
+					Reason:    reason,
+				})
+			}
+		}
+
+		return true
+	})
+
+	return warnings
+}
+
+// funcName is the name of the called function, QueryMany for QueryMany[T] and Execute for txn.Execute
+func funcName(fun ast.Expr) string {
+	switch f := fun.(type) {
+	case *ast.Ident:
+		return f.Name
+	case *ast.SelectorExpr:
+		return f.Sel.Name
+	case *ast.IndexExpr:
+		return funcName(f.X)
+	case *ast.IndexListExpr:
+		return funcName(f.X)
+	default:
+		return ""
+	}
+}
+
+// statementLiteral returns the string literal of an argument or the format of a fmt.Sprintf argument
+func statementLiteral(arg ast.Expr) *ast.BasicLit {
+	if call, ok := arg.(*ast.CallExpr); ok && funcName(call.Fun) == "Sprintf" && len(call.Args) > 0 {
+		arg = call.Args[0]
+	}
+
+	if lit, ok := arg.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+		return lit
+	}
+
+	return nil
+}
+
+// querySource is the document source of a statement: a table or the history of a table, with its
+// alias and the variable bound to the document id by BY
+type querySource struct {
+	table   string
+	history bool
+	alias   string
+	byVar   string
+}
+
+// lintStatement returns why QLDB reads a whole table or history to run statement, empty when its WHERE clause
+// has an equality (= or IN) on an indexed field of schema, on the document id of a history, or on the BY
+// variable. Inserts, statements on information_schema and statements joining sources are not checked
+func lintStatement(schema storage.Schema, statement string) string {
+	class, err := storage.ClassifyStatement(statement)
+	if err != nil || (class != storage.ClassQuery && class != storage.ClassDML) {
+		return ""
+	}
+
+	tokens, err := partiql.Lex(statement)
+	if err != nil {
+		return ""
+	}
+
+	src, rest, ok := parseSource(tokens)
+	if !ok {
+		return ""
+	}
+
+	var indexed []string
+
+	what := "every document of " + src.table
+
+	switch {
+	case src.history:
+		indexed = []string{"metadata.id"}
+		what = fmt.Sprintf("every revision of %s, the history is looked up by metadata.id", src.table)
+	case src.table == dynamicTable:
+		if src.byVar == "" {
+			return ""
+		}
+	default:
+		paths, declared := schema[src.table]
+		if !declared {
+			return fmt.Sprintf("table %s is not declared", src.table)
+		}
+
+		indexed = paths
+	}
+
+	where := whereClause(rest)
+	if where == nil {
+		return "no WHERE clause, reads " + what
+	}
+
+	if src.byVar != "" {
+		indexed = append(append([]string{}, indexed...), src.byVar)
+	}
+
+	if src.usesIndex(where, indexed) {
+		return ""
+	}
+
+	if len(indexed) == 0 {
+		return fmt.Sprintf("%s has no index, reads %s", src.table, what)
+	}
+
+	return fmt.Sprintf("no equality on an indexed field (%s), reads %s", strings.Join(indexed, ", "), what)
+}
+
+// parseSource reads the source of a SELECT, UPDATE, DELETE or FROM statement and returns the tokens after it
+func parseSource(tokens []partiql.Token) (querySource, []partiql.Token, bool) {
+	var src querySource
+
+	start := -1
+
+	switch {
+	case tokens[0].Keyword("SELECT"):
+		depth := 0
+
+		for i, t := range tokens {
+			depth += nesting(t)
+			if depth == 0 && t.Keyword("FROM") {
+				start = i + 1
+				break
+			}
+		}
+	case tokens[0].Keyword("UPDATE"), tokens[0].Keyword("FROM"):
+		start = 1
+	case tokens[0].Keyword("DELETE"):
+		start = 2
+	}
+
+	if start < 0 || start >= len(tokens) || !isIdent(tokens[start]) {
+		return src, nil, false
+	}
+
+	i := start + 1
+
+	switch {
+	case tokens[start].Keyword("history") && i < len(tokens) && tokens[i].Text == "(":
+		if i+1 >= len(tokens) || !isIdent(tokens[i+1]) {
+			return src, nil, false
+		}
+
+		src.table, src.history = tokens[i+1].Text, true
+
+		for depth := 0; i < len(tokens); i++ {
+			if depth += nesting(tokens[i]); depth == 0 {
+				break
+			}
+		}
+
+		i++
+	case i < len(tokens) && tokens[i].Text == ".":
+		// information_schema.user_tables
+		return src, nil, false
+	default:
+		src.table = tokens[start].Text
+	}
+
+	if i < len(tokens) && tokens[i].Keyword("AS") {
+		i++
+	}
+
+	if i < len(tokens) && isIdent(tokens[i]) && !isClauseKeyword(tokens[i]) {
+		src.alias = tokens[i].Text
+		i++
+	}
+
+	if i+1 < len(tokens) && tokens[i].Keyword("BY") {
+		src.byVar = tokens[i+1].Text
+		i += 2
+	}
+
+	// joins are not checked
+	if i < len(tokens) && (tokens[i].Text == "," || tokens[i].Keyword("JOIN") || tokens[i].Keyword("INNER") ||
+		tokens[i].Keyword("LEFT") || tokens[i].Keyword("RIGHT") || tokens[i].Keyword("CROSS") || tokens[i].Keyword("OUTER")) {
+		return src, nil, false
+	}
+
+	return src, tokens[i:], true
+}
+
+// whereClause returns the predicate of the WHERE clause of tokens, nil when there is none
+func whereClause(tokens []partiql.Token) []partiql.Token {
+	depth := 0
+
+	for i, t := range tokens {
+		depth += nesting(t)
+		if depth != 0 || !t.Keyword("WHERE") {
+			continue
+		}
+
+		end := len(tokens)
+
+		for j := i + 1; j < len(tokens); j++ {
+			depth += nesting(tokens[j])
+			if depth == 0 && (tokens[j].Keyword("SET") || tokens[j].Keyword("REMOVE") || tokens[j].Keyword("INSERT") ||
+				tokens[j].Keyword("ORDER") || tokens[j].Keyword("GROUP") || tokens[j].Keyword("LIMIT")) {
+				end = j
+				break
+			}
+		}
+
+		return tokens[i+1 : end]
+	}
+
+	return nil
+}
+
+// usesIndex tells if the predicate has an equality on an indexed path in every branch of its ORs
+func (s querySource) usesIndex(predicate []partiql.Token, indexed []string) bool {
+	predicate = unwrap(predicate)
+
+	if branches := splitKeyword(predicate, "OR"); len(branches) > 1 {
+		for _, branch := range branches {
+			if !s.usesIndex(branch, indexed) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for _, conjunct := range splitKeyword(predicate, "AND") {
+		if len(conjunct) > 0 && conjunct[0].Text == "(" && len(unwrap(conjunct)) < len(conjunct) {
+			if s.usesIndex(conjunct, indexed) {
+				return true
+			}
+
+			continue
+		}
+
+		if path, ok := s.equalityPath(conjunct); ok && contains(indexed, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// equalityPath returns the path compared by path = value, value = path or path IN (...), without the alias
+func (s querySource) equalityPath(conjunct []partiql.Token) (string, bool) {
+	depth := 0
+
+	for i, t := range conjunct {
+		depth += nesting(t)
+		if depth != 0 || (t.Text != "=" && !t.Keyword("IN")) {
+			continue
+		}
+
+		left, right := conjunct[:i], conjunct[i+1:]
+
+		if path, ok := s.path(left); ok && (t.Keyword("IN") || isValue(right)) {
+			return path, true
+		}
+
+		if path, ok := s.path(right); ok && t.Text == "=" && isValue(left) {
+			return path, true
+		}
+
+		return "", false
+	}
+
+	return "", false
+}
+
+// path joins a.b.c into a path of the source, the alias, or the table name when there is no alias, is removed
+func (s querySource) path(tokens []partiql.Token) (string, bool) {
+	if len(tokens) == 0 || len(tokens)%2 == 0 {
+		return "", false
+	}
+
+	parts := make([]string, 0, len(tokens)/2+1)
+
+	for i, t := range tokens {
+		if i%2 == 1 {
+			if t.Text != "." {
+				return "", false
+			}
+
+			continue
+		}
+
+		if !isIdent(t) {
+			return "", false
+		}
+
+		parts = append(parts, t.Text)
+	}
+
+	qualifier := s.alias
+	if qualifier == "" && !s.history {
+		qualifier = s.table
+	}
+
+	if len(parts) > 1 && parts[0] == qualifier {
+		parts = parts[1:]
+	}
+
+	return strings.Join(parts, "."), true
+}
+
+func isValue(tokens []partiql.Token) bool {
+	if len(tokens) != 1 {
+		return false
+	}
+
+	switch tokens[0].Kind {
+	case partiql.Parameter, partiql.String, partiql.Number, partiql.Ion:
+		return true
+	default:
+		return tokens[0].Keyword("true") || tokens[0].Keyword("false") || tokens[0].Keyword("null")
+	}
+}
+
+func isIdent(t partiql.Token) bool {
+	return t.Kind == partiql.Word || t.Kind == partiql.QuotedIdent
+}
+
+func isClauseKeyword(t partiql.Token) bool {
+	for _, kw := range []string{"WHERE", "BY", "SET", "REMOVE", "INSERT", "ORDER", "GROUP", "LIMIT", "JOIN", "INNER",
+		"LEFT", "RIGHT", "CROSS", "OUTER"} {
+		if t.Keyword(kw) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nesting is +1 for an opening bracket and -1 for a closing one
+func nesting(t partiql.Token) int {
+	if t.Kind != partiql.Punctuation {
+		return 0
+	}
+
+	switch t.Text {
+	case "(", "[", "{", "<<":
+		return 1
+	case ")", "]", "}", ">>":
+		return -1
+	default:
+		return 0
+	}
+}
+
+// unwrap removes the parentheses around the whole predicate
+func unwrap(tokens []partiql.Token) []partiql.Token {
+	for len(tokens) >= 2 && tokens[0].Text == "(" && tokens[len(tokens)-1].Text == ")" {
+		depth := 0
+
+		for i, t := range tokens {
+			if depth += nesting(t); depth == 0 && i < len(tokens)-1 {
+				return tokens
+			}
+		}
+
+		tokens = tokens[1 : len(tokens)-1]
+	}
+
+	return tokens
+}
+
+// splitKeyword splits tokens on the keyword outside brackets
+func splitKeyword(tokens []partiql.Token, kw string) [][]partiql.Token {
+	var parts [][]partiql.Token
+
+	depth, start := 0, 0
+
+	for i, t := range tokens {
+		depth += nesting(t)
+		if depth == 0 && t.Keyword(kw) {
+			parts = append(parts, tokens[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, tokens[start:])
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
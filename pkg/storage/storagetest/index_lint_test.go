@@ -0,0 +1,84 @@
+package storagetest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/storage"
+)
+
+func TestLintStatement(t *testing.T) {
+	schema := storage.Schema{"Contract": {"address", "id"}, "Share": {}}
+
+	for statement, want := range map[string]string{
+		"SELECT * FROM Contract AS c WHERE c.id = ?":                          "",
+		"SELECT * FROM Contract WHERE Contract.address = 'x' AND network = ?": "",
+		"SELECT * FROM Contract c WHERE ? = c.id":                             "",
+		"SELECT * FROM Contract AS c WHERE c.id IN (?, ?)":                    "",
+		"SELECT * FROM Contract AS c WHERE (c.id = ? OR c.address = ?)":       "",
+		"UPDATE Contract AS c SET c.network = ? WHERE c.address = ?":          "",
+		"FROM Contract AS c WHERE c.id = ? SET c.network = ?":                 "",
+		"DELETE FROM Contract AS c WHERE c.id = ?":                            "",
+		"SELECT * FROM Share AS s BY sid WHERE sid = ?":                       "",
+		"SELECT * FROM history(Contract) AS h WHERE h.metadata.id = ?":        "",
+		"SELECT * FROM history(_dynamic_) AS h WHERE h.metadata.id = ?":       "",
+		"SELECT * FROM _dynamic_ AS t WHERE t.anything = ?":                   "",
+		"SELECT * FROM information_schema.user_tables":                        "",
+		"SELECT * FROM MigrationLock AS l WHERE l.token = ?":                  "table MigrationLock is not declared",
+		"INSERT INTO Share ?": "",
+		"SELECT * FROM Contract AS c, Share AS s WHERE c.id = s.id":              "",
+		"SELECT * FROM Contract":                                                 "no WHERE clause, reads every document of Contract",
+		"SELECT * FROM Contract AS c WHERE c.network = ?":                        "no equality on an indexed field (address, id), reads every document of Contract",
+		"SELECT * FROM Contract AS c WHERE c.ID = ?":                             "no equality on an indexed field (address, id)",
+		"SELECT * FROM Contract AS c WHERE c.id > ?":                             "no equality on an indexed field (address, id)",
+		"SELECT * FROM Contract AS c WHERE c.id = ? OR c.network = ?":            "no equality on an indexed field (address, id)",
+		"SELECT * FROM Contract AS c WHERE c.id = c.address":                     "no equality on an indexed field (address, id)",
+		"SELECT * FROM Share AS s WHERE s.owner = ?":                             "Share has no index, reads every document of Share",
+		"SELECT * FROM Signer AS s WHERE s.publicAddress = ?":                    "table Signer is not declared",
+		"SELECT metadata.version from history(Contract) where data.id = ?":       "no equality on an indexed field (metadata.id), reads every revision of Contract",
+		"SELECT * FROM (SELECT * FROM Contract AS c WHERE c.id = ?) WHERE x = ?": "",
+	} {
+		assert.Contains(t, lintStatement(schema, statement), want, statement)
+
+		if want == "" {
+			assert.Empty(t, lintStatement(schema, statement), statement)
+		}
+	}
+}
+
+func TestLintSource(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "queries.go"), []byte(`package queries
+
+import "fmt"
+
+func queries(ctx Context, txn Transaction, table string) {
+	QueryMany[Contract](ctx, txn, "SELECT * FROM Contract AS c WHERE c.network = ?")
+	txn.Execute(fmt.Sprintf("SELECT * FROM history(%s) AS h WHERE h.data.id = ?", table))
+
+	// qldb:scan the whole table on purpose
+	txn.Execute("SELECT * FROM Contract")
+	fmt.Println("SELECT * FROM Contract")
+}
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "queries_test.go"), []byte(`package queries
+
+func queriesTest(txn Transaction) {
+	txn.Execute("SELECT * FROM Contract")
+}
+`), 0o600))
+
+	warnings, err := lintSource(dir, storage.Schema{"Contract": {"id"}})
+	require.NoError(t, err)
+	require.Len(t, warnings, 2)
+
+	assert.Equal(t, "queries.go:6", warnings[0].Position)
+	assert.Equal(t, "SELECT * FROM Contract AS c WHERE c.network = ?", warnings[0].Statement)
+	assert.Equal(t, "queries.go:7", warnings[1].Position)
+	assert.Contains(t, warnings[1].String(), "queries.go:7: no equality on an indexed field (metadata.id), reads every revision of _dynamic_")
+}
@@ -0,0 +1,38 @@
+// Package storagetest provides test helpers for the packages that query the ledger
+package storagetest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+	"github.com/carflores-zh/qldb-go/pkg/storage"
+)
+
+// LintIndexes fails t for each statement of the Go files of dir, and each of statements, that QLDB can only run
+// by reading a whole table or history of tables, e.g. storagetest.LintIndexes(t, ".", model.Tables). statements
+// are the ones built outside dir, e.g. by another package. A scan on purpose is marked with a
+// // qldb:scan <reason> comment on the line of the statement or the line before
+func LintIndexes(t testing.TB, dir string, tables []model.Table, statements ...string) {
+	t.Helper()
+
+	schema, err := storage.DeclaredSchema(tables)
+	if err != nil {
+		t.Fatalf("declared schema: %v", err)
+	}
+
+	warnings, err := lintSource(dir, schema)
+	if err != nil {
+		t.Fatalf("lint %s: %v", dir, err)
+	}
+
+	for i, statement := range statements {
+		if reason := lintStatement(schema, statement); reason != "" {
+			warnings = append(warnings, indexWarning{Position: fmt.Sprintf("statements[%d]", i), Statement: statement, Reason: reason})
+		}
+	}
+
+	for _, warning := range warnings {
+		t.Errorf("%s", warning)
+	}
+}
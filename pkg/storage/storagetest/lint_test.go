@@ -0,0 +1,53 @@
+package storagetest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+)
+
+// recorder collects the errors of LintIndexes
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestLintIndexes_Storage(t *testing.T) {
+	r := &recorder{TB: t}
+	LintIndexes(r, "..", model.Tables)
+
+	// QueryTransactions lists the whole TransactionLog, it stays reported until it is paginated or filtered
+	require.Len(t, r.errors, 1, r.errors)
+	assert.Contains(t, r.errors[0], "no WHERE clause, reads every document of TransactionLog {SELECT * FROM TransactionLog}")
+}
+
+func TestLintIndexes(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "share.go"), []byte(`package share
+
+func selectShares(txn Transaction, owner string) {
+	txn.Execute("SELECT * FROM Share AS s WHERE s.owner = ?", owner)
+	txn.Execute("SELECT * FROM Share AS s BY docID WHERE docID = ?", owner)
+}
+`), 0o600))
+
+	r := &recorder{TB: t}
+	LintIndexes(r, dir, model.Tables, "SELECT * FROM Contract AS c WHERE c.id = ?", "SELECT * FROM Share AS s WHERE s.id = ?")
+
+	require.Len(t, r.errors, 2)
+	assert.Contains(t, r.errors[0], "share.go:4: Share has no index, reads every document of Share")
+	assert.Contains(t, r.errors[1], "statements[1]: Share has no index")
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+	"github.com/carflores-zh/qldb-go/pkg/storage/fake"
+	"github.com/carflores-zh/qldb-go/sql"
+)
+
+// newFakeMigrator returns a migrator backed by the in-memory driver, migrated to version
+func newFakeMigrator(t *testing.T, version int) *DBMigrator {
+	t.Helper()
+
+	dbm := &DBMigrator{DB: &DB{Driver: fake.NewDriver(), LedgerName: "test"}}
+
+	require.NoError(t, dbm.MigrateQLDB(context.Background(), sql.FS, version))
+
+	return dbm
+}
+
+func TestStore_Fake(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeMigrator(t, 1).DB
+
+	contract := &model.Contract{Address: "0x1", Network: "0x123", SendFunds: true}
+
+	id, err := db.InsertContractTx(ctx, contract)
+	require.NoError(t, err)
+	assert.Equal(t, id, contract.ID)
+
+	active, err := db.SelectContractActive(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []model.Contract{{ID: id}}, active)
+
+	contract.Network = "ethereum"
+	require.NoError(t, db.UpdateContract(ctx, contract))
+
+	versions, err := db.SelectContractVersion(ctx, id)
+	require.NoError(t, err)
+	// the history is looked up by document id, the insert is version 0
+	require.Len(t, versions, 2)
+	assert.Equal(t, []int{0, 1}, []int{versions[0].Version, versions[1].Version})
+
+	instances, err := db.SelectContractInstance(ctx, id, versions[1].Version)
+	require.NoError(t, err)
+	assert.Equal(t, []model.Contract{*contract}, instances)
+
+	address, err := db.SelectRevisionAddress(ctx, "Contract", id, versions[1].Version)
+	require.NoError(t, err)
+	assert.NotEmpty(t, address.StrandID)
+	assert.Positive(t, address.SequenceNo)
+
+	_, err = db.SelectRevisionAddress(ctx, "Contract", id, 9)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = db.SelectRevisionAddress(ctx, "Contract) AS c, history(Image", id, 1)
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+
+	redacted, err := db.HasDataRedaction(ctx, "Contract")
+	require.NoError(t, err)
+	assert.False(t, redacted)
+
+	require.NoError(t, db.InsertTx(ctx, &model.TransactionLog{TxID: "0xabc", To: "0x1", Nonce: 7}))
+
+	count, txs, err := db.QueryTransactions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "0xabc", txs[0].TxID)
+
+	image := &model.Image{ImageID: "img-1", Document: []byte(`{"pcr0":"abc"}`)}
+	require.NoError(t, db.InsertImage(ctx, image))
+
+	images, err := db.GetAllImages(ctx)
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+	assert.Equal(t, image.ID, images[0].ID)
+	assert.Equal(t, image.Document, images[0].Document)
+}
+
+func TestDBMigrator_MigrateQLDB_Fake(t *testing.T) {
+	ctx := context.Background()
+	dbm := newFakeMigrator(t, 2)
+
+	tables, err := dbm.Driver.GetTableNames(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, tables, "TheHistory")
+
+	require.NoError(t, dbm.MigrateQLDB(ctx, sql.FS, 1))
+
+	tables, err = dbm.Driver.GetTableNames(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, tables, "TheHistory")
+	assert.Contains(t, tables, "Contract")
+}
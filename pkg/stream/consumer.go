@@ -0,0 +1,138 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/carflores-zh/qldb-go/pkg/journal"
+	"github.com/carflores-zh/qldb-go/pkg/model"
+)
+
+// ErrHashMismatch is returned for a revision whose content doesn't match its hash
+var ErrHashMismatch = errors.New("revision hash mismatch")
+
+// RevisionEvent is a revision of a document of a known table, Data is nil for a deleted or redacted revision
+type RevisionEvent[T any] struct {
+	TableName string
+	Revision  *journal.Revision
+	Data      *T
+}
+
+// Handlers are called for every record of the stream, a nil handler ignores its records.
+// Kinesis delivers at least once, handlers must tolerate a revision delivered twice
+type Handlers struct {
+	Control       func(ctx context.Context, control *Control) error
+	BlockSummary  func(ctx context.Context, summary *BlockSummary) error
+	Contract      func(ctx context.Context, event RevisionEvent[model.Contract]) error
+	ControlRecord func(ctx context.Context, event RevisionEvent[model.Control]) error
+	Image         func(ctx context.Context, event RevisionEvent[model.Image]) error
+	// Revision receives the revisions of the tables without a typed handler
+	Revision func(ctx context.Context, details *RevisionDetails) error
+}
+
+type Consumer struct {
+	Source   Source
+	Handlers Handlers
+}
+
+func NewConsumer(source Source, handlers Handlers) *Consumer {
+	return &Consumer{Source: source, Handlers: handlers}
+}
+
+// Run handles the records of the source until it is exhausted, ctx is done or a handler fails
+func (c *Consumer) Run(ctx context.Context) error {
+	for {
+		raw, err := c.Source.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err = c.Handle(ctx, raw); err != nil {
+			return fmt.Errorf("kinesis record %s: %w", raw.SequenceNumber, err)
+		}
+	}
+}
+
+// Handle decodes a Kinesis record and dispatches its QLDB records
+func (c *Consumer) Handle(ctx context.Context, raw RawRecord) error {
+	records, err := Decode(raw.Data)
+	if err != nil {
+		return err
+	}
+
+	for i := range records {
+		if err = c.dispatch(ctx, &records[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Consumer) dispatch(ctx context.Context, record *Record) error {
+	switch {
+	case record.Control != nil:
+		if c.Handlers.Control != nil {
+			return c.Handlers.Control(ctx, record.Control)
+		}
+	case record.BlockSummary != nil:
+		if c.Handlers.BlockSummary != nil {
+			return c.Handlers.BlockSummary(ctx, record.BlockSummary)
+		}
+	case record.RevisionDetails != nil:
+		return c.dispatchRevision(ctx, record.RevisionDetails)
+	}
+
+	return nil
+}
+
+func (c *Consumer) dispatchRevision(ctx context.Context, details *RevisionDetails) error {
+	if details.Revision.Metadata == nil {
+		return fmt.Errorf("%s revision without metadata", details.TableName)
+	}
+
+	matches, err := details.Revision.HashMatches()
+	if err != nil {
+		return err
+	}
+
+	if !matches {
+		return fmt.Errorf("%s %s: %w", details.TableName, details.Revision.Metadata.ID, ErrHashMismatch)
+	}
+
+	switch {
+	case details.TableName == "Contract" && c.Handlers.Contract != nil:
+		return handleRevision(ctx, details, c.Handlers.Contract)
+	case details.TableName == "ControlRecord" && c.Handlers.ControlRecord != nil:
+		return handleRevision(ctx, details, c.Handlers.ControlRecord)
+	case details.TableName == "Image" && c.Handlers.Image != nil:
+		return handleRevision(ctx, details, c.Handlers.Image)
+	case c.Handlers.Revision != nil:
+		return c.Handlers.Revision(ctx, details)
+	}
+
+	return nil
+}
+
+func handleRevision[T any](ctx context.Context, details *RevisionDetails, handler func(context.Context, RevisionEvent[T]) error) error {
+	event := RevisionEvent[T]{TableName: details.TableName, Revision: details.Revision}
+
+	var data T
+
+	found, err := details.Revision.Unmarshal(&data)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", details.TableName, details.Revision.Metadata.ID, err)
+	}
+
+	if found {
+		event.Data = &data
+	}
+
+	return handler(ctx, event)
+}
@@ -0,0 +1,100 @@
+package stream
+
+import (
+	"bytes"
+	"crypto/md5" //nolint:gosec // the KPL format checksums the aggregated records with MD5
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// kplMagic prefixes the Kinesis Producer Library aggregated records
+var kplMagic = []byte{0xF3, 0x89, 0x9A, 0xC2}
+
+const (
+	md5Length = md5.Size
+
+	// protobuf wire types
+	wireVarint = 0
+	wireBytes  = 2
+
+	// AggregatedRecord and Record field numbers
+	aggregatedRecordsField = 3
+	recordDataField        = 3
+)
+
+var errInvalidAggregation = errors.New("invalid KPL aggregated record")
+
+// deaggregate returns the user records of a KPL aggregated record, or data itself when it is not aggregated.
+// The format is the magic, an AggregatedRecord protobuf message and the MD5 of that message
+func deaggregate(data []byte) ([][]byte, error) {
+	if len(data) < len(kplMagic)+md5Length || !bytes.HasPrefix(data, kplMagic) {
+		return [][]byte{data}, nil
+	}
+
+	message := data[len(kplMagic) : len(data)-md5Length]
+
+	checksum := md5.Sum(message) //nolint:gosec // see the import
+	if !bytes.Equal(checksum[:], data[len(data)-md5Length:]) {
+		// a record starting with the magic by chance, KPL consumers treat it as not aggregated
+		return [][]byte{data}, nil
+	}
+
+	var records [][]byte
+
+	err := readFields(message, func(field int, value []byte) error {
+		if field != aggregatedRecordsField {
+			return nil
+		}
+
+		return readFields(value, func(field int, value []byte) error {
+			if field == recordDataField {
+				records = append(records, value)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// readFields calls fn with every length-delimited field of a protobuf message, varints are skipped
+func readFields(message []byte, fn func(field int, value []byte) error) error {
+	for len(message) > 0 {
+		key, n := binary.Uvarint(message)
+		if n <= 0 {
+			return fmt.Errorf("%w: bad field key", errInvalidAggregation)
+		}
+
+		message = message[n:]
+
+		switch key & 0x7 {
+		case wireVarint:
+			if _, n = binary.Uvarint(message); n <= 0 {
+				return fmt.Errorf("%w: bad varint", errInvalidAggregation)
+			}
+
+			message = message[n:]
+		case wireBytes:
+			length, n := binary.Uvarint(message)
+			if n <= 0 || uint64(len(message)-n) < length {
+				return fmt.Errorf("%w: bad length", errInvalidAggregation)
+			}
+
+			value := message[n : n+int(length)]
+			message = message[n+int(length):]
+
+			if err := fn(int(key>>3), value); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: unexpected wire type %d", errInvalidAggregation, key&0x7)
+		}
+	}
+
+	return nil
+}
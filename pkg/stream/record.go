@@ -0,0 +1,237 @@
+// Package stream consumes the QLDB journal stream: the Kinesis records written by StreamJournalToKinesis.
+// Records are decoded (KPL aggregated ones included) and the revisions are dispatched to typed handlers.
+package stream
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/amzn/ion-go/ion"
+
+	"github.com/carflores-zh/qldb-go/pkg/internal/ionvalue"
+	"github.com/carflores-zh/qldb-go/pkg/journal"
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
+)
+
+// Record types of the QLDB stream
+const (
+	RecordTypeControl         = "CONTROL"
+	RecordTypeBlockSummary    = "BLOCK_SUMMARY"
+	RecordTypeRevisionDetails = "REVISION_DETAILS"
+)
+
+// Control record types, CREATED is the first record of a stream and COMPLETED, CANCELLED or FAILED its last one
+const (
+	ControlCreated   = "CREATED"
+	ControlCompleted = "COMPLETED"
+	ControlCancelled = "CANCELLED"
+	ControlFailed    = "FAILED"
+)
+
+// Record is a QLDB stream record, only the payload matching RecordType is set
+type Record struct {
+	StreamArn       string
+	RecordType      string
+	Control         *Control
+	BlockSummary    *BlockSummary
+	RevisionDetails *RevisionDetails
+}
+
+type Control struct {
+	ControlRecordType string `ion:"controlRecordType"`
+}
+
+// BlockSummary is a committed block, its revisions are sent as separate REVISION_DETAILS records
+type BlockSummary struct {
+	BlockAddress      metadata.BlockAddress    `ion:"blockAddress"`
+	TransactionID     string                   `ion:"transactionId"`
+	BlockTimestamp    ion.Timestamp            `ion:"blockTimestamp"`
+	BlockHash         []byte                   `ion:"blockHash"`
+	EntriesHash       []byte                   `ion:"entriesHash"`
+	PreviousBlockHash []byte                   `ion:"previousBlockHash"`
+	EntriesHashList   [][]byte                 `ion:"entriesHashList"`
+	TransactionInfo   *journal.TransactionInfo `ion:"transactionInfo"`
+	RevisionSummaries []RevisionSummary        `ion:"revisionSummaries"`
+}
+
+type RevisionSummary struct {
+	Hash       []byte `ion:"hash"`
+	DocumentID string `ion:"documentId"`
+}
+
+// RevisionDetails is a revision of a user table
+type RevisionDetails struct {
+	TableName string
+	TableID   string
+	Revision  *journal.Revision
+}
+
+type tableInfo struct {
+	TableName string `ion:"tableName"`
+	TableID   string `ion:"tableId"`
+}
+
+// Decode returns the QLDB records of a Kinesis record, several when it is KPL aggregated
+func Decode(data []byte) ([]Record, error) {
+	userRecords, err := deaggregate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+
+	for _, userRecord := range userRecords {
+		reader := ion.NewReaderBytes(userRecord)
+
+		for reader.Next() {
+			record, err := decodeRecord(reader)
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", len(records), err)
+			}
+
+			records = append(records, *record)
+		}
+
+		if reader.Err() != nil {
+			return nil, reader.Err()
+		}
+	}
+
+	return records, nil
+}
+
+func decodeRecord(reader ion.Reader) (*Record, error) {
+	if reader.Type() != ion.StructType || reader.IsNull() {
+		return nil, fmt.Errorf("record is %v, expected a struct", reader.Type())
+	}
+
+	if err := reader.StepIn(); err != nil {
+		return nil, err
+	}
+
+	record := &Record{}
+
+	var payload []byte
+
+	for reader.Next() {
+		name, err := reader.FieldName()
+		if err != nil {
+			return nil, err
+		}
+
+		if name == nil || name.Text == nil {
+			continue
+		}
+
+		switch *name.Text {
+		case "qldbStreamArn":
+			record.StreamArn, err = stringValue(reader)
+		case "recordType":
+			record.RecordType, err = stringValue(reader)
+		case "payload":
+			payload, err = ionvalue.Raw(reader)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", *name.Text, err)
+		}
+	}
+
+	if reader.Err() != nil {
+		return nil, reader.Err()
+	}
+
+	if err := reader.StepOut(); err != nil {
+		return nil, err
+	}
+
+	if payload == nil {
+		return nil, errors.New("record without payload")
+	}
+
+	switch record.RecordType {
+	case RecordTypeControl:
+		record.Control = &Control{}
+		return record, ion.Unmarshal(payload, record.Control)
+	case RecordTypeBlockSummary:
+		record.BlockSummary = &BlockSummary{}
+		return record, ion.Unmarshal(payload, record.BlockSummary)
+	case RecordTypeRevisionDetails:
+		details, err := decodeRevisionDetails(payload)
+		record.RevisionDetails = details
+
+		return record, err
+	default:
+		return nil, fmt.Errorf("unknown record type %q", record.RecordType)
+	}
+}
+
+func decodeRevisionDetails(payload []byte) (*RevisionDetails, error) {
+	var decoded struct {
+		TableInfo tableInfo `ion:"tableInfo"`
+	}
+
+	if err := ion.Unmarshal(payload, &decoded); err != nil {
+		return nil, err
+	}
+
+	details := &RevisionDetails{TableName: decoded.TableInfo.TableName, TableID: decoded.TableInfo.TableID}
+
+	reader := ion.NewReaderBytes(payload)
+	if !reader.Next() {
+		return nil, reader.Err()
+	}
+
+	if err := reader.StepIn(); err != nil {
+		return nil, err
+	}
+
+	for reader.Next() {
+		name, err := reader.FieldName()
+		if err != nil {
+			return nil, err
+		}
+
+		if name == nil || name.Text == nil || *name.Text != "revision" {
+			continue
+		}
+
+		raw, err := ionvalue.Raw(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		if details.Revision, err = journal.ParseRevision(raw); err != nil {
+			return nil, fmt.Errorf("revision: %w", err)
+		}
+	}
+
+	if reader.Err() != nil {
+		return nil, reader.Err()
+	}
+
+	if details.Revision == nil {
+		return nil, errors.New("revision details without revision")
+	}
+
+	return details, nil
+}
+
+// stringValue reads a string or a symbol
+func stringValue(reader ion.Reader) (string, error) {
+	if reader.Type() == ion.SymbolType {
+		symbol, err := reader.SymbolValue()
+		if err != nil || symbol == nil || symbol.Text == nil {
+			return "", err
+		}
+
+		return *symbol.Text, nil
+	}
+
+	value, err := reader.StringValue()
+	if err != nil || value == nil {
+		return "", err
+	}
+
+	return *value, nil
+}
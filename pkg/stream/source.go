@@ -0,0 +1,75 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// RawRecord is a Kinesis record as delivered by a Source
+type RawRecord struct {
+	SequenceNumber string `json:"SequenceNumber"`
+	PartitionKey   string `json:"PartitionKey"`
+	Data           []byte `json:"Data"`
+}
+
+// Source delivers the Kinesis records of the stream, Next returns io.EOF once there are no more records
+type Source interface {
+	Next(ctx context.Context) (RawRecord, error)
+}
+
+// FileSource reads Kinesis records from a JSON lines file, one record per line with the fields of the
+// Kinesis GetRecords output (Data base64 encoded), e.g. to replay a captured stream in tests
+type FileSource struct {
+	file    *os.File
+	scanner *bufio.Scanner
+	line    int
+}
+
+const maxRecordLine = 2 * 1024 * 1024 // base64 of the 1 MB Kinesis record limit, with room for the other fields
+
+func NewFileSource(path string) (*FileSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordLine)
+
+	return &FileSource{file: file, scanner: scanner}, nil
+}
+
+func (s *FileSource) Next(ctx context.Context) (RawRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return RawRecord{}, err
+	}
+
+	for s.scanner.Scan() {
+		s.line++
+
+		if len(s.scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record RawRecord
+		if err := json.Unmarshal(s.scanner.Bytes(), &record); err != nil {
+			return RawRecord{}, fmt.Errorf("%s:%d: %w", s.file.Name(), s.line, err)
+		}
+
+		return record, nil
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return RawRecord{}, err
+	}
+
+	return RawRecord{}, io.EOF
+}
+
+func (s *FileSource) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,179 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/model"
+)
+
+const recordsFile = "testdata/records.jsonl"
+
+// readRecords returns the raw records of the test stream
+func readRecords(t *testing.T) []RawRecord {
+	t.Helper()
+
+	source, err := NewFileSource(recordsFile)
+	require.NoError(t, err)
+
+	defer source.Close()
+
+	var records []RawRecord
+
+	for {
+		record, err := source.Next(context.Background())
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+
+		require.NoError(t, err)
+
+		records = append(records, record)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	raw := readRecords(t)
+	require.Len(t, raw, 6)
+
+	records, err := Decode(raw[1].Data)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, RecordTypeBlockSummary, records[0].RecordType)
+	assert.Equal(t, int64(12), records[0].BlockSummary.BlockAddress.SequenceNo)
+	assert.Equal(t, "Contract", records[0].BlockSummary.TransactionInfo.Documents["8F0TPCmdNQ6JTRpiLj2TmW"].TableName)
+
+	// KPL aggregated
+	records, err = Decode(raw[3].Data)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "ControlRecord", records[0].RevisionDetails.TableName)
+	assert.Equal(t, "Image", records[1].RevisionDetails.TableName)
+
+	_, err = Decode([]byte(`{qldbStreamArn:"arn",recordType:"UNKNOWN",payload:{}}`))
+	assert.ErrorContains(t, err, "unknown record type")
+}
+
+func TestDeaggregate(t *testing.T) {
+	aggregated := readRecords(t)[3].Data
+
+	userRecords, err := deaggregate(aggregated)
+	require.NoError(t, err)
+	assert.Len(t, userRecords, 2)
+
+	// a bad checksum means the data only starts with the magic by chance
+	corrupted := bytes.Clone(aggregated)
+	corrupted[len(corrupted)-1] ^= 0xff
+
+	userRecords, err = deaggregate(corrupted)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{corrupted}, userRecords)
+}
+
+func TestConsumer_Run(t *testing.T) {
+	source, err := NewFileSource(recordsFile)
+	require.NoError(t, err)
+
+	defer source.Close()
+
+	var (
+		controls  []string
+		summaries int
+		contracts []RevisionEvent[model.Contract]
+		records   []RevisionEvent[model.Control]
+		images    []RevisionEvent[model.Image]
+		others    []string
+	)
+
+	consumer := NewConsumer(source, Handlers{
+		Control: func(ctx context.Context, control *Control) error {
+			controls = append(controls, control.ControlRecordType)
+			return nil
+		},
+		BlockSummary: func(ctx context.Context, summary *BlockSummary) error {
+			summaries++
+			return nil
+		},
+		Contract: func(ctx context.Context, event RevisionEvent[model.Contract]) error {
+			contracts = append(contracts, event)
+			return nil
+		},
+		ControlRecord: func(ctx context.Context, event RevisionEvent[model.Control]) error {
+			records = append(records, event)
+			return nil
+		},
+		Image: func(ctx context.Context, event RevisionEvent[model.Image]) error {
+			images = append(images, event)
+			return nil
+		},
+		Revision: func(ctx context.Context, details *RevisionDetails) error {
+			others = append(others, details.TableName)
+			return nil
+		},
+	})
+
+	require.NoError(t, consumer.Run(context.Background()))
+
+	assert.Equal(t, []string{ControlCreated, ControlCompleted}, controls)
+	assert.Equal(t, 1, summaries)
+
+	require.Len(t, contracts, 1)
+	assert.Equal(t, 1, contracts[0].Revision.Metadata.Version)
+	assert.Equal(t, &model.Contract{ID: "8F0TPCmdNQ6JTRpiLj2TmW", Address: "0x1", Network: "ethereum", SendFunds: true}, contracts[0].Data)
+
+	require.Len(t, records, 1)
+	assert.Equal(t, "8F0TPCmdNQ6JTRpiLj2TmW", records[0].Data.DocumentID)
+
+	require.Len(t, images, 1)
+	assert.Nil(t, images[0].Data, "the image revision is a delete")
+
+	assert.Equal(t, []string{"Signer"}, others)
+}
+
+func TestConsumer_Errors(t *testing.T) {
+	ctx := context.Background()
+	raw := readRecords(t)
+
+	t.Run("hash mismatch", func(t *testing.T) {
+		tampered := raw[2]
+		tampered.Data = bytes.Replace(tampered.Data, []byte("ethereum"), []byte("polygonx"), 1)
+
+		err := NewConsumer(nil, Handlers{}).Handle(ctx, tampered)
+		assert.ErrorIs(t, err, ErrHashMismatch)
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		source, err := NewFileSource(recordsFile)
+		require.NoError(t, err)
+
+		defer source.Close()
+
+		errHandler := errors.New("handler failed")
+
+		err = NewConsumer(source, Handlers{
+			Contract: func(ctx context.Context, event RevisionEvent[model.Contract]) error {
+				return errHandler
+			},
+		}).Run(ctx)
+		assert.ErrorIs(t, err, errHandler)
+		assert.ErrorContains(t, err, raw[2].SequenceNumber)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		source, err := NewFileSource(recordsFile)
+		require.NoError(t, err)
+
+		defer source.Close()
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		assert.ErrorIs(t, NewConsumer(source, Handlers{}).Run(canceled), context.Canceled)
+	})
+}
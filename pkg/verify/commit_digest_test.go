@@ -0,0 +1,138 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/amzn/ion-go/ion"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/qldbsession"
+	"github.com/awslabs/amazon-qldb-driver-go/v3/qldbdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sessionServer answers the QLDB session commands of the driver and keeps the commit digest it sends.
+// The driver computes that digest with its own Ion hash and dot, QLDB rejects a commit whose digest
+// differs from the ledger one, so it is an implementation of the hash join independent from this package
+type sessionServer struct {
+	transactionID string
+	commitDigest  []byte
+}
+
+func (s *sessionServer) Do(req *http.Request) (*http.Response, error) {
+	var command struct {
+		StartSession      *struct{}
+		StartTransaction  *struct{}
+		ExecuteStatement  *struct{}
+		CommitTransaction *struct{ CommitDigest []byte }
+		EndSession        *struct{}
+	}
+
+	if err := json.NewDecoder(req.Body).Decode(&command); err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+
+	switch {
+	case command.StartSession != nil:
+		result = map[string]interface{}{"StartSession": map[string]string{"SessionToken": "session"}}
+	case command.StartTransaction != nil:
+		result = map[string]interface{}{"StartTransaction": map[string]string{"TransactionId": s.transactionID}}
+	case command.ExecuteStatement != nil:
+		result = map[string]interface{}{"ExecuteStatement": map[string]interface{}{"FirstPage": map[string]interface{}{"Values": []interface{}{}}}}
+	case command.CommitTransaction != nil:
+		s.commitDigest = command.CommitTransaction.CommitDigest
+		result = map[string]interface{}{"CommitTransaction": map[string]interface{}{
+			"TransactionId": s.transactionID,
+			"CommitDigest":  command.CommitTransaction.CommitDigest,
+		}}
+	case command.EndSession != nil:
+		result = map[string]interface{}{"EndSession": map[string]interface{}{}}
+	default:
+		return nil, fmt.Errorf("unexpected command")
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/x-amz-json-1.0"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func ionHashOf(t *testing.T, value interface{}) []byte {
+	t.Helper()
+
+	binary, err := ion.MarshalBinary(value)
+	require.NoError(t, err)
+
+	hash, err := IonHash(binary)
+	require.NoError(t, err)
+
+	return hash
+}
+
+// TestDot_CommitDigest checks IonHash and Dot against the commit digest of the QLDB driver: the transaction id
+// joined with each statement, itself joined with its parameters
+func TestDot_CommitDigest(t *testing.T) {
+	server := &sessionServer{transactionID: "7q6aB1ML2vZPI1kc3sU4OC"}
+
+	session := qldbsession.New(qldbsession.Options{
+		Region:           "us-east-1",
+		Credentials:      aws.AnonymousCredentials{},
+		EndpointResolver: qldbsession.EndpointResolverFromURL("https://session.qldb.test"),
+		HTTPClient:       server,
+	})
+
+	driver, err := qldbdriver.New("test", session)
+	require.NoError(t, err)
+
+	defer driver.Shutdown(context.Background())
+
+	statements := []struct {
+		text       string
+		parameters []interface{}
+	}{
+		{"INSERT INTO Contract ?", []interface{}{map[string]interface{}{"address": "0x1", "network": "ethereum", "sendFunds": true}}},
+		{"UPDATE Contract AS c SET c.network = ? WHERE c.id = ? AND c.version = ?", []interface{}{"polygon", "QAMMHTXhu2zerDt2mcGGzy", 3}},
+		{"SELECT * FROM Contract", nil},
+	}
+
+	_, err = driver.Execute(context.Background(), func(txn qldbdriver.Transaction) (interface{}, error) {
+		for _, statement := range statements {
+			if _, errExec := txn.Execute(statement.text, statement.parameters...); errExec != nil {
+				return nil, errExec
+			}
+		}
+
+		return nil, nil
+	})
+	require.NoError(t, err)
+
+	digest := ionHashOf(t, server.transactionID)
+
+	for _, statement := range statements {
+		statementHash := ionHashOf(t, statement.text)
+
+		for _, parameter := range statement.parameters {
+			statementHash = Dot(statementHash, ionHashOf(t, parameter))
+		}
+
+		digest = Dot(digest, statementHash)
+	}
+
+	require.Len(t, server.commitDigest, HashLength)
+	assert.Equal(t, server.commitDigest, digest)
+}
@@ -0,0 +1,99 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/amzn/ion-go/ion"
+	ionhash "github.com/amzn/ion-hash-go"
+
+	"github.com/carflores-zh/qldb-go/pkg/internal/ionvalue"
+)
+
+// RevisionHash computes the QLDB hash of a revision (Ion text or binary, as returned by history() or
+// the committed views): the Ion hash of its data, or its dataHash once redacted, joined with the Ion hash of its metadata
+func RevisionHash(revision []byte) ([]byte, error) {
+	reader := ion.NewReaderBytes(revision)
+
+	if !reader.Next() {
+		if reader.Err() != nil {
+			return nil, reader.Err()
+		}
+
+		return nil, errors.New("empty revision")
+	}
+
+	if reader.Type() != ion.StructType || reader.IsNull() {
+		return nil, fmt.Errorf("revision is %v, expected a struct", reader.Type())
+	}
+
+	if err := reader.StepIn(); err != nil {
+		return nil, err
+	}
+
+	var dataHash, metadataHash []byte
+
+	for reader.Next() {
+		name, err := reader.FieldName()
+		if err != nil {
+			return nil, err
+		}
+
+		if name == nil || name.Text == nil {
+			continue
+		}
+
+		switch *name.Text {
+		case "data":
+			dataHash, err = hashValue(reader)
+		case "dataHash":
+			dataHash, err = reader.ByteValue()
+		case "metadata":
+			metadataHash, err = hashValue(reader)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("revision %s: %w", *name.Text, err)
+		}
+	}
+
+	if reader.Err() != nil {
+		return nil, reader.Err()
+	}
+
+	if metadataHash == nil {
+		return nil, errors.New("revision has no metadata")
+	}
+
+	return Dot(dataHash, metadataHash), nil
+}
+
+// IonHash returns the SHA-256 Ion hash of the first value of data
+func IonHash(data []byte) ([]byte, error) {
+	reader := ion.NewReaderBytes(data)
+
+	if !reader.Next() {
+		if reader.Err() != nil {
+			return nil, reader.Err()
+		}
+
+		return nil, errors.New("no ion value")
+	}
+
+	return hashValue(reader)
+}
+
+// hashValue hashes the value the reader is positioned on
+func hashValue(reader ion.Reader) ([]byte, error) {
+	writer, err := ionhash.NewHashWriter(ion.NewBinaryWriter(io.Discard), ionhash.NewCryptoHasherProvider(ionhash.SHA256))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ionvalue.Copy(reader, writer); err != nil {
+		return nil, err
+	}
+
+	return writer.Sum(nil)
+}
@@ -0,0 +1,67 @@
+package verify
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/amzn/ion-go/ion"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fixtureData = `{address:"0x1",id:"QAMMHTXhu2zerDt2mcGGzy",network:"ethereum",sendFunds:true}`
+
+func TestRevisionHash(t *testing.T) {
+	f := loadFixture(t, "contract_revision.json")
+
+	var revision Revision
+	require.NoError(t, ion.UnmarshalString(f.Revision, &revision))
+
+	hash, err := RevisionHash([]byte(f.Revision))
+	require.NoError(t, err)
+	assert.Equal(t, revision.Hash, hash)
+
+	t.Run("binary", func(t *testing.T) {
+		var value interface{}
+		require.NoError(t, ion.UnmarshalString(f.Revision, &value))
+
+		// the field order of the text must not matter either, a struct hash is order independent
+		binary, err := ion.MarshalBinary(value)
+		require.NoError(t, err)
+
+		hash, err := RevisionHash(binary)
+		require.NoError(t, err)
+		assert.Equal(t, revision.Hash, hash)
+	})
+
+	t.Run("redacted", func(t *testing.T) {
+		dataHash, err := IonHash([]byte(fixtureData))
+		require.NoError(t, err)
+
+		redacted := strings.Replace(f.Revision, "data:"+fixtureData, fmt.Sprintf("dataHash:{{%s}}", base64.StdEncoding.EncodeToString(dataHash)), 1)
+		require.NotEqual(t, f.Revision, redacted)
+
+		hash, err := RevisionHash([]byte(redacted))
+		require.NoError(t, err)
+		assert.Equal(t, revision.Hash, hash, "a redacted revision keeps its hash")
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		hash, err := RevisionHash([]byte(strings.Replace(f.Revision, "ethereum", "polygon", 1)))
+		require.NoError(t, err)
+		assert.NotEqual(t, revision.Hash, hash)
+	})
+}
+
+func TestRevisionHash_Invalid(t *testing.T) {
+	_, err := RevisionHash([]byte(`{data:{a:1}}`))
+	assert.ErrorContains(t, err, "no metadata")
+
+	_, err = RevisionHash([]byte(`[1]`))
+	assert.ErrorContains(t, err, "expected a struct")
+
+	_, err = RevisionHash(nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+// HashLength is the length of the SHA-256 hashes of the journal
+const HashLength = sha256.Size
+
+// Step is one link of the proof path: the proof hash joined with the previous hash gave Computed
+type Step struct {
+	ProofHash []byte
+	Computed  []byte
+}
+
+// ProofResult is the outcome of a Merkle proof verification
+type ProofResult struct {
+	Verified     bool
+	RevisionHash []byte
+	Digest       []byte
+	Path         []Step
+}
+
+// VerifyProof chains the revision hash with every proof hash and compares the result to the digest
+func VerifyProof(revisionHash, digest []byte, proof [][]byte) (*ProofResult, error) {
+	if len(revisionHash) != HashLength {
+		return nil, fmt.Errorf("revision hash has %d bytes, expected %d", len(revisionHash), HashLength)
+	}
+
+	if len(digest) != HashLength {
+		return nil, fmt.Errorf("digest has %d bytes, expected %d", len(digest), HashLength)
+	}
+
+	result := &ProofResult{
+		RevisionHash: revisionHash,
+		Digest:       digest,
+		Path:         make([]Step, 0, len(proof)),
+	}
+
+	candidate := revisionHash
+
+	for i, proofHash := range proof {
+		if len(proofHash) != HashLength {
+			return nil, fmt.Errorf("proof hash %d has %d bytes, expected %d", i, len(proofHash), HashLength)
+		}
+
+		candidate = Dot(candidate, proofHash)
+		result.Path = append(result.Path, Step{ProofHash: proofHash, Computed: candidate})
+	}
+
+	result.Verified = bytes.Equal(candidate, digest)
+
+	return result, nil
+}
+
+// Dot is the QLDB hash join: SHA-256 of both hashes concatenated in hash order,
+// an empty hash leaves the other one unchanged
+func Dot(h1, h2 []byte) []byte {
+	switch {
+	case len(h1) == 0:
+		return h2
+	case len(h2) == 0:
+		return h1
+	}
+
+	var concatenated []byte
+
+	if CompareHashes(h1, h2) < 0 {
+		concatenated = append(append(concatenated, h1...), h2...)
+	} else {
+		concatenated = append(append(concatenated, h2...), h1...)
+	}
+
+	sum := sha256.Sum256(concatenated)
+
+	return sum[:]
+}
+
+// CompareHashes orders hashes as QLDB does: signed bytes, starting from the last one
+func CompareHashes(h1, h2 []byte) int {
+	for i := len(h1) - 1; i >= 0; i-- {
+		if diff := int(int8(h1[i])) - int(int8(h2[i])); diff != 0 {
+			return diff
+		}
+	}
+
+	return 0
+}
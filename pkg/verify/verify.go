@@ -0,0 +1,178 @@
+// Package verify checks document revisions against a ledger digest.
+// The proof returned by GetRevision is verified client-side, so a tampered journal or a forged
+// revision cannot pass even if the QLDB service itself is not trusted.
+package verify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/amzn/ion-go/ion"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/qldb"
+	"github.com/aws/aws-sdk-go-v2/service/qldb/types"
+
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
+)
+
+var (
+	// ErrVerificationFailed is returned when the proof does not lead to the digest
+	ErrVerificationFailed = errors.New("revision verification failed")
+	// ErrDigestTooOld is returned when the revision was committed after the digest tip
+	ErrDigestTooOld = errors.New("digest does not cover the revision")
+)
+
+// Client is the part of storage.QLDBClient used to verify revisions
+//
+//nolint:lll // ignore long line length
+type Client interface {
+	GetDigest(ctx context.Context, params *qldb.GetDigestInput, optFns ...func(*qldb.Options)) (*qldb.GetDigestOutput, error)
+	GetRevision(ctx context.Context, params *qldb.GetRevisionInput, optFns ...func(*qldb.Options)) (*qldb.GetRevisionOutput, error)
+}
+
+// Digest is the hash of the whole journal up to TipAddress
+type Digest struct {
+	Hash       []byte
+	TipAddress metadata.BlockAddress
+}
+
+// Revision is a document revision as returned by GetRevision
+type Revision struct {
+	BlockAddress metadata.BlockAddress `ion:"blockAddress"`
+	Hash         []byte                `ion:"hash"`
+	Metadata     struct {
+		ID      string        `ion:"id"`
+		Version int           `ion:"version"`
+		TxTime  ion.Timestamp `ion:"txTime"`
+		TxID    string        `ion:"txId"`
+	} `ion:"metadata"`
+	IonText string `ion:"-"` // the revision as received, data included
+}
+
+// Result is the outcome of VerifyRevision, Verified requires both the proof and the content hash to match
+type Result struct {
+	*ProofResult
+	Revision    Revision
+	Digest      Digest
+	ContentHash []byte // revision hash computed from the received data and metadata
+}
+
+type Verifier struct {
+	Client     Client
+	LedgerName string
+}
+
+func New(client Client, ledgerName string) *Verifier {
+	return &Verifier{Client: client, LedgerName: ledgerName}
+}
+
+// GetDigest returns the current digest of the ledger
+func (v *Verifier) GetDigest(ctx context.Context) (Digest, error) {
+	out, err := v.Client.GetDigest(ctx, &qldb.GetDigestInput{Name: aws.String(v.LedgerName)})
+	if err != nil {
+		return Digest{}, fmt.Errorf("get digest: %w", err)
+	}
+
+	digest := Digest{Hash: out.Digest}
+
+	if err = unmarshalValueHolder(out.DigestTipAddress, &digest.TipAddress); err != nil {
+		return Digest{}, fmt.Errorf("digest tip address: %w", err)
+	}
+
+	return digest, nil
+}
+
+// VerifyRevision fetches a new digest and verifies the revision of documentID stored at address against it.
+// The error matches ErrVerificationFailed when the proof does not lead to the digest, the result is still returned
+func (v *Verifier) VerifyRevision(ctx context.Context, documentID string, address metadata.BlockAddress) (*Result, error) {
+	digest, err := v.GetDigest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.VerifyRevisionWithDigest(ctx, documentID, address, digest)
+}
+
+// VerifyRevisionWithDigest verifies the revision of documentID stored at address against a digest saved earlier
+func (v *Verifier) VerifyRevisionWithDigest(ctx context.Context, documentID string, address metadata.BlockAddress, digest Digest) (*Result, error) {
+	if address.StrandID == digest.TipAddress.StrandID && address.SequenceNo > digest.TipAddress.SequenceNo {
+		return nil, fmt.Errorf("revision at %d, digest tip at %d: %w", address.SequenceNo, digest.TipAddress.SequenceNo, ErrDigestTooOld)
+	}
+
+	blockAddress, err := marshalValueHolder(address)
+	if err != nil {
+		return nil, err
+	}
+
+	tipAddress, err := marshalValueHolder(digest.TipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := v.Client.GetRevision(ctx, &qldb.GetRevisionInput{
+		Name:             aws.String(v.LedgerName),
+		DocumentId:       aws.String(documentID),
+		BlockAddress:     blockAddress,
+		DigestTipAddress: tipAddress,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get revision %s: %w", documentID, err)
+	}
+
+	var revision Revision
+
+	if err = unmarshalValueHolder(out.Revision, &revision); err != nil {
+		return nil, fmt.Errorf("revision: %w", err)
+	}
+
+	revision.IonText = aws.ToString(out.Revision.IonText)
+
+	if revision.Metadata.ID != documentID || revision.BlockAddress != address {
+		return nil, fmt.Errorf("received revision %s at %+v, requested %s at %+v: %w",
+			revision.Metadata.ID, revision.BlockAddress, documentID, address, ErrVerificationFailed)
+	}
+
+	var proof [][]byte
+
+	if err = unmarshalValueHolder(out.Proof, &proof); err != nil {
+		return nil, fmt.Errorf("proof: %w", err)
+	}
+
+	proofResult, err := VerifyProof(revision.Hash, digest.Hash, proof)
+	if err != nil {
+		return nil, err
+	}
+
+	contentHash, err := RevisionHash([]byte(revision.IonText))
+	if err != nil {
+		return nil, fmt.Errorf("revision hash: %w", err)
+	}
+
+	result := &Result{ProofResult: proofResult, Revision: revision, Digest: digest, ContentHash: contentHash}
+	result.Verified = result.Verified && bytes.Equal(contentHash, revision.Hash)
+
+	if !result.Verified {
+		return result, fmt.Errorf("document %s version %d: %w", documentID, revision.Metadata.Version, ErrVerificationFailed)
+	}
+
+	return result, nil
+}
+
+func marshalValueHolder(v interface{}) (*types.ValueHolder, error) {
+	text, err := ion.MarshalText(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.ValueHolder{IonText: aws.String(string(text))}, nil
+}
+
+func unmarshalValueHolder(holder *types.ValueHolder, v interface{}) error {
+	if holder == nil || holder.IonText == nil {
+		return errors.New("missing value")
+	}
+
+	return ion.UnmarshalString(*holder.IonText, v)
+}
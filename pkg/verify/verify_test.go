@@ -0,0 +1,192 @@
+package verify
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/qldb"
+	"github.com/aws/aws-sdk-go-v2/service/qldb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carflores-zh/qldb-go/pkg/model/metadata"
+)
+
+// fixture is a GetDigest/GetRevision exchange, the Ion values are kept as text. contract_revision.json is built
+// for the tests, not captured from a ledger: its hashes are computed with IonHash and Dot, which TestDot_CommitDigest
+// checks against the driver. The exchanges captured from a ledger are the testdata/recorded_*.json files
+type fixture struct {
+	LedgerName       string `json:"ledgerName"`
+	DocumentID       string `json:"documentId"`
+	BlockAddress     string `json:"blockAddress"`
+	Digest           string `json:"digest"`
+	DigestTipAddress string `json:"digestTipAddress"`
+	Proof            string `json:"proof"`
+	Revision         string `json:"revision"`
+}
+
+func loadFixture(t *testing.T, name string) *fixture {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/" + name)
+	require.NoError(t, err)
+
+	f := &fixture{}
+	require.NoError(t, json.Unmarshal(data, f))
+
+	return f
+}
+
+// replayClient answers with the fixture
+type replayClient struct {
+	fixture *fixture
+}
+
+func (c *replayClient) GetDigest(ctx context.Context, params *qldb.GetDigestInput, optFns ...func(*qldb.Options)) (*qldb.GetDigestOutput, error) {
+	digest, err := base64.StdEncoding.DecodeString(c.fixture.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &qldb.GetDigestOutput{
+		Digest:           digest,
+		DigestTipAddress: &types.ValueHolder{IonText: aws.String(c.fixture.DigestTipAddress)},
+	}, nil
+}
+
+func (c *replayClient) GetRevision(ctx context.Context, params *qldb.GetRevisionInput, optFns ...func(*qldb.Options)) (*qldb.GetRevisionOutput, error) {
+	return &qldb.GetRevisionOutput{
+		Proof:    &types.ValueHolder{IonText: aws.String(c.fixture.Proof)},
+		Revision: &types.ValueHolder{IonText: aws.String(c.fixture.Revision)},
+	}, nil
+}
+
+func blockAddress(t *testing.T, f *fixture) metadata.BlockAddress {
+	t.Helper()
+
+	var address metadata.BlockAddress
+	require.NoError(t, unmarshalValueHolder(&types.ValueHolder{IonText: aws.String(f.BlockAddress)}, &address))
+
+	return address
+}
+
+func TestVerifier_VerifyRevision(t *testing.T) {
+	f := loadFixture(t, "contract_revision.json")
+	v := New(&replayClient{fixture: f}, f.LedgerName)
+
+	result, err := v.VerifyRevision(context.Background(), f.DocumentID, blockAddress(t, f))
+	require.NoError(t, err)
+
+	assert.True(t, result.Verified)
+	assert.Equal(t, 1, result.Revision.Metadata.Version)
+	assert.Equal(t, int64(8), result.Digest.TipAddress.SequenceNo)
+	require.Len(t, result.Path, 4)
+	assert.Equal(t, result.Digest.Hash, result.Path[3].Computed)
+}
+
+// TestVerifier_VerifyRevision_Recorded verifies the exchanges captured from a ledger: digest and digestTipAddress
+// from aws qldb get-digest, proof and revision from aws qldb get-revision with that tip. Their hashes were not
+// computed by this package
+func TestVerifier_VerifyRevision_Recorded(t *testing.T) {
+	files, err := filepath.Glob("testdata/recorded_*.json")
+	require.NoError(t, err)
+
+	if len(files) == 0 {
+		// QLDB reached its end of support on 2025-07-31, there is no ledger left to capture an exchange from
+		t.Skip("no GetDigest/GetRevision exchange captured from a ledger in testdata")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			f := loadFixture(t, filepath.Base(file))
+
+			result, err := New(&replayClient{fixture: f}, f.LedgerName).VerifyRevision(context.Background(), f.DocumentID, blockAddress(t, f))
+			require.NoError(t, err)
+			assert.True(t, result.Verified)
+
+			if len(result.Path) > 0 {
+				assert.Equal(t, result.Digest.Hash, result.Path[len(result.Path)-1].Computed)
+			}
+		})
+	}
+}
+
+func TestVerifier_VerifyRevision_Tampered(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(f *fixture)
+	}{
+		{
+			name: "revision hash",
+			tamper: func(f *fixture) {
+				f.Revision = strings.Replace(f.Revision, "hash:{{R", "hash:{{S", 1)
+			},
+		},
+		{
+			name: "data",
+			tamper: func(f *fixture) {
+				f.Revision = strings.Replace(f.Revision, "ethereum", "polygon", 1)
+			},
+		},
+		{
+			name: "proof",
+			tamper: func(f *fixture) {
+				f.Proof = strings.Replace(f.Proof, "{{J8Xc", "{{K8Xc", 1)
+			},
+		},
+		{
+			name: "digest",
+			tamper: func(f *fixture) {
+				f.Digest = "A" + f.Digest[1:]
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := loadFixture(t, "contract_revision.json")
+			tt.tamper(f)
+
+			result, err := New(&replayClient{fixture: f}, f.LedgerName).
+				VerifyRevision(context.Background(), f.DocumentID, blockAddress(t, f))
+			assert.ErrorIs(t, err, ErrVerificationFailed)
+			require.NotNil(t, result)
+			assert.False(t, result.Verified)
+		})
+	}
+}
+
+func TestVerifier_VerifyRevision_WrongDocument(t *testing.T) {
+	f := loadFixture(t, "contract_revision.json")
+
+	_, err := New(&replayClient{fixture: f}, f.LedgerName).
+		VerifyRevision(context.Background(), "otherDocument", blockAddress(t, f))
+	assert.ErrorIs(t, err, ErrVerificationFailed)
+}
+
+func TestVerifier_VerifyRevisionWithDigest_TooOld(t *testing.T) {
+	f := loadFixture(t, "contract_revision.json")
+	address := blockAddress(t, f)
+	digest := Digest{Hash: make([]byte, HashLength), TipAddress: metadata.BlockAddress{StrandID: address.StrandID, SequenceNo: 1}}
+
+	_, err := New(&replayClient{fixture: f}, f.LedgerName).
+		VerifyRevisionWithDigest(context.Background(), f.DocumentID, address, digest)
+	assert.ErrorIs(t, err, ErrDigestTooOld)
+}
+
+func TestDot(t *testing.T) {
+	h1 := make([]byte, HashLength)
+	h2 := make([]byte, HashLength)
+	h2[HashLength-1] = 0x80 // negative as a signed byte, so h2 sorts first
+
+	assert.Equal(t, Dot(h1, h2), Dot(h2, h1), "the join does not depend on the argument order")
+	assert.Less(t, CompareHashes(h2, h1), 0)
+	assert.Equal(t, h1, Dot(h1, nil))
+	assert.Equal(t, h2, Dot(nil, h2))
+}
@@ -0,0 +1,14 @@
+DROP INDEX ON TransactionLog(txID);
+DROP INDEX ON TransactionLog("to");
+CREATE INDEX ON TransactionLog(TxID);
+CREATE INDEX ON TransactionLog(ToAddress);
+DROP INDEX ON Signer(publicAddress);
+CREATE INDEX ON Signer(PublicAddress);
+DROP INDEX ON Image(imageId);
+DROP INDEX ON Image(id);
+CREATE INDEX ON Image(ID);
+DROP INDEX ON ControlRecord(documentId);
+CREATE INDEX ON ControlRecord(Status);
+DROP INDEX ON Contract(id);
+DROP INDEX ON Contract(address);
+CREATE INDEX ON Contract(Hash);
@@ -0,0 +1,9 @@
+// Package sql holds the ledger migrations, embedded so the migrator works from any directory
+package sql
+
+import "embed"
+
+// FS has the up and down migration directories, pass it to storage.DBMigrator.MigrateQLDB
+//
+//go:embed up/*.sql down/*.sql
+var FS embed.FS
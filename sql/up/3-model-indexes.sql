@@ -0,0 +1,14 @@
+DROP INDEX ON Contract(Hash);
+CREATE INDEX ON Contract(address);
+CREATE INDEX ON Contract(id);
+DROP INDEX ON ControlRecord(Status);
+CREATE INDEX ON ControlRecord(documentId);
+DROP INDEX ON Image(ID);
+CREATE INDEX ON Image(id);
+CREATE INDEX ON Image(imageId);
+DROP INDEX ON Signer(PublicAddress);
+CREATE INDEX ON Signer(publicAddress);
+DROP INDEX ON TransactionLog(ToAddress);
+DROP INDEX ON TransactionLog(TxID);
+CREATE INDEX ON TransactionLog("to");
+CREATE INDEX ON TransactionLog(txID);